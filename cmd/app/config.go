@@ -3,13 +3,16 @@ package main
 import (
 	"fmt"
 	"github.com/caarlos0/env/v11"
+	"time"
 )
 
 type (
 	Config struct {
-		HTTPServer HTTPServer `envPrefix:"HTTP_SERVER_"`
-		DB         DB         `envPrefix:"MAIN_DB_"`
-		Migrations Migrations `envPrefix:"MIGRATIONS_"`
+		HTTPServer  HTTPServer  `envPrefix:"HTTP_SERVER_"`
+		DB          DB          `envPrefix:"MAIN_DB_"`
+		Migrations  Migrations  `envPrefix:"MIGRATIONS_"`
+		Idempotency Idempotency `envPrefix:"IDEMPOTENCY_"`
+		Auth        Auth        `envPrefix:"AUTH_"`
 	}
 	HTTPServer struct {
 		ListenAddr string `env:"LISTEN_ADDR,notEmpty"`
@@ -26,8 +29,33 @@ type (
 		Dir     string `env:"DIR,notEmpty"`
 		Enabled bool   `env:"ENABLED" envDefault:"false"`
 	}
+	Idempotency struct {
+		KeyTTL time.Duration `env:"KEY_TTL" envDefault:"24h"`
+	}
+	// Auth configures JWT authentication. Tokens are verified with the HS256 secret, the RS256 public key (PEM) and
+	// the keys of the JWKS file, whichever are set; at least one is required unless authentication is disabled.
+	Auth struct {
+		Enabled            bool          `env:"ENABLED" envDefault:"true"`
+		HS256Secret        secret        `env:"HS256_SECRET"`
+		RS256PublicKeyFile string        `env:"RS256_PUBLIC_KEY_FILE"`
+		JWKSFile           string        `env:"JWKS_FILE"`
+		Issuer             string        `env:"ISSUER"`
+		Audience           string        `env:"AUDIENCE"`
+		Leeway             time.Duration `env:"LEEWAY" envDefault:"30s"`
+	}
 )
 
+// secret is a config value that must not show up in logs.
+type secret string
+
+func (s secret) String() string {
+	return "[redacted]"
+}
+
+func (s secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
 func loadConfigFromEnv() (Config, error) {
 	c, err := env.ParseAs[Config]()
 	if err != nil {
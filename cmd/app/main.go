@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
 	"github.com/BernsteinMondy/subscription-service/internal/controller"
 	"github.com/BernsteinMondy/subscription-service/internal/middleware"
 	"github.com/BernsteinMondy/subscription-service/internal/migrations"
@@ -14,6 +15,7 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -82,13 +84,24 @@ func run() (err error) {
 
 	// Repository - Service - Controller
 	repo := repository.New(db)
-	srvc := service.NewService(repo)
-	ctrl := controller.New(srvc)
+	srvc := service.NewService(repo, cfg.Idempotency.KeyTTL)
+	ctrl := controller.New(srvc, middleware.NewAuthorizer(srvc))
 
 	// HTTP mux and middleware
 	mux := http.NewServeMux()
 	ctrl.MapHandlers(mux)
-	handlerWithMw := middleware.LoggingMiddleware(mux)
+
+	var handler http.Handler = mux
+	if cfg.Auth.Enabled {
+		verifier, err := newTokenVerifier(cfg.Auth)
+		if err != nil {
+			return fmt.Errorf("newTokenVerifier: %w", err)
+		}
+		handler = middleware.AuthMiddleware(verifier)(handler)
+	} else {
+		slog.Warn("Authentication disabled - the API is open to anyone who can reach it")
+	}
+	handlerWithMw := middleware.LoggingMiddleware(handler)
 
 	// HTTP server
 	httpServer := &http.Server{
@@ -118,6 +131,40 @@ func newDatabaseConnection(c DB) (*sql.DB, error) {
 	return database.NewConnection(dbCfg)
 }
 
+func newTokenVerifier(c Auth) (*auth.Verifier, error) {
+	keys := auth.NewKeySet()
+
+	if c.HS256Secret != "" {
+		keys.AddHMAC("", []byte(c.HS256Secret))
+	}
+
+	if c.RS256PublicKeyFile != "" {
+		data, err := os.ReadFile(c.RS256PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read RS256 public key: %w", err)
+		}
+
+		key, err := auth.ParseRSAPublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse RS256 public key: %w", err)
+		}
+		keys.AddRSA("", key)
+	}
+
+	if c.JWKSFile != "" {
+		err := keys.LoadJWKSFile(c.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWKS file: %w", err)
+		}
+	}
+
+	if keys.Empty() {
+		return nil, errors.New("no token verification key configured")
+	}
+
+	return auth.NewVerifier(keys, c.Issuer, c.Audience, c.Leeway), nil
+}
+
 func launchHTTPServer(ctx context.Context, httpServer *http.Server) error {
 	serverErr := make(chan error, 1)
 
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject is the "sub" claim of the caller's token.
+	Subject string
+	// Roles are taken from the token's "roles" claim, an array of strings or a space-separated string. The
+	// authorization middleware adds the roles assigned to the subject in the database.
+	Roles []string
+	// Claims are all claims of the token. Numbers are json.Number.
+	Claims map[string]any
+}
+
+// rolesFromClaim parses the "roles" claim.
+func rolesFromClaim(claim any) []string {
+	switch roles := claim.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []any:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				result = append(result, name)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity put on ctx by WithIdentity, if any.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
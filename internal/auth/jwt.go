@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+)
+
+// Verifier checks JWTs signed with HS256 or RS256 against a KeySet.
+type Verifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewVerifier creates a Verifier. A non-empty issuer or audience must match the token's "iss" or "aud" claim;
+// leeway allows for clock skew when checking "exp" and "nbf".
+func NewVerifier(keys *KeySet, issuer, audience string, leeway time.Duration) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   leeway,
+		now:      time.Now,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and claims of token and returns the identity it asserts. Tokens must have "sub" and
+// "exp" claims. It fails with ErrTokenExpired for an expired token and ErrInvalidToken otherwise.
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+
+	err = v.verifySignature(&header, parts[0]+"."+parts[1], signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	err = v.checkClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return &Identity{
+		Subject: subject,
+		Roles:   rolesFromClaim(claims["roles"]),
+		Claims:  claims,
+	}, nil
+}
+
+// verifySignature only uses keys of the kind the algorithm needs, so an RSA public key can never be used as an
+// HMAC secret.
+func (v *Verifier) verifySignature(header *jwtHeader, signed string, signature []byte) error {
+	switch header.Alg {
+	case algHS256:
+		secret, ok := lookupKey(v.keys.hmac, header.Kid)
+		if !ok {
+			return fmt.Errorf("%w: no HS256 key for kid %q", ErrInvalidToken, header.Kid)
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+	case algRS256:
+		key, ok := lookupKey(v.keys.rsa, header.Kid)
+		if !ok {
+			return fmt.Errorf("%w: no RS256 key for kid %q", ErrInvalidToken, header.Kid)
+		}
+
+		digest := sha256.Sum256([]byte(signed))
+		err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+		if err != nil {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	return nil
+}
+
+// lookupKey finds the key for kid. A token without kid may use the only key of its kind.
+func lookupKey[K any](keys map[string]K, kid string) (K, bool) {
+	key, ok := keys[kid]
+	if ok || kid != "" || len(keys) != 1 {
+		return key, ok
+	}
+
+	for _, key := range keys {
+		return key, true
+	}
+
+	return key, false
+}
+
+func (v *Verifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(exp.Add(v.leeway)) {
+		return ErrTokenExpired
+	}
+
+	if _, present := claims["nbf"]; present {
+		nbf, ok := numericDate(claims["nbf"])
+		if !ok {
+			return fmt.Errorf("%w: malformed nbf claim", ErrInvalidToken)
+		}
+		if now.Add(v.leeway).Before(nbf) {
+			return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+		}
+	}
+
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// numericDate parses a JWT NumericDate, seconds since the Unix epoch.
+func numericDate(claim any) (time.Time, bool) {
+	number, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if seconds, err := number.Int64(); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+
+	seconds, err := number.Float64()
+	if err != nil || seconds > math.MaxInt64 || seconds < math.MinInt64 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(seconds), 0), true
+}
+
+// hasAudience tells whether the "aud" claim, a string or an array of strings, contains audience.
+func hasAudience(claim any, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("decode base64: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(dst)
+}
@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAKeyOnce sync.Once
+	testRSAKey     *rsa.PrivateKey
+)
+
+// rsaKey returns an RSA key shared by the tests of the package, as generating one is slow.
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	testRSAKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		testRSAKey = key
+	})
+
+	return testRSAKey
+}
+
+func rsaPublicKeyPEM(t *testing.T) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey(t).PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal segment: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signHS256 returns a token of header and claims signed with secret, whatever alg the header names.
+func signHS256(t *testing.T, header, claims map[string]any, secret []byte) string {
+	t.Helper()
+
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey(t), crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+var (
+	testSecret = []byte("0123456789abcdef0123456789abcdef")
+	testNow    = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+)
+
+// testClaims returns valid claims with overrides applied; a nil override removes the claim.
+func testClaims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"sub": "alice",
+		"iss": "https://issuer.example.com",
+		"aud": "subscription-service",
+		"exp": testNow.Add(time.Hour).Unix(),
+	}
+
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	rs256 := map[string]any{"alg": "RS256", "typ": "JWT"}
+
+	tests := []struct {
+		name    string
+		keys    func(t *testing.T) *KeySet
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name: "valid HS256",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(nil), testSecret)
+			},
+		},
+		{
+			name: "valid RS256",
+			token: func(t *testing.T) string {
+				return signRS256(t, rs256, testClaims(nil))
+			},
+		},
+		{
+			name: "alg none",
+			token: func(t *testing.T) string {
+				return encodeSegment(t, map[string]any{"alg": "none"}) + "." + encodeSegment(t, testClaims(nil)) + "."
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "HS256 signed with the RSA public key",
+			keys: func(t *testing.T) *KeySet {
+				keys := NewKeySet()
+				keys.AddRSA("", &rsaKey(t).PublicKey)
+				return keys
+			},
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(nil), rsaPublicKeyPEM(t))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "HS256 signed with the RSA public key next to an HMAC key",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(nil), rsaPublicKeyPEM(t))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "RS256 without RSA keys",
+			keys: func(t *testing.T) *KeySet {
+				keys := NewKeySet()
+				keys.AddHMAC("", testSecret)
+				return keys
+			},
+			token: func(t *testing.T) string {
+				return signRS256(t, rs256, testClaims(nil))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "bad HS256 signature",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(nil), []byte("another secret"))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T) string {
+				parts := strings.Split(signRS256(t, rs256, testClaims(nil)), ".")
+				parts[1] = encodeSegment(t, testClaims(map[string]any{"sub": "mallory"}))
+				return strings.Join(parts, ".")
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "malformed",
+			token: func(t *testing.T) string {
+				return "not.a-token"
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"exp": testNow.Add(-time.Minute).Unix()}), testSecret)
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "expired within leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"exp": testNow.Add(-10 * time.Second).Unix()}), testSecret)
+			},
+		},
+		{
+			name: "missing exp",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"exp": nil}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "exp not a number",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"exp": "tomorrow"}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "nbf in the future",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"nbf": testNow.Add(time.Minute).Unix()}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "nbf within leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"nbf": testNow.Add(10 * time.Second).Unix()}), testSecret)
+			},
+		},
+		{
+			name: "wrong issuer",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"iss": "https://evil.example.com"}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong audience",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"aud": "another-service"}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "audience array",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"aud": []string{"another-service", "subscription-service"}}), testSecret)
+			},
+		},
+		{
+			name: "missing sub",
+			token: func(t *testing.T) string {
+				return signHS256(t, hs256, testClaims(map[string]any{"sub": nil}), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unknown kid",
+			token: func(t *testing.T) string {
+				return signHS256(t, map[string]any{"alg": "HS256", "kid": "unknown"}, testClaims(nil), testSecret)
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := NewKeySet()
+			keys.AddHMAC("", testSecret)
+			keys.AddRSA("", &rsaKey(t).PublicKey)
+			if tt.keys != nil {
+				keys = tt.keys(t)
+			}
+
+			verifier := NewVerifier(keys, "https://issuer.example.com", "subscription-service", 30*time.Second)
+			verifier.now = func() time.Time { return testNow }
+
+			identity, err := verifier.Verify(tt.token(t))
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && identity.Subject != "alice" {
+				t.Fatalf("Verify() subject = %q, want alice", identity.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyRoles(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles any
+		want  []string
+	}{
+		{name: "array", roles: []string{"operator", "read-only"}, want: []string{"operator", "read-only"}},
+		{name: "space-separated", roles: "operator read-only", want: []string{"operator", "read-only"}},
+		{name: "non-string entries skipped", roles: []any{"operator", 1}, want: []string{"operator"}},
+		{name: "missing", roles: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := NewKeySet()
+			keys.AddHMAC("", testSecret)
+
+			verifier := NewVerifier(keys, "", "", 0)
+			verifier.now = func() time.Time { return testNow }
+
+			token := signHS256(t, map[string]any{"alg": "HS256"}, testClaims(map[string]any{"roles": tt.roles}), testSecret)
+
+			identity, err := verifier.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if len(identity.Roles) != len(tt.want) {
+				t.Fatalf("roles = %v, want %v", identity.Roles, tt.want)
+			}
+			for i := range tt.want {
+				if identity.Roles[i] != tt.want[i] {
+					t.Fatalf("roles = %v, want %v", identity.Roles, tt.want)
+				}
+			}
+		})
+	}
+}
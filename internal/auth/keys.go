@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// KeySet holds the keys tokens are verified with: HMAC secrets for HS256 and RSA public keys for RS256. Keys are
+// looked up by the token's "kid" header; keys added with an empty ID serve tokens without one.
+type KeySet struct {
+	hmac map[string][]byte
+	rsa  map[string]*rsa.PublicKey
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{
+		hmac: make(map[string][]byte),
+		rsa:  make(map[string]*rsa.PublicKey),
+	}
+}
+
+func (ks *KeySet) AddHMAC(kid string, secret []byte) {
+	ks.hmac[kid] = secret
+}
+
+func (ks *KeySet) AddRSA(kid string, key *rsa.PublicKey) {
+	ks.rsa[kid] = key
+}
+
+// Empty tells whether the set has no keys at all.
+func (ks *KeySet) Empty() bool {
+	return len(ks.hmac) == 0 && len(ks.rsa) == 0
+}
+
+// ParseRSAPublicKeyPEM parses a PEM encoded RSA public key, either PKIX ("PUBLIC KEY") or PKCS #1
+// ("RSA PUBLIC KEY").
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKIX public key: %w", err)
+		}
+
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, not RSA", key)
+		}
+
+		return rsaKey, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS1 public key: %w", err)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+}
+
+type jwksDTO struct {
+	Keys []jwkDTO `json:"keys"`
+}
+
+type jwkDTO struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// LoadJWKSFile adds the keys of a JSON Web Key Set file to ks. RSA keys are used for RS256 and symmetric ("oct")
+// keys for HS256; keys of other types, or meant for encryption, are skipped.
+func (ks *KeySet) LoadJWKSFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	var jwks jwksDTO
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return fmt.Errorf("unmarshal JWKS: %w", err)
+	}
+
+	for i, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		switch jwk.Kty {
+		case "RSA":
+			if jwk.Alg != "" && jwk.Alg != algRS256 {
+				continue
+			}
+
+			key, err := parseJWKRSA(jwk.N, jwk.E)
+			if err != nil {
+				return fmt.Errorf("key %d: %w", i, err)
+			}
+			ks.AddRSA(jwk.Kid, key)
+		case "oct":
+			if jwk.Alg != "" && jwk.Alg != algHS256 {
+				continue
+			}
+
+			secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("key %d: invalid k", i)
+			}
+			ks.AddHMAC(jwk.Kid, secret)
+		}
+	}
+
+	return nil
+}
+
+func parseJWKRSA(nStr, eStr string) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(nStr)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(eStr)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: exponent,
+	}, nil
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRSAPublicKeyPEM(t *testing.T) {
+	key := &rsaKey(t).PublicKey
+
+	pkix, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("marshal PKIX: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PKIX", data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})},
+		{name: "PKCS1", data: pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(key)})},
+		{name: "not PEM", data: []byte("not a key"), wantErr: true},
+		{name: "private key block", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkix}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRSAPublicKeyPEM(tt.data)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParseRSAPublicKeyPEM() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRSAPublicKeyPEM() error = %v", err)
+			}
+			if !got.Equal(key) {
+				t.Fatal("ParseRSAPublicKeyPEM() returned another key")
+			}
+		})
+	}
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatalf("marshal JWKS: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatalf("write JWKS: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	key := &rsaKey(t).PublicKey
+	rsaJWK := map[string]any{
+		"kty": "RSA",
+		"kid": "rsa-1",
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+	octJWK := map[string]any{
+		"kty": "oct",
+		"kid": "oct-1",
+		"k":   base64.RawURLEncoding.EncodeToString(testSecret),
+	}
+	encryptionJWK := map[string]any{
+		"kty": "oct",
+		"kid": "enc-1",
+		"use": "enc",
+		"k":   base64.RawURLEncoding.EncodeToString([]byte("encryption key")),
+	}
+
+	keys := NewKeySet()
+	err := keys.LoadJWKSFile(writeJWKS(t, rsaJWK, octJWK, encryptionJWK))
+	if err != nil {
+		t.Fatalf("LoadJWKSFile() error = %v", err)
+	}
+
+	if !keys.rsa["rsa-1"].Equal(key) {
+		t.Fatal("RSA key not loaded")
+	}
+	if string(keys.hmac["oct-1"]) != string(testSecret) {
+		t.Fatal("oct key not loaded")
+	}
+	if _, ok := keys.hmac["enc-1"]; ok {
+		t.Fatal("encryption key loaded")
+	}
+
+	verifier := NewVerifier(keys, "", "", 0)
+	verifier.now = func() time.Time { return testNow }
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name: "RS256 with known kid",
+			token: func(t *testing.T) string {
+				return signRS256(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, testClaims(nil))
+			},
+		},
+		{
+			name: "HS256 with known kid",
+			token: func(t *testing.T) string {
+				return signHS256(t, map[string]any{"alg": "HS256", "kid": "oct-1"}, testClaims(nil), testSecret)
+			},
+		},
+		{
+			name: "unknown kid",
+			token: func(t *testing.T) string {
+				return signRS256(t, map[string]any{"alg": "RS256", "kid": "rsa-2"}, testClaims(nil))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "kid of another key type",
+			token: func(t *testing.T) string {
+				return signHS256(t, map[string]any{"alg": "HS256", "kid": "rsa-1"}, testClaims(nil), rsaPublicKeyPEM(t))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "encryption key",
+			token: func(t *testing.T) string {
+				return signHS256(t, map[string]any{"alg": "HS256", "kid": "enc-1"}, testClaims(nil), []byte("encryption key"))
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(tt.token(t))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadJWKSFileInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		key  map[string]any
+	}{
+		{name: "RSA without modulus", key: map[string]any{"kty": "RSA", "e": "AQAB"}},
+		{name: "RSA with oversized exponent", key: map[string]any{"kty": "RSA", "n": "AQAB", "e": "AQABAQAB"}},
+		{name: "oct without k", key: map[string]any{"kty": "oct"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewKeySet().LoadJWKSFile(writeJWKS(t, tt.key))
+			if err == nil {
+				t.Fatal("LoadJWKSFile() succeeded, want an error")
+			}
+		})
+	}
+}
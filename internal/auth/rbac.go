@@ -0,0 +1,84 @@
+package auth
+
+import "slices"
+
+// Permission allows a kind of request.
+type Permission string
+
+const (
+	PermissionSubscriptionsRead  Permission = "subscriptions:read"
+	PermissionSubscriptionsWrite Permission = "subscriptions:write"
+	// PermissionSubscriptionsAllUsers extends the other subscription permissions from the caller's own
+	// subscriptions to those of every user.
+	PermissionSubscriptionsAllUsers Permission = "subscriptions:all_users"
+	PermissionReportsRead           Permission = "reports:read"
+	PermissionAdmin                 Permission = "admin:*"
+)
+
+const (
+	// RoleAdmin has every permission.
+	RoleAdmin = "admin"
+	// RoleOperator manages the subscriptions of every user.
+	RoleOperator = "operator"
+	// RoleReadOnly may only read its own subscriptions and reports.
+	RoleReadOnly = "read-only"
+	// RoleUser manages its own subscriptions.
+	RoleUser = "user"
+)
+
+// DefaultRole is given to authenticated callers that have no role, neither in their token nor assigned.
+const DefaultRole = RoleUser
+
+// rolePermissions lists the permissions granted by each role.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermissionSubscriptionsRead,
+		PermissionSubscriptionsWrite,
+		PermissionSubscriptionsAllUsers,
+		PermissionReportsRead,
+		PermissionAdmin,
+	},
+	RoleOperator: {
+		PermissionSubscriptionsRead,
+		PermissionSubscriptionsWrite,
+		PermissionSubscriptionsAllUsers,
+		PermissionReportsRead,
+	},
+	RoleReadOnly: {
+		PermissionSubscriptionsRead,
+		PermissionReportsRead,
+	},
+	RoleUser: {
+		PermissionSubscriptionsRead,
+		PermissionSubscriptionsWrite,
+		PermissionReportsRead,
+	},
+}
+
+// IsRole tells whether role is a known role.
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Roles returns the names of the known roles, sorted.
+func Roles() []string {
+	roles := make([]string, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+
+	return roles
+}
+
+// HasPermission tells whether any role of the identity grants permission. Unknown roles grant nothing.
+func (i *Identity) HasPermission(permission Permission) bool {
+	for _, role := range i.Roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// maxBatchSize bounds the number of items in one batch request.
+const maxBatchSize = 1000
+
+// CreateSubscriptionsBatch godoc
+// @Summary Create subscriptions in bulk
+// @Description Create up to 1000 subscriptions in one transaction. Each item is validated like POST /subscriptions.
+// @Description In atomic mode (default) nothing is created if any item is invalid; the response then has the status of the first failed item, and valid items are reported with status 424. In partial mode the valid items are created and the response is 207 if any item failed.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param mode query string false "atomic (default) or partial" Enums(atomic, partial)
+// @Param subscriptions body []createSubscriptionRequestDTO true "Subscriptions to create"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscriptions' history; ignored for authenticated requests, which record the token subject"
+// @Success 201 {object} batchResponseDTO "Every subscription was created"
+// @Success 207 {object} batchResponseDTO "Partial mode: some subscriptions were created"
+// @Failure 400 {object} batchResponseDTO "Bad Request"
+// @Failure 403 {object} problemDTO "user_id is not the caller's own user ID"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions:batch [post]
+func (c *controller) postSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		handleError(w, invalidField("mode", err))
+		return
+	}
+
+	var reqs []createSubscriptionRequestDTO
+
+	err = decodeJSONBody(r.Body, &reqs)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	err = checkBatchSize(len(reqs))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	results := newBatchResults(len(reqs))
+	valid := make([]*entity.CreateSubscriptionData, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+
+	for i := range reqs {
+		data, err := parseCreateSubscription(&reqs[i])
+		if err != nil {
+			results[i].setError(err)
+			continue
+		}
+		valid = append(valid, data)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) > 0 && (mode == entity.BatchModePartial || len(valid) == len(reqs)) {
+		ctx := r.Context()
+		ids, err := c.service.NewSubscriptions(ctx, valid, actorFromRequest(r))
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		for j, id := range ids {
+			results[indexes[j]].ID = id.String()
+			results[indexes[j]].Status = http.StatusCreated
+		}
+	}
+
+	writeBatchResponse(w, results, mode, http.StatusCreated)
+}
+
+// UpdateSubscriptionsBatch godoc
+// @Summary Update subscriptions in bulk
+// @Description Replace up to 1000 subscriptions in one transaction. Each item is validated like PUT /subscriptions/{id}; version works like If-Match.
+// @Description In atomic mode (default) nothing is changed if any item fails; the response then has the status of the first failed item, and the other items are reported with status 424. In partial mode the other items are applied and the response is 207 if any item failed.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param mode query string false "atomic (default) or partial" Enums(atomic, partial)
+// @Param subscriptions body []batchUpdateItemDTO true "Subscription IDs with their new data"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscriptions' history; ignored for authenticated requests, which record the token subject"
+// @Success 200 {object} batchResponseDTO "Every subscription was updated"
+// @Success 207 {object} batchResponseDTO "Partial mode: some subscriptions were updated"
+// @Failure 400 {object} batchResponseDTO "Bad Request"
+// @Failure 404 {object} batchResponseDTO "Atomic mode: a subscription was not found"
+// @Failure 409 {object} batchResponseDTO "Atomic mode: an update conflicts with a subscription's scheduled prices"
+// @Failure 412 {object} batchResponseDTO "Atomic mode: a subscription's version did not match"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions:batchUpdate [post]
+func (c *controller) updateSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		handleError(w, invalidField("mode", err))
+		return
+	}
+
+	var reqs []batchUpdateItemDTO
+
+	err = decodeJSONBody(r.Body, &reqs)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	err = checkBatchSize(len(reqs))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	results := newBatchResults(len(reqs))
+	updates := make([]entity.SubscriptionUpdate, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+
+	for i := range reqs {
+		update, err := parseBatchUpdateItem(&reqs[i])
+		if err != nil {
+			results[i].setError(err)
+			continue
+		}
+		results[i].ID = update.ID.String()
+		updates = append(updates, update)
+		indexes = append(indexes, i)
+	}
+
+	if len(updates) > 0 && (mode == entity.BatchModePartial || len(updates) == len(reqs)) {
+		ctx := r.Context()
+		applied, err := c.service.UpdateSubscriptions(ctx, updates, mode, actorFromRequest(r))
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		setBatchResults(results, indexes, applied)
+	}
+
+	writeBatchResponse(w, results, mode, http.StatusOK)
+}
+
+// DeleteSubscriptionsBatch godoc
+// @Summary Cancel subscriptions in bulk
+// @Description Cancel up to 1000 subscriptions in one transaction, like DELETE /subscriptions/{id}.
+// @Description In atomic mode (default) nothing is changed if any item fails; the response then has the status of the first failed item, and the other items are reported with status 424. In partial mode the other items are applied and the response is 207 if any item failed.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param mode query string false "atomic (default) or partial" Enums(atomic, partial)
+// @Param request body batchDeleteRequestDTO true "IDs of the subscriptions to cancel"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscriptions' history; ignored for authenticated requests, which record the token subject"
+// @Success 200 {object} batchResponseDTO "Every subscription was cancelled"
+// @Success 207 {object} batchResponseDTO "Partial mode: some subscriptions were cancelled"
+// @Failure 400 {object} batchResponseDTO "Bad Request"
+// @Failure 404 {object} batchResponseDTO "Atomic mode: a subscription was not found"
+// @Failure 409 {object} batchResponseDTO "Atomic mode: a subscription is already cancelled or expired"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions:batchDelete [post]
+func (c *controller) deleteSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		handleError(w, invalidField("mode", err))
+		return
+	}
+
+	var req batchDeleteRequestDTO
+
+	err = decodeJSONBody(r.Body, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	err = checkBatchSize(len(req.IDs))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	results := newBatchResults(len(req.IDs))
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	indexes := make([]int, 0, len(req.IDs))
+
+	for i, idStr := range req.IDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			results[i].setError(invalidField("ids", fmt.Errorf("id parse failed: %w", err)))
+			continue
+		}
+		results[i].ID = id.String()
+		ids = append(ids, id)
+		indexes = append(indexes, i)
+	}
+
+	if len(ids) > 0 && (mode == entity.BatchModePartial || len(ids) == len(req.IDs)) {
+		ctx := r.Context()
+		applied, err := c.service.CancelSubscriptions(ctx, ids, req.AtPeriodEnd, mode, actorFromRequest(r))
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		setBatchResults(results, indexes, applied)
+	}
+
+	writeBatchResponse(w, results, mode, http.StatusOK)
+}
+
+func parseBatchMode(modeStr string) (entity.BatchMode, error) {
+	switch mode := entity.BatchMode(modeStr); mode {
+	case "":
+		return entity.BatchModeAtomic, nil
+	case entity.BatchModeAtomic, entity.BatchModePartial:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown batch mode %q", modeStr)
+	}
+}
+
+func parseBatchUpdateItem(req *batchUpdateItemDTO) (entity.SubscriptionUpdate, error) {
+	err := validate(req)
+	if err != nil {
+		return entity.SubscriptionUpdate{}, err
+	}
+
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return entity.SubscriptionUpdate{}, invalidField("id", fmt.Errorf("id parse failed: %w", err))
+	}
+
+	data, err := parseUpdateSubscription(&req.updateSubscriptionCreateDTO)
+	if err != nil {
+		return entity.SubscriptionUpdate{}, err
+	}
+
+	update := entity.SubscriptionUpdate{
+		ID:   id,
+		Data: data,
+	}
+	if req.Version != nil {
+		update.IfMatch = []int64{*req.Version}
+	}
+
+	return update, nil
+}
+
+func newBatchResults(n int) []batchItemResultDTO {
+	results := make([]batchItemResultDTO, n)
+	for i := range results {
+		results[i].Index = i
+	}
+
+	return results
+}
+
+// checkBatchSize fails unless a batch has between one and maxBatchSize items.
+func checkBatchSize(n int) error {
+	if n == 0 || n > maxBatchSize {
+		return malformedRequest(fmt.Errorf("batch must have between 1 and %d items", maxBatchSize))
+	}
+
+	return nil
+}
+
+// setError reports err as the item's problem.
+func (res *batchItemResultDTO) setError(err error) {
+	problem := newProblem(err)
+
+	res.Status = problem.Status
+	res.Code = problem.Code
+	res.Error = problem.Detail
+	if res.Error == "" {
+		res.Error = problem.Title
+	}
+	res.Errors = problem.Errors
+}
+
+// setBatchResults copies the outcome of the items passed to the service, applied[j] belonging to
+// results[indexes[j]].
+func setBatchResults(results []batchItemResultDTO, indexes []int, applied []entity.BatchItemResult) {
+	for j, result := range applied {
+		res := &results[indexes[j]]
+		res.ID = result.ID.String()
+
+		if result.Err != nil {
+			res.setError(result.Err)
+			continue
+		}
+
+		res.Status = http.StatusOK
+		res.Version = result.Version
+	}
+}
+
+// writeBatchResponse writes the results with successStatus if every item succeeded. Otherwise a partial batch is
+// answered with 207, and an atomic one with the status of its first failed item, its other items marked 424.
+func writeBatchResponse(w http.ResponseWriter, results []batchItemResultDTO, mode entity.BatchMode, successStatus int) {
+	status := successStatus
+
+	for i := range results {
+		if results[i].Status < http.StatusBadRequest && results[i].Status != 0 {
+			continue
+		}
+
+		if mode == entity.BatchModePartial {
+			status = http.StatusMultiStatus
+			break
+		}
+
+		if status == successStatus && results[i].Status != 0 {
+			status = results[i].Status
+		}
+	}
+
+	if mode == entity.BatchModeAtomic && status != successStatus {
+		for i := range results {
+			if results[i].Status < http.StatusBadRequest {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Version = 0
+				results[i].Code = codeBatchItemNotApplied
+				results[i].Error = problemTitles[codeBatchItemNotApplied]
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(batchResponseDTO{Results: results})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
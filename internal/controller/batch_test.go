@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    entity.BatchMode
+		wantErr bool
+	}{
+		{mode: "", want: entity.BatchModeAtomic},
+		{mode: "atomic", want: entity.BatchModeAtomic},
+		{mode: "partial", want: entity.BatchModePartial},
+		{mode: "Partial", wantErr: true},
+		{mode: "all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got, err := parseBatchMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBatchMode(%q) error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseBatchMode(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBatchSize(t *testing.T) {
+	tests := []struct {
+		n       int
+		wantErr bool
+	}{
+		{n: 0, wantErr: true},
+		{n: 1},
+		{n: maxBatchSize},
+		{n: maxBatchSize + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := checkBatchSize(tt.n)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("checkBatchSize(%d) error = %v, wantErr %v", tt.n, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseBatchUpdateItem(t *testing.T) {
+	id := uuid.New()
+	version := int64(3)
+	data := updateSubscriptionCreateDTO{ServiceName: "Netflix", Price: "9.99", StartDate: "07-2025"}
+
+	update, err := parseBatchUpdateItem(&batchUpdateItemDTO{ID: id.String(), Version: &version, updateSubscriptionCreateDTO: data})
+	if err != nil {
+		t.Fatalf("parseBatchUpdateItem() error = %v", err)
+	}
+	if update.ID != id || len(update.IfMatch) != 1 || update.IfMatch[0] != version {
+		t.Fatalf("parseBatchUpdateItem() = %+v, want ID %v and IfMatch [%d]", update, id, version)
+	}
+	if update.Data.ServiceName != "Netflix" || update.Data.Price.String() != "9.99" {
+		t.Fatalf("data = %+v, want Netflix for 9.99", update.Data)
+	}
+
+	update, err = parseBatchUpdateItem(&batchUpdateItemDTO{ID: id.String(), updateSubscriptionCreateDTO: data})
+	if err != nil {
+		t.Fatalf("parseBatchUpdateItem() without version error = %v", err)
+	}
+	if update.IfMatch != nil {
+		t.Fatalf("IfMatch = %v, want none", update.IfMatch)
+	}
+
+	_, err = parseBatchUpdateItem(&batchUpdateItemDTO{ID: "nope", updateSubscriptionCreateDTO: data})
+	assertFieldError(t, err, "id")
+
+	_, err = parseBatchUpdateItem(&batchUpdateItemDTO{ID: id.String()})
+	assertFieldError(t, err, "service_name")
+}
+
+func TestSetBatchResults(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	results := newBatchResults(3)
+	results[1].setError(invalidField("id", errors.New("invalid UUID")))
+
+	setBatchResults(results, []int{0, 2}, []entity.BatchItemResult{
+		{ID: first, Version: 4},
+		{ID: second, Err: srvc.ErrPreconditionFailed},
+	})
+
+	for i, res := range results {
+		if res.Index != i {
+			t.Fatalf("result %d index = %d", i, res.Index)
+		}
+	}
+	if got := results[0]; got.ID != first.String() || got.Status != http.StatusOK || got.Version != 4 {
+		t.Fatalf("result 0 = %+v, want %v applied at version 4", got, first)
+	}
+	if got := results[1]; got.Status != http.StatusBadRequest || got.Code != codeValidationFailed || len(got.Errors) != 1 {
+		t.Fatalf("result 1 = %+v, want a validation error", got)
+	}
+	if got := results[2]; got.ID != second.String() || got.Status != http.StatusPreconditionFailed || got.Code != codePreconditionFailed {
+		t.Fatalf("result 2 = %+v, want %v failed with 412", got, second)
+	}
+}
+
+func TestWriteBatchResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         entity.BatchMode
+		statuses     []int
+		wantStatus   int
+		wantStatuses []int
+	}{
+		{
+			name:         "atomic success",
+			mode:         entity.BatchModeAtomic,
+			statuses:     []int{http.StatusOK, http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:         "atomic failure",
+			mode:         entity.BatchModeAtomic,
+			statuses:     []int{http.StatusOK, http.StatusNotFound, http.StatusBadRequest},
+			wantStatus:   http.StatusNotFound,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusNotFound, http.StatusBadRequest},
+		},
+		{
+			name:         "atomic invalid item before the service was called",
+			mode:         entity.BatchModeAtomic,
+			statuses:     []int{0, http.StatusBadRequest},
+			wantStatus:   http.StatusBadRequest,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+		},
+		{
+			name:         "partial success",
+			mode:         entity.BatchModePartial,
+			statuses:     []int{http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusOK},
+		},
+		{
+			name:         "partial failure",
+			mode:         entity.BatchModePartial,
+			statuses:     []int{http.StatusOK, http.StatusNotFound},
+			wantStatus:   http.StatusMultiStatus,
+			wantStatuses: []int{http.StatusOK, http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := newBatchResults(len(tt.statuses))
+			for i, status := range tt.statuses {
+				results[i].Status = status
+			}
+
+			w := httptest.NewRecorder()
+			writeBatchResponse(w, results, tt.mode, http.StatusOK)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			var resp batchResponseDTO
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatalf("unmarshal response: %v", err)
+			}
+			for i, want := range tt.wantStatuses {
+				if got := resp.Results[i]; got.Status != want {
+					t.Fatalf("result %d status = %d, want %d", i, got.Status, want)
+				}
+				if want == http.StatusFailedDependency && resp.Results[i].Code != codeBatchItemNotApplied {
+					t.Fatalf("result %d code = %q, want %q", i, resp.Results[i].Code, codeBatchItemNotApplied)
+				}
+			}
+		})
+	}
+}
+
+func TestPostSubscriptionsBatch(t *testing.T) {
+	const body = `[
+		{"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Netflix","price":"9.99","start_date":"07-2025"},
+		{"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Spotify","price":"4.99"}
+	]`
+
+	tests := []struct {
+		mode        string
+		wantStatus  int
+		wantCreated int
+	}{
+		{mode: "atomic", wantStatus: http.StatusBadRequest},
+		{mode: "partial", wantStatus: http.StatusMultiStatus, wantCreated: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var created []*entity.CreateSubscriptionData
+			fake := &fakeService{
+				newSubscriptions: func(_ context.Context, data []*entity.CreateSubscriptionData, _ string) ([]uuid.UUID, error) {
+					created = data
+					return []uuid.UUID{uuid.New()}, nil
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/subscriptions:batch?mode="+tt.mode, strings.NewReader(body))
+			w := serve(t, fake, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if len(created) != tt.wantCreated {
+				t.Fatalf("created %d subscriptions, want %d", len(created), tt.wantCreated)
+			}
+
+			var resp batchResponseDTO
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatalf("unmarshal response: %v", err)
+			}
+			if got := resp.Results[1]; got.Status != http.StatusBadRequest || len(got.Errors) != 1 || got.Errors[0].Field != "start_date" {
+				t.Fatalf("second result = %+v, want a start_date error", got)
+			}
+		})
+	}
+}
@@ -1,30 +1,611 @@
 package controller
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const timeFormat = "01-2006"
 
+// defaultCurrency is assumed for subscriptions created without a currency.
+const defaultCurrency = "RUB"
+
+var (
+	currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+	decimalRegexp  = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
 func parseStartAndEndDate(startDateStr, endDateStr string) (time.Time, time.Time, error) {
 	startDate, err := time.Parse(timeFormat, startDateStr)
 	if err != nil {
-		return time.Time{}, time.Time{}, fmt.Errorf("start date parse failed: %w", err)
+		return time.Time{}, time.Time{}, invalidField("start_date", fmt.Errorf("start date parse failed: %w", err))
 	}
 
 	endDate, err := time.Parse(timeFormat, endDateStr)
 	if err != nil {
-		return time.Time{}, time.Time{}, fmt.Errorf("end date parse failed: %w", err)
+		return time.Time{}, time.Time{}, invalidField("end_date", fmt.Errorf("end date parse failed: %w", err))
 	}
 
 	if startDate.After(endDate) {
-		return time.Time{}, time.Time{}, fmt.Errorf("start date is after end date")
+		return time.Time{}, time.Time{}, invalidField("end_date", fmt.Errorf("start date is after end date"))
 	}
 
 	if endDate.Before(startDate) {
-		return time.Time{}, time.Time{}, fmt.Errorf("end date is before start date")
+		return time.Time{}, time.Time{}, invalidField("end_date", fmt.Errorf("end date is before start date"))
 	}
 
 	return startDate, endDate, nil
 }
+
+// parseCurrency parses an ISO 4217 currency code. Lower case codes are accepted.
+func parseCurrency(currencyStr string) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(currencyStr))
+	if !currencyRegexp.MatchString(currency) {
+		return "", fmt.Errorf("invalid currency %q", currencyStr)
+	}
+
+	return currency, nil
+}
+
+// parseOptionalCurrency parses a currency code, returning fallback for an empty string.
+func parseOptionalCurrency(currencyStr, fallback string) (string, error) {
+	if currencyStr == "" {
+		return fallback, nil
+	}
+
+	return parseCurrency(currencyStr)
+}
+
+// parseBilling parses a subscription's billing period and interval, defaulting to one month.
+func parseBilling(periodStr string, interval int) (entity.BillingPeriod, int32, error) {
+	period := entity.BillingPeriod(periodStr)
+
+	switch period {
+	case "":
+		period = entity.BillingPeriodMonthly
+	case entity.BillingPeriodWeekly, entity.BillingPeriodMonthly, entity.BillingPeriodQuarterly, entity.BillingPeriodYearly:
+	default:
+		return "", 0, invalidField("billing_period", fmt.Errorf("unknown billing period %q", periodStr))
+	}
+
+	if interval == 0 {
+		interval = 1
+	}
+
+	if interval < 0 || interval > math.MaxInt32 {
+		return "", 0, invalidField("billing_interval", fmt.Errorf("billing interval out of range"))
+	}
+
+	return period, int32(interval), nil
+}
+
+// parseSubscriptionPeriod parses a subscription's period. An empty end date means the subscription is open-ended.
+func parseSubscriptionPeriod(startDateStr, endDateStr string) (time.Time, *time.Time, error) {
+	if endDateStr == "" {
+		startDate, err := time.Parse(timeFormat, startDateStr)
+		if err != nil {
+			return time.Time{}, nil, invalidField("start_date", fmt.Errorf("start date parse failed: %w", err))
+		}
+
+		return startDate, nil, nil
+	}
+
+	startDate, endDate, err := parseStartAndEndDate(startDateStr, endDateStr)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return startDate, &endDate, nil
+}
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	maxReportMonths = 120
+)
+
+func parseLimit(limitStr string) (int, error) {
+	if limitStr == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		return 0, fmt.Errorf("limit parse failed: %w", err)
+	}
+
+	if limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+
+	return limit, nil
+}
+
+// parseSort parses the "<field>[:<order>]" form of the sort query parameter, e.g. "price:desc".
+func parseSort(sortStr string) (entity.Sort, error) {
+	sort := entity.Sort{
+		Field: entity.SortByStartDate,
+		Order: entity.SortAsc,
+	}
+
+	if sortStr == "" {
+		return sort, nil
+	}
+
+	fieldStr, orderStr, hasOrder := strings.Cut(sortStr, ":")
+
+	switch field := entity.SortField(fieldStr); field {
+	case entity.SortByStartDate, entity.SortByEndDate, entity.SortByPrice, entity.SortByServiceName:
+		sort.Field = field
+	default:
+		return entity.Sort{}, fmt.Errorf("unknown sort field %q", fieldStr)
+	}
+
+	if hasOrder {
+		switch order := entity.SortOrder(orderStr); order {
+		case entity.SortAsc, entity.SortDesc:
+			sort.Order = order
+		default:
+			return entity.Sort{}, fmt.Errorf("unknown sort order %q", orderStr)
+		}
+	}
+
+	return sort, nil
+}
+
+type cursorDTO struct {
+	Field string `json:"f"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(cursor *entity.Cursor) string {
+	data, _ := json.Marshal(cursorDTO{
+		Field: string(cursor.Sort.Field),
+		Order: string(cursor.Sort.Order),
+		Value: cursor.Value,
+		ID:    cursor.ID.String(),
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor. Cursors are opaque to clients, so any defect, including a
+// value that is not a valid key for the cursor's sort field, is reported as an invalid cursor.
+func decodeCursor(cursorStr string) (*entity.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+	if err != nil {
+		return nil, invalidCursor(fmt.Errorf("cursor decode failed: %w", err))
+	}
+
+	var dto cursorDTO
+	err = json.Unmarshal(data, &dto)
+	if err != nil {
+		return nil, invalidCursor(fmt.Errorf("cursor unmarshal failed: %w", err))
+	}
+
+	id, err := uuid.Parse(dto.ID)
+	if err != nil {
+		return nil, invalidCursor(fmt.Errorf("cursor id parse failed: %w", err))
+	}
+
+	sort := entity.Sort{
+		Field: entity.SortField(dto.Field),
+		Order: entity.SortOrder(dto.Order),
+	}
+
+	err = checkCursorValue(sort.Field, dto.Value)
+	if err != nil {
+		return nil, invalidCursor(err)
+	}
+
+	return &entity.Cursor{
+		Sort:  sort,
+		Value: dto.Value,
+		ID:    id,
+	}, nil
+}
+
+// checkCursorValue fails unless value is a key Subscription.SortValue could have returned for field, so that the
+// repository can cast it to the column's type.
+func checkCursorValue(field entity.SortField, value string) error {
+	switch field {
+	case entity.SortByStartDate:
+		_, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("cursor value parse failed: %w", err)
+		}
+	case entity.SortByEndDate:
+		if value == "infinity" {
+			return nil
+		}
+		_, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("cursor value parse failed: %w", err)
+		}
+	case entity.SortByPrice:
+		_, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cursor value parse failed: %w", err)
+		}
+	case entity.SortByServiceName:
+		if !utf8.ValidString(value) || strings.ContainsRune(value, 0) {
+			return errors.New("cursor value is not valid text")
+		}
+	default:
+		return fmt.Errorf("unknown cursor sort field %q", field)
+	}
+
+	return nil
+}
+
+// parseSubscriptionsFilter builds a filter from the list query parameters. user_id and service_name may be
+// repeated; user_id also accepts a comma-separated list. Every parameter is optional.
+func parseSubscriptionsFilter(query url.Values) (*entity.GetSubscriptionsFilter, error) {
+	filter := &entity.GetSubscriptionsFilter{}
+
+	for _, userIDsStr := range query["user_id"] {
+		for _, userIDStr := range strings.Split(userIDsStr, ",") {
+			userID, err := uuid.Parse(strings.TrimSpace(userIDStr))
+			if err != nil {
+				return nil, invalidField("user_id", fmt.Errorf("user id parse failed: %w", err))
+			}
+			filter.UserIDs = append(filter.UserIDs, userID)
+		}
+	}
+
+	for _, serviceName := range query["service_name"] {
+		if serviceName != "" {
+			filter.ServiceNames = append(filter.ServiceNames, serviceName)
+		}
+	}
+
+	var err error
+
+	if startDateStr := query.Get("start_date"); startDateStr != "" {
+		filter.StartDate, err = time.Parse(timeFormat, startDateStr)
+		if err != nil {
+			return nil, invalidField("start_date", fmt.Errorf("start date parse failed: %w", err))
+		}
+	}
+
+	if endDateStr := query.Get("end_date"); endDateStr != "" {
+		filter.EndDate, err = time.Parse(timeFormat, endDateStr)
+		if err != nil {
+			return nil, invalidField("end_date", fmt.Errorf("end date parse failed: %w", err))
+		}
+	}
+
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.StartDate.After(filter.EndDate) {
+		return nil, invalidField("end_date", fmt.Errorf("start date is after end date"))
+	}
+
+	filter.Match, err = parseDateMatch(query.Get("match"))
+	if err != nil {
+		return nil, invalidField("match", err)
+	}
+
+	// active_at looks at a single month, taken from start_date; the range collapses to that month.
+	if filter.Match == entity.DateMatchActiveAt {
+		if filter.StartDate.IsZero() {
+			return nil, invalidField("start_date", fmt.Errorf("active_at match requires start date"))
+		}
+
+		if !filter.EndDate.IsZero() && !filter.EndDate.Equal(filter.StartDate) {
+			return nil, invalidField("end_date", fmt.Errorf("active_at match does not accept a different end date"))
+		}
+
+		filter.EndDate = filter.StartDate
+	}
+
+	for _, statusStr := range query["status"] {
+		status, err := parseSubscriptionStatus(statusStr)
+		if err != nil {
+			return nil, invalidField("status", err)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	filter.PriceMin, err = parsePriceBound(query.Get("price_min"))
+	if err != nil {
+		return nil, invalidField("price_min", fmt.Errorf("price min parse failed: %w", err))
+	}
+
+	filter.PriceMax, err = parsePriceBound(query.Get("price_max"))
+	if err != nil {
+		return nil, invalidField("price_max", fmt.Errorf("price max parse failed: %w", err))
+	}
+
+	if filter.PriceMin != nil && filter.PriceMax != nil && decimalGreater(*filter.PriceMin, *filter.PriceMax) {
+		return nil, invalidField("price_min", fmt.Errorf("price min is greater than price max"))
+	}
+
+	return filter, nil
+}
+
+// decimalGreater tells whether decimal a is greater than decimal b. Both must match decimalRegexp.
+func decimalGreater(a, b string) bool {
+	x, _ := new(big.Rat).SetString(a)
+	y, _ := new(big.Rat).SetString(b)
+
+	return x.Cmp(y) > 0
+}
+
+func parsePriceBound(priceStr string) (*string, error) {
+	if priceStr == "" {
+		return nil, nil
+	}
+
+	if !decimalRegexp.MatchString(priceStr) {
+		return nil, fmt.Errorf("price must be a non-negative decimal number")
+	}
+
+	return &priceStr, nil
+}
+
+// parsePrice parses a subscription price. The currency defaults to defaultCurrency; negative amounts are rejected.
+func parsePrice(amount amountDTO, currencyStr string) (entity.Money, error) {
+	currency, err := parseOptionalCurrency(currencyStr, defaultCurrency)
+	if err != nil {
+		return entity.Money{}, invalidField("currency", err)
+	}
+
+	price, err := entity.ParseMoney(string(amount), currency)
+	if err != nil {
+		return entity.Money{}, invalidField("price", fmt.Errorf("price parse failed: %w", err))
+	}
+
+	if price.IsNegative() {
+		return entity.Money{}, invalidField("price", fmt.Errorf("price is negative"))
+	}
+
+	return price, nil
+}
+
+// defaultListStatuses are listed when the request does not ask for particular statuses. Cancelled subscriptions
+// are kept for history and only listed on request.
+var defaultListStatuses = []entity.SubscriptionStatus{
+	entity.SubscriptionStatusTrial,
+	entity.SubscriptionStatusActive,
+	entity.SubscriptionStatusPaused,
+	entity.SubscriptionStatusExpired,
+}
+
+func parseSubscriptionStatus(statusStr string) (entity.SubscriptionStatus, error) {
+	switch status := entity.SubscriptionStatus(statusStr); status {
+	case entity.SubscriptionStatusTrial, entity.SubscriptionStatusActive, entity.SubscriptionStatusPaused,
+		entity.SubscriptionStatusCancelled, entity.SubscriptionStatusExpired:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown status %q", statusStr)
+	}
+}
+
+// parseInitialStatus parses the status a subscription is created in, defaulting to active.
+func parseInitialStatus(statusStr string) (entity.SubscriptionStatus, error) {
+	switch status := entity.SubscriptionStatus(statusStr); status {
+	case "":
+		return entity.SubscriptionStatusActive, nil
+	case entity.SubscriptionStatusTrial, entity.SubscriptionStatusActive:
+		return status, nil
+	default:
+		return "", fmt.Errorf("subscription cannot be created with status %q", statusStr)
+	}
+}
+
+// anonymousActor is recorded for changes made by clients that do not identify themselves.
+const anonymousActor = "anonymous"
+
+// actorFromRequest returns who makes the request, as recorded in the subscription history: the subject of an
+// authenticated request, otherwise the X-Actor header.
+func actorFromRequest(r *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		return identity.Subject
+	}
+
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		return anonymousActor
+	}
+
+	return actor
+}
+
+// parseOptionalBool parses a boolean query parameter; an empty string is false.
+func parseOptionalBool(boolStr string) (bool, error) {
+	if boolStr == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(boolStr)
+}
+
+func parseDateMatch(matchStr string) (entity.DateMatch, error) {
+	switch match := entity.DateMatch(matchStr); match {
+	case "":
+		return entity.DateMatchContained, nil
+	case entity.DateMatchContained, entity.DateMatchOverlaps, entity.DateMatchActiveAt:
+		return match, nil
+	default:
+		return "", fmt.Errorf("unknown match %q", matchStr)
+	}
+}
+
+func parseTotalMode(modeStr string) (entity.TotalMode, error) {
+	switch mode := entity.TotalMode(modeStr); mode {
+	case "":
+		return entity.TotalModeSum, nil
+	case entity.TotalModeSum, entity.TotalModeMonthlyAccrual:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q", modeStr)
+	}
+}
+
+// Exchange rates are stored as numeric(20, 10): at most 10 digits on either side of the decimal point.
+const (
+	maxExchangeRateIntegerDigits  = 10
+	maxExchangeRateFractionDigits = 10
+)
+
+func parseExchangeRate(baseStr, quoteStr, effectiveFromStr, rateStr string) (entity.ExchangeRate, error) {
+	base, err := parseCurrency(baseStr)
+	if err != nil {
+		return entity.ExchangeRate{}, invalidField("base_currency", err)
+	}
+
+	quote, err := parseCurrency(quoteStr)
+	if err != nil {
+		return entity.ExchangeRate{}, invalidField("quote_currency", err)
+	}
+
+	if base == quote {
+		return entity.ExchangeRate{}, invalidField("quote_currency", fmt.Errorf("base and quote currencies are equal"))
+	}
+
+	effectiveFrom, err := time.Parse(timeFormat, effectiveFromStr)
+	if err != nil {
+		return entity.ExchangeRate{}, invalidField("effective_from", fmt.Errorf("effective from parse failed: %w", err))
+	}
+
+	rate := strings.TrimSpace(rateStr)
+	if !decimalRegexp.MatchString(rate) || strings.Trim(rate, "0.") == "" {
+		return entity.ExchangeRate{}, invalidField("rate", fmt.Errorf("rate must be a positive decimal number"))
+	}
+
+	integer, fraction, _ := strings.Cut(rate, ".")
+	if len(strings.TrimLeft(integer, "0")) > maxExchangeRateIntegerDigits {
+		return entity.ExchangeRate{}, invalidField("rate", fmt.Errorf("rate must have at most %d integer digits", maxExchangeRateIntegerDigits))
+	}
+	if len(fraction) > maxExchangeRateFractionDigits {
+		return entity.ExchangeRate{}, invalidField("rate", fmt.Errorf("rate must have at most %d fraction digits", maxExchangeRateFractionDigits))
+	}
+
+	return entity.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		EffectiveFrom: effectiveFrom,
+		Rate:          rate,
+	}, nil
+}
+
+func parseCreateSubscription(req *createSubscriptionRequestDTO) (*entity.CreateSubscriptionData, error) {
+	var (
+		data   entity.CreateSubscriptionData
+		parser = newFieldParser(validate(req))
+	)
+
+	parser.parse(func() (err error) {
+		data.UserID, err = uuid.Parse(req.UserID)
+		if err != nil {
+			return invalidField("user_id", fmt.Errorf("user id parse failed: %w", err))
+		}
+		return nil
+	}, "user_id")
+
+	parser.parse(func() (err error) {
+		data.StartDate, data.EndDate, err = parseSubscriptionPeriod(req.StartDate, req.EndDate)
+		return err
+	}, "start_date", "end_date")
+
+	parser.parse(func() (err error) {
+		data.Price, err = parsePrice(req.Price, req.Currency)
+		return err
+	}, "price", "currency")
+
+	parser.parse(func() (err error) {
+		data.BillingPeriod, data.BillingInterval, err = parseBilling(req.BillingPeriod, req.BillingInterval)
+		return err
+	}, "billing_period", "billing_interval")
+
+	parser.parse(func() (err error) {
+		data.Status, err = parseInitialStatus(req.Status)
+		if err != nil {
+			return invalidField("status", err)
+		}
+		return nil
+	}, "status")
+
+	err := parser.err()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ServiceName = req.ServiceName
+
+	return &data, nil
+}
+
+func parseUpdateSubscription(req *updateSubscriptionCreateDTO) (*entity.UpdateSubscriptionData, error) {
+	var (
+		data   entity.UpdateSubscriptionData
+		parser = newFieldParser(validate(req))
+	)
+
+	parser.parse(func() (err error) {
+		data.StartDate, data.EndDate, err = parseSubscriptionPeriod(req.StartDate, req.EndDate)
+		return err
+	}, "start_date", "end_date")
+
+	parser.parse(func() (err error) {
+		data.Price, err = parsePrice(req.Price, req.Currency)
+		return err
+	}, "price", "currency")
+
+	parser.parse(func() (err error) {
+		data.BillingPeriod, data.BillingInterval, err = parseBilling(req.BillingPeriod, req.BillingInterval)
+		return err
+	}, "billing_period", "billing_interval")
+
+	err := parser.err()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ServiceName = req.ServiceName
+
+	return &data, nil
+}
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header; UUIDs and similar keys are far shorter.
+const maxIdempotencyKeyLength = 255
+
+// parseIdempotencyKey validates the Idempotency-Key header. An empty key means the request is not idempotent.
+func parseIdempotencyKey(key string) (string, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return "", fmt.Errorf("idempotency key contains a non-printable or non-ASCII character")
+		}
+	}
+
+	return key, nil
+}
+
+// requiredQueryParam fails if the query parameter name is missing or empty.
+func requiredQueryParam(query url.Values, name string) error {
+	if query.Get(name) == "" {
+		return invalidField(name, fmt.Errorf("is required"))
+	}
+
+	return nil
+}
@@ -0,0 +1,629 @@
+package controller
+
+import (
+	"encoding/base64"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// assertFieldError fails unless err reports field as invalid.
+func assertFieldError(t *testing.T, err error, field string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("got no error, want %s to be invalid", field)
+	}
+
+	for _, fe := range fieldErrors(err) {
+		if fe.field == field {
+			return
+		}
+	}
+
+	t.Fatalf("error = %v, want %s to be invalid", err, field)
+}
+
+func TestParseSubscriptionsFilterPriceRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantField string
+	}{
+		{name: "min below max", query: "price_min=9.5&price_max=10"},
+		{name: "equal bounds", query: "price_min=10&price_max=10.00"},
+		{name: "min only", query: "price_min=10"},
+		{name: "max only", query: "price_max=10"},
+		{name: "inverted", query: "price_min=10&price_max=9.99", wantField: "price_min"},
+		{name: "inverted by a fraction", query: "price_min=10.001&price_max=10", wantField: "price_min"},
+		{name: "longer number is not greater", query: "price_min=9.999&price_max=10"},
+		{name: "invalid min", query: "price_min=abc", wantField: "price_min"},
+		{name: "negative max", query: "price_max=-1", wantField: "price_max"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+
+			_, err = parseSubscriptionsFilter(query)
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("parseSubscriptionsFilter() error = %v", err)
+				}
+				return
+			}
+			assertFieldError(t, err, tt.wantField)
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &entity.Cursor{
+		Sort:  entity.Sort{Field: entity.SortByPrice, Order: entity.SortDesc},
+		Value: "999",
+		ID:    uuid.New(),
+	}
+
+	encoded := encodeCursor(cursor)
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Fatalf("encodeCursor() = %q, want it URL safe and unpadded", encoded)
+	}
+
+	decoded, err := decodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if *decoded != *cursor {
+		t.Fatalf("decodeCursor() = %+v, want %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded", cursor: base64.URLEncoding.EncodeToString([]byte(`{"id":"x"}`))},
+		{name: "not JSON", cursor: base64.RawURLEncoding.EncodeToString([]byte("start_date"))},
+		{name: "invalid id", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"f":"price","o":"asc","v":"1","id":"x"}`))},
+		{name: "missing id", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"f":"price","o":"asc","v":"1"}`))},
+		{name: "unknown field", cursor: rawCursor("created_at", "2025-07-01T00:00:00Z")},
+		{name: "price not an integer", cursor: rawCursor("price", "9.99")},
+		{name: "price overflows", cursor: rawCursor("price", "99999999999999999999")},
+		{name: "start date not a time", cursor: rawCursor("start_date", "07-2025")},
+		{name: "start date infinity", cursor: rawCursor("start_date", "infinity")},
+		{name: "end date not a time", cursor: rawCursor("end_date", "'; DROP TABLE")},
+		{name: "service name with NUL", cursor: rawCursor("service_name", "a\u0000b")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.cursor)
+			if err == nil {
+				t.Fatalf("decodeCursor(%q) error = nil, want an error", tt.cursor)
+			}
+			if problem := newProblem(err); problem.Status != http.StatusBadRequest || problem.Code != codeInvalidCursor {
+				t.Fatalf("newProblem() = %d %q, want 400 %q", problem.Status, problem.Code, codeInvalidCursor)
+			}
+		})
+	}
+}
+
+// rawCursor encodes a cursor with the given sort field and value, as a client could forge it.
+func rawCursor(field, value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"f":"` + field + `","o":"asc","v":"` + value + `","id":"` + uuid.NewString() + `"}`))
+}
+
+func TestDecodeCursorValues(t *testing.T) {
+	tests := []struct {
+		field string
+		value string
+	}{
+		{field: "start_date", value: "2025-07-01T00:00:00Z"},
+		{field: "end_date", value: "2025-12-01T00:00:00.5+03:00"},
+		{field: "end_date", value: "infinity"},
+		{field: "price", value: "-100"},
+		{field: "service_name", value: "Yandex Plus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.value, func(t *testing.T) {
+			cursor, err := decodeCursor(rawCursor(tt.field, tt.value))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if cursor.Value != tt.value {
+				t.Fatalf("value = %q, want %q", cursor.Value, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    entity.Sort
+		wantErr bool
+	}{
+		{sort: "", want: entity.Sort{Field: entity.SortByStartDate, Order: entity.SortAsc}},
+		{sort: "price", want: entity.Sort{Field: entity.SortByPrice, Order: entity.SortAsc}},
+		{sort: "end_date:desc", want: entity.Sort{Field: entity.SortByEndDate, Order: entity.SortDesc}},
+		{sort: "service_name:asc", want: entity.Sort{Field: entity.SortByServiceName, Order: entity.SortAsc}},
+		{sort: "user_id", wantErr: true},
+		{sort: "price:down", wantErr: true},
+		{sort: "price:", wantErr: true},
+		{sort: "PRICE", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := parseSort(tt.sort)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSort(%q) error = %v, wantErr %v", tt.sort, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseSort(%q) = %+v, want %+v", tt.sort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		limit   string
+		want    int
+		wantErr bool
+	}{
+		{limit: "", want: defaultPageLimit},
+		{limit: "1", want: 1},
+		{limit: "1000", want: maxPageLimit},
+		{limit: "0", wantErr: true},
+		{limit: "1001", wantErr: true},
+		{limit: "-5", wantErr: true},
+		{limit: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			got, err := parseLimit(tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLimit(%q) error = %v, wantErr %v", tt.limit, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseLimit(%q) = %d, want %d", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionsFilter(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	query := url.Values{
+		"user_id":      {alice.String(), bob.String() + ", " + carol.String()},
+		"service_name": {"Netflix", "", "Yandex Plus"},
+		"status":       {"active", "paused"},
+	}
+
+	filter, err := parseSubscriptionsFilter(query)
+	if err != nil {
+		t.Fatalf("parseSubscriptionsFilter() error = %v", err)
+	}
+
+	if !slices.Equal(filter.UserIDs, []uuid.UUID{alice, bob, carol}) {
+		t.Errorf("UserIDs = %v, want %v", filter.UserIDs, []uuid.UUID{alice, bob, carol})
+	}
+	if !slices.Equal(filter.ServiceNames, []string{"Netflix", "Yandex Plus"}) {
+		t.Errorf("ServiceNames = %v, want the non-empty names", filter.ServiceNames)
+	}
+	if !slices.Equal(filter.Statuses, []entity.SubscriptionStatus{entity.SubscriptionStatusActive, entity.SubscriptionStatusPaused}) {
+		t.Errorf("Statuses = %v, want active and paused", filter.Statuses)
+	}
+	if filter.Match != entity.DateMatchContained {
+		t.Errorf("Match = %q, want %q", filter.Match, entity.DateMatchContained)
+	}
+	if filter.OwnerID != nil {
+		t.Errorf("OwnerID = %v, want it left to the service", *filter.OwnerID)
+	}
+}
+
+func TestParseSubscriptionsFilterEmpty(t *testing.T) {
+	filter, err := parseSubscriptionsFilter(url.Values{})
+	if err != nil {
+		t.Fatalf("parseSubscriptionsFilter() error = %v", err)
+	}
+
+	if len(filter.UserIDs) > 0 || len(filter.ServiceNames) > 0 || len(filter.Statuses) > 0 ||
+		!filter.StartDate.IsZero() || !filter.EndDate.IsZero() || filter.PriceMin != nil || filter.PriceMax != nil {
+		t.Fatalf("parseSubscriptionsFilter() = %+v, want no conditions", filter)
+	}
+}
+
+func TestParseSubscriptionsFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantField string
+	}{
+		{name: "invalid user id", query: "user_id=42", wantField: "user_id"},
+		{name: "empty user id in list", query: "user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba,", wantField: "user_id"},
+		{name: "unknown status", query: "status=deleted", wantField: "status"},
+		{name: "invalid start date", query: "start_date=2025-07", wantField: "start_date"},
+		{name: "invalid end date", query: "end_date=13-2025", wantField: "end_date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+
+			_, err = parseSubscriptionsFilter(query)
+			assertFieldError(t, err, tt.wantField)
+		})
+	}
+}
+
+func TestParseDateMatch(t *testing.T) {
+	tests := []struct {
+		match   string
+		want    entity.DateMatch
+		wantErr bool
+	}{
+		{match: "", want: entity.DateMatchContained},
+		{match: "contained", want: entity.DateMatchContained},
+		{match: "overlaps", want: entity.DateMatchOverlaps},
+		{match: "active_at", want: entity.DateMatchActiveAt},
+		{match: "Overlaps", wantErr: true},
+		{match: "within", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.match, func(t *testing.T) {
+			got, err := parseDateMatch(tt.match)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDateMatch(%q) error = %v, wantErr %v", tt.match, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseDateMatch(%q) = %q, want %q", tt.match, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionsFilterDates(t *testing.T) {
+	july := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantMatch entity.DateMatch
+		wantStart time.Time
+		wantEnd   time.Time
+		wantField string
+	}{
+		{
+			name:      "contained range",
+			query:     "start_date=07-2025&end_date=12-2025",
+			wantMatch: entity.DateMatchContained,
+			wantStart: july,
+			wantEnd:   december,
+		},
+		{
+			name:      "overlapping range",
+			query:     "start_date=07-2025&end_date=12-2025&match=overlaps",
+			wantMatch: entity.DateMatchOverlaps,
+			wantStart: july,
+			wantEnd:   december,
+		},
+		{
+			name:      "open range",
+			query:     "end_date=12-2025&match=overlaps",
+			wantMatch: entity.DateMatchOverlaps,
+			wantEnd:   december,
+		},
+		{
+			name:      "active_at collapses to the month",
+			query:     "start_date=07-2025&match=active_at",
+			wantMatch: entity.DateMatchActiveAt,
+			wantStart: july,
+			wantEnd:   july,
+		},
+		{
+			name:      "active_at with the same end date",
+			query:     "start_date=07-2025&end_date=07-2025&match=active_at",
+			wantMatch: entity.DateMatchActiveAt,
+			wantStart: july,
+			wantEnd:   july,
+		},
+		{name: "start after end", query: "start_date=12-2025&end_date=07-2025", wantField: "end_date"},
+		{name: "unknown match", query: "match=during", wantField: "match"},
+		{name: "active_at without start date", query: "match=active_at", wantField: "start_date"},
+		{name: "active_at with another end date", query: "start_date=07-2025&end_date=12-2025&match=active_at", wantField: "end_date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+
+			filter, err := parseSubscriptionsFilter(query)
+			if tt.wantField != "" {
+				assertFieldError(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSubscriptionsFilter() error = %v", err)
+			}
+
+			if filter.Match != tt.wantMatch || !filter.StartDate.Equal(tt.wantStart) || !filter.EndDate.Equal(tt.wantEnd) {
+				t.Fatalf("match, start, end = %q, %v, %v, want %q, %v, %v",
+					filter.Match, filter.StartDate, filter.EndDate, tt.wantMatch, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestParseTotalMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    entity.TotalMode
+		wantErr bool
+	}{
+		{mode: "", want: entity.TotalModeSum},
+		{mode: "sum", want: entity.TotalModeSum},
+		{mode: "monthly_accrual", want: entity.TotalModeMonthlyAccrual},
+		{mode: "average", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got, err := parseTotalMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTotalMode(%q) error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseTotalMode(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionPeriod(t *testing.T) {
+	july := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		start     string
+		end       string
+		wantEnd   *time.Time
+		wantField string
+	}{
+		{name: "open-ended", start: "07-2025"},
+		{name: "closed", start: "07-2025", end: "12-2025", wantEnd: &december},
+		{name: "single month", start: "07-2025", end: "07-2025", wantEnd: &july},
+		{name: "end before start", start: "12-2025", end: "07-2025", wantField: "end_date"},
+		{name: "invalid start of an open-ended period", start: "2025-07", wantField: "start_date"},
+		{name: "invalid end", start: "07-2025", end: "2025-12", wantField: "end_date"},
+		{name: "missing start", end: "12-2025", wantField: "start_date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseSubscriptionPeriod(tt.start, tt.end)
+			if tt.wantField != "" {
+				assertFieldError(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSubscriptionPeriod() error = %v", err)
+			}
+
+			if !start.Equal(july) {
+				t.Errorf("start = %v, want %v", start, july)
+			}
+			if (end == nil) != (tt.wantEnd == nil) || end != nil && !end.Equal(*tt.wantEnd) {
+				t.Errorf("end = %v, want %v", end, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestParseBilling(t *testing.T) {
+	tests := []struct {
+		name         string
+		period       string
+		interval     int
+		wantPeriod   entity.BillingPeriod
+		wantInterval int32
+		wantField    string
+	}{
+		{name: "defaults", wantPeriod: entity.BillingPeriodMonthly, wantInterval: 1},
+		{name: "weekly", period: "weekly", wantPeriod: entity.BillingPeriodWeekly, wantInterval: 1},
+		{name: "every two quarters", period: "quarterly", interval: 2, wantPeriod: entity.BillingPeriodQuarterly, wantInterval: 2},
+		{name: "yearly", period: "yearly", interval: 1, wantPeriod: entity.BillingPeriodYearly, wantInterval: 1},
+		{name: "interval without period", interval: 6, wantPeriod: entity.BillingPeriodMonthly, wantInterval: 6},
+		{name: "unknown period", period: "daily", wantField: "billing_period"},
+		{name: "upper case period", period: "Monthly", wantField: "billing_period"},
+		{name: "negative interval", period: "monthly", interval: -1, wantField: "billing_interval"},
+		{name: "interval overflows int32", period: "monthly", interval: math.MaxInt32 + 1, wantField: "billing_interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, interval, err := parseBilling(tt.period, tt.interval)
+			if tt.wantField != "" {
+				assertFieldError(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBilling() error = %v", err)
+			}
+			if period != tt.wantPeriod || interval != tt.wantInterval {
+				t.Fatalf("parseBilling() = %q, %d, want %q, %d", period, interval, tt.wantPeriod, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     string
+		wantErr  bool
+	}{
+		{currency: "USD", want: "USD"},
+		{currency: "eur", want: "EUR"},
+		{currency: " rub ", want: "RUB"},
+		{currency: "", wantErr: true},
+		{currency: "US", wantErr: true},
+		{currency: "USDT", wantErr: true},
+		{currency: "U5D", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			got, err := parseCurrency(tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCurrency(%q) error = %v, wantErr %v", tt.currency, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseCurrency(%q) = %q, want %q", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOptionalCurrency(t *testing.T) {
+	if got, err := parseOptionalCurrency("", defaultCurrency); err != nil || got != defaultCurrency {
+		t.Fatalf("parseOptionalCurrency(\"\") = %q, %v, want the fallback %q", got, err, defaultCurrency)
+	}
+	if got, err := parseOptionalCurrency("usd", defaultCurrency); err != nil || got != "USD" {
+		t.Fatalf("parseOptionalCurrency(\"usd\") = %q, %v, want USD", got, err)
+	}
+	if _, err := parseOptionalCurrency("dollars", defaultCurrency); err == nil {
+		t.Fatal("parseOptionalCurrency(\"dollars\") error = nil, want an error")
+	}
+}
+
+func TestParseExchangeRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		quote     string
+		from      string
+		rate      string
+		wantField string
+	}{
+		{name: "valid", base: "usd", quote: "RUB", from: "07-2025", rate: "81.25"},
+		{name: "rate with spaces", base: "EUR", quote: "USD", from: "07-2025", rate: " 1.0825 "},
+		{name: "invalid base", base: "US", quote: "RUB", from: "07-2025", rate: "81", wantField: "base_currency"},
+		{name: "invalid quote", base: "USD", quote: "", from: "07-2025", rate: "81", wantField: "quote_currency"},
+		{name: "same currencies", base: "USD", quote: "usd", from: "07-2025", rate: "1", wantField: "quote_currency"},
+		{name: "invalid month", base: "USD", quote: "RUB", from: "2025-07", rate: "81", wantField: "effective_from"},
+		{name: "zero rate", base: "USD", quote: "RUB", from: "07-2025", rate: "0.000", wantField: "rate"},
+		{name: "negative rate", base: "USD", quote: "RUB", from: "07-2025", rate: "-81", wantField: "rate"},
+		{name: "not a number", base: "USD", quote: "RUB", from: "07-2025", rate: "1e3", wantField: "rate"},
+		{name: "largest rate", base: "USD", quote: "VND", from: "07-2025", rate: "9999999999.9999999999"},
+		{name: "leading zeros", base: "USD", quote: "VND", from: "07-2025", rate: "0009999999999"},
+		{name: "smallest rate", base: "VND", quote: "USD", from: "07-2025", rate: "0.0000000001"},
+		{name: "too many integer digits", base: "USD", quote: "VND", from: "07-2025", rate: "10000000000", wantField: "rate"},
+		{name: "too many fraction digits", base: "VND", quote: "USD", from: "07-2025", rate: "0.00000000001", wantField: "rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := parseExchangeRate(tt.base, tt.quote, tt.from, tt.rate)
+			if tt.wantField != "" {
+				assertFieldError(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExchangeRate() error = %v", err)
+			}
+
+			if rate.BaseCurrency != strings.ToUpper(tt.base) || rate.Rate != strings.TrimSpace(tt.rate) {
+				t.Fatalf("parseExchangeRate() = %+v, want normalised currencies and rate", rate)
+			}
+			if !rate.EffectiveFrom.Equal(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Fatalf("EffectiveFrom = %v, want July 2025", rate.EffectiveFrom)
+			}
+		})
+	}
+}
+
+func TestParseIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "empty", key: ""},
+		{name: "uuid", key: "8e03978e-40d5-43e8-bc93-6894a57f9324"},
+		{name: "printable ASCII", key: "order-42/retry:1~"},
+		{name: "longest", key: strings.Repeat("k", maxIdempotencyKeyLength)},
+		{name: "too long", key: strings.Repeat("k", maxIdempotencyKeyLength+1), wantErr: true},
+		{name: "space", key: "order 42", wantErr: true},
+		{name: "control character", key: "order\t42", wantErr: true},
+		{name: "non-ASCII", key: "заказ-42", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIdempotencyKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIdempotencyKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.key {
+				t.Fatalf("parseIdempotencyKey() = %q, want %q", got, tt.key)
+			}
+		})
+	}
+}
+
+func TestActorFromRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		identity *auth.Identity
+		want     string
+	}{
+		{name: "header", header: " ops@example.com ", want: "ops@example.com"},
+		{name: "no header", want: anonymousActor},
+		{name: "token subject wins", header: "someone-else", identity: &auth.Identity{Subject: "alice"}, want: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/subscriptions", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Actor", tt.header)
+			}
+			if tt.identity != nil {
+				r = r.WithContext(auth.WithIdentity(r.Context(), tt.identity))
+			}
+
+			if got := actorFromRequest(r); got != tt.want {
+				t.Fatalf("actorFromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
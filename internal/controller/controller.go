@@ -2,26 +2,56 @@ package controller
 
 import (
 	"context"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
 	"github.com/BernsteinMondy/subscription-service/internal/entity"
 	"github.com/google/uuid"
+	"net/http"
+	"time"
 )
 
 type service interface {
-	GetAllSubscriptions(ctx context.Context) ([]entity.Subscription, error)
-	GetSubscriptionsTotalSumFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter) (int32, error)
+	ListSubscriptions(ctx context.Context, params *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error)
+	ExportSubscriptions(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) error
+	GetSubscriptionsTotalSumFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter, mode entity.TotalMode, targetCurrency string) (*entity.Money, error)
 	GetSubscription(ctx context.Context, id uuid.UUID) (*entity.Subscription, error)
+	GetMonthlyReport(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) (*entity.MonthlyReport, error)
+	GetSubscriptionHistory(ctx context.Context, id uuid.UUID) ([]entity.SubscriptionEvent, error)
 
-	CancelSubscription(ctx context.Context, id uuid.UUID) error
-	NewSubscription(ctx context.Context, data *entity.CreateSubscriptionData) (uuid.UUID, error)
-	UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData) error
+	ActivateSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error)
+	PauseSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error)
+	ResumeSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error)
+	CancelSubscription(ctx context.Context, id uuid.UUID, atPeriodEnd bool, actor string, ifMatch []int64) (int64, error)
+	ExpireSubscriptions(ctx context.Context, actor string) (int64, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID, ifMatch []int64, actor string) error
+	NewSubscription(ctx context.Context, data *entity.CreateSubscriptionData, idempotencyKey, actor string) (uuid.UUID, error)
+	UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, actor string) (int64, error)
+	NewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData, actor string) ([]uuid.UUID, error)
+	ValidateNewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData) error
+	UpdateSubscriptions(ctx context.Context, updates []entity.SubscriptionUpdate, mode entity.BatchMode, actor string) ([]entity.BatchItemResult, error)
+	CancelSubscriptions(ctx context.Context, ids []uuid.UUID, atPeriodEnd bool, mode entity.BatchMode, actor string) ([]entity.BatchItemResult, error)
+	SchedulePriceChange(ctx context.Context, id uuid.UUID, amount string, effectiveFrom time.Time, actor string, ifMatch []int64) (int64, error)
+
+	GetExchangeRates(ctx context.Context, filter *entity.GetExchangeRatesFilter) ([]entity.ExchangeRate, error)
+	SetExchangeRates(ctx context.Context, rates []entity.ExchangeRate) error
+	DeleteExchangeRate(ctx context.Context, rate *entity.ExchangeRate) error
+
+	GetRoleAssignments(ctx context.Context, subject string) ([]entity.RoleAssignment, error)
+	AssignRole(ctx context.Context, subject, role, actor string) (bool, error)
+	RevokeRole(ctx context.Context, subject, role string) error
+}
+
+type authorizer interface {
+	Require(permission auth.Permission, next http.Handler) http.Handler
 }
 
 type controller struct {
-	service service
+	service    service
+	authorizer authorizer
 }
 
-func New(srvc service) *controller {
+func New(srvc service, authz authorizer) *controller {
 	return &controller{
-		service: srvc,
+		service:    srvc,
+		authorizer: authz,
 	}
 }
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeService implements the service methods a test sets; calling any other one panics.
+type fakeService struct {
+	service
+
+	getSubscription    func(ctx context.Context, id uuid.UUID) (*entity.Subscription, error)
+	listSubscriptions  func(ctx context.Context, params *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error)
+	updateSubscription func(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, actor string) (int64, error)
+	cancelSubscription func(ctx context.Context, id uuid.UUID, atPeriodEnd bool, actor string, ifMatch []int64) (int64, error)
+	deleteSubscription func(ctx context.Context, id uuid.UUID, ifMatch []int64, actor string) error
+	pauseSubscription  func(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error)
+
+	schedulePriceChange func(ctx context.Context, id uuid.UUID, amount string, effectiveFrom time.Time, actor string, ifMatch []int64) (int64, error)
+
+	newSubscription          func(ctx context.Context, data *entity.CreateSubscriptionData, idempotencyKey, actor string) (uuid.UUID, error)
+	newSubscriptions         func(ctx context.Context, data []*entity.CreateSubscriptionData, actor string) ([]uuid.UUID, error)
+	validateNewSubscriptions func(ctx context.Context, data []*entity.CreateSubscriptionData) error
+
+	exportSubscriptions func(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) error
+
+	getSubscriptionsTotalSumFilter func(ctx context.Context, filter *entity.GetSubscriptionsFilter, mode entity.TotalMode, targetCurrency string) (*entity.Money, error)
+	getMonthlyReport               func(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) (*entity.MonthlyReport, error)
+
+	setExchangeRates func(ctx context.Context, rates []entity.ExchangeRate) error
+}
+
+func (f *fakeService) SchedulePriceChange(ctx context.Context, id uuid.UUID, amount string, effectiveFrom time.Time, actor string, ifMatch []int64) (int64, error) {
+	return f.schedulePriceChange(ctx, id, amount, effectiveFrom, actor, ifMatch)
+}
+
+func (f *fakeService) SetExchangeRates(ctx context.Context, rates []entity.ExchangeRate) error {
+	return f.setExchangeRates(ctx, rates)
+}
+
+func (f *fakeService) GetMonthlyReport(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) (*entity.MonthlyReport, error) {
+	return f.getMonthlyReport(ctx, filter, targetCurrency)
+}
+
+func (f *fakeService) GetSubscriptionsTotalSumFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter, mode entity.TotalMode, targetCurrency string) (*entity.Money, error) {
+	return f.getSubscriptionsTotalSumFilter(ctx, filter, mode, targetCurrency)
+}
+
+func (f *fakeService) ExportSubscriptions(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) error {
+	return f.exportSubscriptions(ctx, filter, sort, fn)
+}
+
+func (f *fakeService) NewSubscription(ctx context.Context, data *entity.CreateSubscriptionData, idempotencyKey, actor string) (uuid.UUID, error) {
+	return f.newSubscription(ctx, data, idempotencyKey, actor)
+}
+
+func (f *fakeService) NewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData, actor string) ([]uuid.UUID, error) {
+	return f.newSubscriptions(ctx, data, actor)
+}
+
+func (f *fakeService) ValidateNewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData) error {
+	return f.validateNewSubscriptions(ctx, data)
+}
+
+func (f *fakeService) ListSubscriptions(ctx context.Context, params *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error) {
+	return f.listSubscriptions(ctx, params)
+}
+
+func (f *fakeService) CancelSubscription(ctx context.Context, id uuid.UUID, atPeriodEnd bool, actor string, ifMatch []int64) (int64, error) {
+	return f.cancelSubscription(ctx, id, atPeriodEnd, actor, ifMatch)
+}
+
+func (f *fakeService) PauseSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error) {
+	return f.pauseSubscription(ctx, id, actor, ifMatch)
+}
+
+func (f *fakeService) DeleteSubscription(ctx context.Context, id uuid.UUID, ifMatch []int64, actor string) error {
+	return f.deleteSubscription(ctx, id, ifMatch, actor)
+}
+
+func (f *fakeService) GetSubscription(ctx context.Context, id uuid.UUID) (*entity.Subscription, error) {
+	return f.getSubscription(ctx, id)
+}
+
+func (f *fakeService) UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, actor string) (int64, error) {
+	return f.updateSubscription(ctx, id, data, ifMatch, actor)
+}
+
+// allowAll lets every request through, as the authorizer does with authentication disabled.
+type allowAll struct{}
+
+func (allowAll) Require(_ auth.Permission, next http.Handler) http.Handler {
+	return next
+}
+
+// serve runs r through the routes of a controller backed by srvc.
+func serve(t *testing.T, srvc service, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	New(srvc, allowAll{}).MapHandlers(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	return w
+}
@@ -1,11 +1,49 @@
 package controller
 
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"strconv"
+	"time"
+)
+
+// amountDTO is a decimal amount in major units. It is accepted both as a JSON string ("10.99") and as a JSON
+// number (10.99) so that clients sending whole numbers keep working. Other JSON values are kept as their JSON
+// text, which validation rejects as not a decimal, so that they are reported against the field.
+type amountDTO string
+
+func (a *amountDTO) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = amountDTO(s)
+		return nil
+	}
+
+	if bytes.Equal(data, []byte("null")) {
+		*a = ""
+		return nil
+	}
+
+	*a = amountDTO(data)
+	return nil
+}
+
+// createSubscriptionRequestDTO is validated by validate: service_name is trimmed, its inner whitespace collapsed,
+// and the currency upper-cased before the checks.
 type createSubscriptionRequestDTO struct {
-	UserID      string `json:"user_id" example:"a6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
-	ServiceName string `json:"service_name" example:"Yandex Plus"`
-	Price       int    `json:"price" example:"1000"`
-	StartDate   string `json:"start_date" example:"08-2025"`
-	EndDate     string `json:"end_date" example:"09-2025"`
+	UserID          string    `json:"user_id" validate:"trim,required,uuid" example:"a6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	ServiceName     string    `json:"service_name" validate:"trim,single_space,required,max=255" maxLength:"255" example:"Yandex Plus"`
+	Price           amountDTO `json:"price" validate:"trim,required,decimal" swaggertype:"string" example:"1000.00"`
+	Currency        string    `json:"currency,omitempty" validate:"trim,upper,currency" example:"RUB"`
+	BillingPeriod   string    `json:"billing_period,omitempty" validate:"oneof=weekly monthly quarterly yearly" enums:"weekly,monthly,quarterly,yearly" example:"monthly"`
+	BillingInterval int       `json:"billing_interval,omitempty" validate:"min=0,max=1000" minimum:"0" maximum:"1000" example:"1"`
+	StartDate       string    `json:"start_date" validate:"trim,required,month" example:"08-2025"`
+	EndDate         string    `json:"end_date,omitempty" validate:"trim,month" example:"09-2025"`
+	Status          string    `json:"status,omitempty" validate:"oneof=trial active" enums:"trial,active" example:"active"`
 }
 
 type createSubscriptionResponseDTO struct {
@@ -13,25 +51,303 @@ type createSubscriptionResponseDTO struct {
 }
 
 type getTotalPriceResponseDTO struct {
-	TotalPrice int `json:"total_price" example:"4600"`
+	TotalPrice string `json:"total_price" example:"4600.00"`
+	Currency   string `json:"currency,omitempty" example:"RUB"`
 }
 
 type getSubscriptionReadDTO struct {
-	ID          string `json:"id" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
-	UserID      string `json:"user_id" example:"a6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
-	ServiceName string `json:"service_name" example:"Yandex Plus"`
-	Price       int    `json:"price" example:"1000"`
-	StartDate   string `json:"start_date" example:"08-2025"`
-	EndDate     string `json:"end_date" example:"09-2025"`
+	ID              string  `json:"id" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	UserID          string  `json:"user_id" example:"a6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	ServiceName     string  `json:"service_name" example:"Yandex Plus"`
+	Price           string  `json:"price" example:"1000.00"`
+	Currency        string  `json:"currency" example:"RUB"`
+	BillingPeriod   string  `json:"billing_period" example:"monthly"`
+	BillingInterval int     `json:"billing_interval" example:"1"`
+	StartDate       string  `json:"start_date" example:"08-2025"`
+	EndDate         *string `json:"end_date" example:"09-2025"`
+	Status          string  `json:"status" example:"active" enums:"trial,active,paused,cancelled,expired"`
+	CancelledAt     *string `json:"cancelled_at,omitempty" example:"2025-09-14T10:00:00Z"`
+	// Prices lists every price the subscription has had or is scheduled to have, starting with the price from
+	// start_date.
+	Prices  []pricePeriodDTO `json:"prices"`
+	Version int64            `json:"version" example:"1"`
+}
+
+type pricePeriodDTO struct {
+	Price         string `json:"price" example:"1000.00"`
+	EffectiveFrom string `json:"effective_from" example:"08-2025"`
+}
+
+type schedulePriceChangeRequestDTO struct {
+	Price         amountDTO `json:"price" swaggertype:"string" example:"1200.00"`
+	EffectiveFrom string    `json:"effective_from" example:"01-2026"`
+}
+
+type subscriptionEventDTO struct {
+	ID        int64  `json:"id" example:"1"`
+	Type      string `json:"type" example:"updated" enums:"created,updated,status_changed,deleted"`
+	Actor     string `json:"actor" example:"admin@example.com"`
+	CreatedAt string `json:"created_at" example:"2025-09-14T10:00:00Z"`
+	// Before and After are snapshots of the stored subscription; prices are in minor units.
+	Before json.RawMessage `json:"before" swaggertype:"object"`
+	After  json.RawMessage `json:"after" swaggertype:"object"`
+}
+
+type subscriptionHistoryResponseDTO struct {
+	Events []subscriptionEventDTO `json:"events"`
+}
+
+type expireSubscriptionsResponseDTO struct {
+	Expired int64 `json:"expired" example:"3"`
+}
+
+type monthlyReportResponseDTO struct {
+	Currency string           `json:"currency,omitempty" example:"RUB"`
+	Months   []monthReportDTO `json:"months"`
+}
+
+type monthReportDTO struct {
+	Month               string            `json:"month" example:"08-2025"`
+	TotalPrice          string            `json:"total_price" example:"1500.00"`
+	ActiveSubscriptions int               `json:"active_subscriptions" example:"2"`
+	Services            []serviceSpendDTO `json:"services"`
+}
+
+type serviceSpendDTO struct {
+	ServiceName         string `json:"service_name" example:"Yandex Plus"`
+	TotalPrice          string `json:"total_price" example:"1000.00"`
+	ActiveSubscriptions int    `json:"active_subscriptions" example:"1"`
 }
 
 type getSubscriptionsResponseDTO struct {
 	Subscriptions []getSubscriptionReadDTO `json:"subscriptions"`
+	NextCursor    string                   `json:"next_cursor,omitempty" example:"eyJmIjoic3RhcnRfZGF0ZSJ9"`
 }
 
+// updateSubscriptionCreateDTO is validated like createSubscriptionRequestDTO.
 type updateSubscriptionCreateDTO struct {
-	ServiceName string `json:"service_name" example:"Yandex Plus"`
-	Price       int    `json:"price" example:"499"`
-	StartDate   string `json:"start_date" example:"08-2025"`
-	EndDate     string `json:"end_date" example:"09-2025"`
+	ServiceName     string    `json:"service_name" validate:"trim,single_space,required,max=255" maxLength:"255" example:"Yandex Plus"`
+	Price           amountDTO `json:"price" validate:"trim,required,decimal" swaggertype:"string" example:"499.00"`
+	Currency        string    `json:"currency,omitempty" validate:"trim,upper,currency" example:"RUB"`
+	BillingPeriod   string    `json:"billing_period,omitempty" validate:"oneof=weekly monthly quarterly yearly" enums:"weekly,monthly,quarterly,yearly" example:"monthly"`
+	BillingInterval int       `json:"billing_interval,omitempty" validate:"min=0,max=1000" minimum:"0" maximum:"1000" example:"1"`
+	StartDate       string    `json:"start_date" validate:"trim,required,month" example:"08-2025"`
+	EndDate         string    `json:"end_date,omitempty" validate:"trim,month" example:"09-2025"`
+}
+
+type batchUpdateItemDTO struct {
+	ID string `json:"id" validate:"trim,required,uuid" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	// Version, if set, must be the subscription's current version, like an If-Match header.
+	Version *int64 `json:"version,omitempty" example:"3"`
+	updateSubscriptionCreateDTO
+}
+
+type batchDeleteRequestDTO struct {
+	IDs         []string `json:"ids" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	AtPeriodEnd bool     `json:"at_period_end,omitempty" example:"false"`
+}
+
+type batchItemResultDTO struct {
+	Index   int    `json:"index" example:"0"`
+	ID      string `json:"id,omitempty" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	Version int64  `json:"version,omitempty" example:"2"`
+	// Status is the HTTP status the item would have had as a single request; 424 marks an item of an atomic
+	// batch that was valid but not applied because another item failed.
+	Status int `json:"status" example:"200"`
+	// Code, Error and Errors are the code, detail and field errors of the problem the item would have had as a
+	// single request.
+	Code   string          `json:"code,omitempty" example:"not_found"`
+	Error  string          `json:"error,omitempty" example:"Resource not found"`
+	Errors []fieldErrorDTO `json:"errors,omitempty"`
+}
+
+type batchResponseDTO struct {
+	Results []batchItemResultDTO `json:"results"`
+}
+
+type exchangeRateDTO struct {
+	BaseCurrency  string `json:"base_currency" example:"USD"`
+	QuoteCurrency string `json:"quote_currency" example:"RUB"`
+	EffectiveFrom string `json:"effective_from" example:"08-2025"`
+	// Rate is a positive decimal with at most 10 digits before and 10 after the decimal point.
+	Rate string `json:"rate" example:"92.5"`
+}
+
+type exchangeRatesDTO struct {
+	Rates []exchangeRateDTO `json:"rates"`
+}
+
+type roleAssignmentDTO struct {
+	Subject    string `json:"subject" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	Role       string `json:"role" example:"operator" enums:"admin,operator,read-only,user"`
+	AssignedBy string `json:"assigned_by" example:"admin@example.com"`
+	AssignedAt string `json:"assigned_at" example:"2025-09-14T10:00:00Z"`
+}
+
+type roleAssignmentsDTO struct {
+	Assignments []roleAssignmentDTO `json:"assignments"`
+}
+
+func newSubscriptionReadDTO(sub *entity.Subscription) getSubscriptionReadDTO {
+	dto := getSubscriptionReadDTO{
+		ID:              sub.ID.String(),
+		UserID:          sub.UserID.String(),
+		ServiceName:     sub.ServiceName,
+		Price:           sub.Price.String(),
+		Currency:        sub.Price.Currency,
+		BillingPeriod:   string(sub.BillingPeriod),
+		BillingInterval: int(sub.BillingInterval),
+		StartDate:       sub.StartDate.Format(timeFormat),
+		Status:          string(sub.Status),
+		Version:         sub.Version,
+	}
+
+	dto.Prices = make([]pricePeriodDTO, 0, len(sub.PriceChanges)+1)
+	dto.Prices = append(dto.Prices, pricePeriodDTO{
+		Price:         sub.Price.String(),
+		EffectiveFrom: sub.StartDate.Format(timeFormat),
+	})
+	for _, priceChange := range sub.PriceChanges {
+		dto.Prices = append(dto.Prices, pricePeriodDTO{
+			Price:         priceChange.Price.String(),
+			EffectiveFrom: priceChange.EffectiveFrom.Format(timeFormat),
+		})
+	}
+
+	if sub.EndDate != nil {
+		endDate := sub.EndDate.Format(timeFormat)
+		dto.EndDate = &endDate
+	}
+
+	if sub.CancelledAt != nil {
+		cancelledAt := sub.CancelledAt.UTC().Format(time.RFC3339)
+		dto.CancelledAt = &cancelledAt
+	}
+
+	return dto
+}
+
+func newSubscriptionEventDTO(event *entity.SubscriptionEvent) subscriptionEventDTO {
+	return subscriptionEventDTO{
+		ID:        event.ID,
+		Type:      string(event.Type),
+		Actor:     event.Actor,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Before:    event.Before,
+		After:     event.After,
+	}
+}
+
+func newUpdateSubscriptionDTO(sub *entity.Subscription) *updateSubscriptionCreateDTO {
+	dto := &updateSubscriptionCreateDTO{
+		ServiceName:     sub.ServiceName,
+		Price:           amountDTO(sub.Price.String()),
+		Currency:        sub.Price.Currency,
+		BillingPeriod:   string(sub.BillingPeriod),
+		BillingInterval: int(sub.BillingInterval),
+		StartDate:       sub.StartDate.Format(timeFormat),
+	}
+
+	if sub.EndDate != nil {
+		dto.EndDate = sub.EndDate.Format(timeFormat)
+	}
+
+	return dto
+}
+
+type importLineErrorDTO struct {
+	Line  int    `json:"line" example:"3"`
+	Field string `json:"field,omitempty" example:"start_date"`
+	Error string `json:"error" example:"start date parse failed: parsing time \"2025-08\" as \"01-2006\": cannot parse \"2025-08\" as \"01\""`
+}
+
+type importSubscriptionsResponseDTO struct {
+	DryRun  bool                 `json:"dry_run" example:"false"`
+	Rows    int                  `json:"rows" example:"120"`
+	Valid   int                  `json:"valid" example:"120"`
+	Created int                  `json:"created" example:"120"`
+	IDs     []string             `json:"ids,omitempty"`
+	Errors  []importLineErrorDTO `json:"errors"`
+}
+
+// exportSubscriptionDTO is a subscription in an export. Price changes are left out, price is the price from
+// start_date.
+type exportSubscriptionDTO struct {
+	ID              string  `json:"id" example:"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	UserID          string  `json:"user_id" example:"a6fa4d7c-8f90-4f92-912e-92c644c57a1e"`
+	ServiceName     string  `json:"service_name" example:"Yandex Plus"`
+	Price           string  `json:"price" example:"1000.00"`
+	Currency        string  `json:"currency" example:"RUB"`
+	BillingPeriod   string  `json:"billing_period" example:"monthly"`
+	BillingInterval int     `json:"billing_interval" example:"1"`
+	StartDate       string  `json:"start_date" example:"08-2025"`
+	EndDate         *string `json:"end_date" example:"09-2025"`
+	Status          string  `json:"status" example:"active" enums:"trial,active,paused,cancelled,expired"`
+	CancelledAt     *string `json:"cancelled_at,omitempty" example:"2025-09-14T10:00:00Z"`
+	Version         int64   `json:"version" example:"1"`
+}
+
+// exportCSVHeader names the columns of exportSubscriptionDTO.csvRecord. Those the CSV import reads have its default
+// names, but an export does not round-trip: the import ignores id, cancelled_at and version, creating new
+// subscriptions, and rejects the paused, cancelled and expired statuses.
+var exportCSVHeader = []string{"id", "user_id", "service_name", "price", "currency", "billing_period", "billing_interval", "start_date", "end_date", "status", "cancelled_at", "version"}
+
+func newSubscriptionExportDTO(sub *entity.Subscription) exportSubscriptionDTO {
+	dto := newSubscriptionReadDTO(sub)
+
+	return exportSubscriptionDTO{
+		ID:              dto.ID,
+		UserID:          dto.UserID,
+		ServiceName:     dto.ServiceName,
+		Price:           dto.Price,
+		Currency:        dto.Currency,
+		BillingPeriod:   dto.BillingPeriod,
+		BillingInterval: dto.BillingInterval,
+		StartDate:       dto.StartDate,
+		EndDate:         dto.EndDate,
+		Status:          dto.Status,
+		CancelledAt:     dto.CancelledAt,
+		Version:         dto.Version,
+	}
+}
+
+func (dto *exportSubscriptionDTO) csvRecord() []string {
+	var endDate, cancelledAt string
+	if dto.EndDate != nil {
+		endDate = *dto.EndDate
+	}
+	if dto.CancelledAt != nil {
+		cancelledAt = *dto.CancelledAt
+	}
+
+	return []string{
+		dto.ID,
+		dto.UserID,
+		dto.ServiceName,
+		dto.Price,
+		dto.Currency,
+		dto.BillingPeriod,
+		strconv.Itoa(dto.BillingInterval),
+		dto.StartDate,
+		endDate,
+		dto.Status,
+		cancelledAt,
+		strconv.FormatInt(dto.Version, 10),
+	}
+}
+
+// problemDTO is an RFC 7807 problem detail.
+type problemDTO struct {
+	Type   string `json:"type" example:"/problems/validation_failed"`
+	Title  string `json:"title" example:"Request has invalid fields"`
+	Status int    `json:"status" example:"400"`
+	Detail string `json:"detail,omitempty" example:"start_date: parsing time \"2025-08\" as \"01-2006\": cannot parse \"2025-08\" as \"01\""`
+	// Code is a stable identifier of the kind of problem.
+	Code   string          `json:"code" example:"validation_failed"`
+	Errors []fieldErrorDTO `json:"errors,omitempty"`
+}
+
+type fieldErrorDTO struct {
+	Field   string `json:"field" example:"start_date"`
+	Message string `json:"message" example:"parsing time \"2025-08\" as \"01-2006\": cannot parse \"2025-08\" as \"01\""`
 }
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSubscriptionReadDTOEndDate(t *testing.T) {
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		endDate *time.Time
+		want    string
+	}{
+		{name: "open-ended", want: `"end_date":null`},
+		{name: "closed", endDate: &end, want: `"end_date":"12-2025"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &entity.Subscription{
+				ID:        uuid.New(),
+				UserID:    uuid.New(),
+				Price:     entity.Money{Amount: 100000, Currency: "RUB"},
+				StartDate: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+				EndDate:   tt.endDate,
+			}
+
+			data, err := json.Marshal(newSubscriptionReadDTO(sub))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Fatalf("JSON = %s, want it to contain %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSubscriptionEventDTO(t *testing.T) {
+	event := &entity.SubscriptionEvent{
+		ID:        7,
+		Type:      entity.SubscriptionEventType("created"),
+		Actor:     "alice",
+		CreatedAt: time.Date(2025, time.September, 14, 13, 0, 0, 500, time.FixedZone("UTC+3", 3*60*60)),
+		After:     json.RawMessage(`{"price":999}`),
+	}
+
+	data, err := json.Marshal(newSubscriptionEventDTO(event))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"id":7,"type":"created","actor":"alice","created_at":"2025-09-14T10:00:00.0000005Z","before":null,"after":{"price":999}}`
+	if string(data) != want {
+		t.Fatalf("JSON = %s, want %s", data, want)
+	}
+}
+
+func TestNewSubscriptionReadDTOPrices(t *testing.T) {
+	sub := &entity.Subscription{
+		Price:     entity.Money{Amount: 1000, Currency: "USD"},
+		StartDate: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		PriceChanges: []entity.PriceChange{
+			{Price: entity.Money{Amount: 1200, Currency: "USD"}, EffectiveFrom: time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC)},
+			{Price: entity.Money{Amount: 1500, Currency: "USD"}, EffectiveFrom: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	got := newSubscriptionReadDTO(sub).Prices
+	want := []pricePeriodDTO{
+		{Price: "10.00", EffectiveFrom: "07-2025"},
+		{Price: "12.00", EffectiveFrom: "10-2025"},
+		{Price: "15.00", EffectiveFrom: "01-2026"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Prices = %+v, want %+v", got, want)
+	}
+}
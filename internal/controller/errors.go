@@ -1,21 +1,227 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
 	"log/slog"
 	"net/http"
 )
 
+// problemContentType is the media type of RFC 7807 error responses.
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix is joined with an error code to form the type URI of a problem.
+const problemTypePrefix = "/problems/"
+
+// Error codes identify the kind of a problem. Clients match on them, so they must never change.
+const (
+	codeValidationFailed     = "validation_failed"
+	codeMalformedRequest     = "malformed_request"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeRequestTooLarge      = "request_too_large"
+	codeNotAcceptable        = "not_acceptable"
+	codeNotFound             = "not_found"
+	codeForbidden            = "forbidden"
+	codeInvalidCursor        = "invalid_cursor"
+	codePreconditionFailed   = "precondition_failed"
+	codeInvalidTransition    = "invalid_transition"
+	codeInvalidPriceChange   = "invalid_price_change"
+	codePriceHistoryConflict = "price_history_conflict"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codeMixedCurrencies      = "mixed_currencies"
+	codeExchangeRateNotFound = "exchange_rate_not_found"
+	codeBatchItemNotApplied  = "batch_item_not_applied"
+	codeInternal             = "internal_error"
+)
+
+var problemTitles = map[string]string{
+	codeValidationFailed:     "Request has invalid fields",
+	codeMalformedRequest:     "Request is malformed",
+	codeUnsupportedMediaType: "Unsupported media type",
+	codeRequestTooLarge:      "Request body is too large",
+	codeNotAcceptable:        "No acceptable media type",
+	codeNotFound:             "Resource not found",
+	codeForbidden:            "Access denied",
+	codeInvalidCursor:        "Cursor is invalid or does not match the requested sort",
+	codePreconditionFailed:   "Subscription was modified",
+	codeInvalidTransition:    "Subscription cannot change to the requested status",
+	codeInvalidPriceChange:   "Price change cannot be scheduled",
+	codePriceHistoryConflict: "Update conflicts with the scheduled prices",
+	codeIdempotencyKeyReused: "Idempotency-Key was already used with a different request",
+	codeMixedCurrencies:      "Subscriptions are priced in different currencies",
+	codeExchangeRateNotFound: "Exchange rate not found",
+	codeBatchItemNotApplied:  "Not applied because another item of the batch failed",
+	codeInternal:             "Internal server error",
+}
+
+// fieldError is an invalid value of a request field: a property of the body, a query or path parameter, or a
+// header. field is named as the client sends it.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func invalidField(field string, err error) error {
+	return &fieldError{field: field, err: err}
+}
+
+func (e *fieldError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// requestError is a client error that is not about a single field.
+type requestError struct {
+	status int
+	code   string
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// malformedRequest reports a body or header that could not be parsed at all.
+func malformedRequest(err error) error {
+	return &requestError{status: http.StatusBadRequest, code: codeMalformedRequest, err: err}
+}
+
+func unsupportedMediaType(mediaType string) error {
+	return &requestError{
+		status: http.StatusUnsupportedMediaType,
+		code:   codeUnsupportedMediaType,
+		err:    fmt.Errorf("expected a %s body", mediaType),
+	}
+}
+
+// requestBodyError reports an error reading the body of a request limited by http.MaxBytesReader: 413 if the body
+// exceeded the limit, otherwise a malformed request.
+func requestBodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &requestError{
+			status: http.StatusRequestEntityTooLarge,
+			code:   codeRequestTooLarge,
+			err:    fmt.Errorf("body must not exceed %d bytes", tooLarge.Limit),
+		}
+	}
+
+	return malformedRequest(err)
+}
+
+// invalidCursor reports a cursor that was not produced by the service or was tampered with.
+func invalidCursor(err error) error {
+	return &requestError{status: http.StatusBadRequest, code: codeInvalidCursor, err: err}
+}
+
+func notAcceptable(mediaTypes ...string) error {
+	return &requestError{
+		status: http.StatusNotAcceptable,
+		code:   codeNotAcceptable,
+		err:    fmt.Errorf("acceptable media types are %q", mediaTypes),
+	}
+}
+
+// handleError writes err as an RFC 7807 problem. Unexpected errors are logged and answered without details.
 func handleError(w http.ResponseWriter, err error) {
+	problem := newProblem(err)
+	if problem.Status == http.StatusInternalServerError {
+		slog.Error("unexpected internal error", slog.String("error", err.Error()))
+	}
+
+	writeProblem(w, problem)
+}
+
+func writeProblem(w http.ResponseWriter, problem problemDTO) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// newProblem maps a request or service error to the problem shown to the client.
+func newProblem(err error) problemDTO {
+	if fields := fieldErrors(err); len(fields) > 0 {
+		problem := problemWithCode(http.StatusBadRequest, codeValidationFailed, fields[0].Error())
+		if len(fields) > 1 {
+			problem.Detail = fmt.Sprintf("%d fields are invalid", len(fields))
+		}
+
+		problem.Errors = make([]fieldErrorDTO, 0, len(fields))
+		for _, field := range fields {
+			problem.Errors = append(problem.Errors, fieldErrorDTO{
+				Field:   field.field,
+				Message: field.err.Error(),
+			})
+		}
+
+		return problem
+	}
+
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return problemWithCode(reqErr.status, reqErr.code, reqErr.err.Error())
+	}
+
 	switch {
 	case errors.Is(err, srvc.ErrNotFound):
-		w.WriteHeader(http.StatusNotFound)
-		_, _ = w.Write([]byte("resource not found"))
-		return
+		return problemWithCode(http.StatusNotFound, codeNotFound, "")
+	case errors.Is(err, srvc.ErrForbidden):
+		return problemWithCode(http.StatusForbidden, codeForbidden, "user_id must be the caller's own user ID")
+	case errors.Is(err, srvc.ErrInvalidCursor):
+		return problemWithCode(http.StatusBadRequest, codeInvalidCursor, "")
+	case errors.Is(err, srvc.ErrPreconditionFailed):
+		return problemWithCode(http.StatusPreconditionFailed, codePreconditionFailed, "If-Match does not match the subscription's ETag")
+	case errors.Is(err, srvc.ErrInvalidTransition):
+		return problemWithCode(http.StatusConflict, codeInvalidTransition, err.Error())
+	case errors.Is(err, srvc.ErrInvalidPriceChange):
+		return problemWithCode(http.StatusUnprocessableEntity, codeInvalidPriceChange, err.Error())
+	case errors.Is(err, srvc.ErrPriceHistoryConflict):
+		return problemWithCode(http.StatusConflict, codePriceHistoryConflict, err.Error())
+	case errors.Is(err, srvc.ErrIdempotencyKeyReused):
+		return problemWithCode(http.StatusUnprocessableEntity, codeIdempotencyKeyReused, "")
+	case errors.Is(err, srvc.ErrMixedCurrencies):
+		return problemWithCode(http.StatusUnprocessableEntity, codeMixedCurrencies, "set target_currency to convert the prices")
+	case errors.Is(err, srvc.ErrExchangeRateNotFound):
+		return problemWithCode(http.StatusUnprocessableEntity, codeExchangeRateNotFound, "")
 	default:
-		slog.Error("unexpected internal error", slog.String("error", err.Error()))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return problemWithCode(http.StatusInternalServerError, codeInternal, "")
+	}
+}
+
+func problemWithCode(status int, code, detail string) problemDTO {
+	return problemDTO{
+		Type:   problemTypePrefix + code,
+		Title:  problemTitles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// fieldErrors returns every fieldError in the tree of err, including those combined with errors.Join.
+func fieldErrors(err error) []*fieldError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *fieldError:
+		return []*fieldError{e}
+	case interface{ Unwrap() []error }:
+		var fields []*fieldError
+		for _, joined := range e.Unwrap() {
+			fields = append(fields, fieldErrors(joined)...)
+		}
+		return fields
+	default:
+		return fieldErrors(errors.Unwrap(err))
 	}
 }
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "field", err: invalidField("price", errors.New("invalid")), wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed},
+		{name: "malformed", err: malformedRequest(errors.New("unexpected EOF")), wantStatus: http.StatusBadRequest, wantCode: codeMalformedRequest},
+		{name: "unsupported media type", err: unsupportedMediaType("text/csv"), wantStatus: http.StatusUnsupportedMediaType, wantCode: codeUnsupportedMediaType},
+		{name: "not acceptable", err: notAcceptable(csvContentType), wantStatus: http.StatusNotAcceptable, wantCode: codeNotAcceptable},
+		{name: "not found", err: fmt.Errorf("get: %w", srvc.ErrNotFound), wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "forbidden", err: srvc.ErrForbidden, wantStatus: http.StatusForbidden, wantCode: codeForbidden},
+		{name: "invalid cursor", err: srvc.ErrInvalidCursor, wantStatus: http.StatusBadRequest, wantCode: codeInvalidCursor},
+		{name: "precondition failed", err: srvc.ErrPreconditionFailed, wantStatus: http.StatusPreconditionFailed, wantCode: codePreconditionFailed},
+		{name: "invalid transition", err: srvc.ErrInvalidTransition, wantStatus: http.StatusConflict, wantCode: codeInvalidTransition},
+		{name: "invalid price change", err: srvc.ErrInvalidPriceChange, wantStatus: http.StatusUnprocessableEntity, wantCode: codeInvalidPriceChange},
+		{name: "price history conflict", err: srvc.ErrPriceHistoryConflict, wantStatus: http.StatusConflict, wantCode: codePriceHistoryConflict},
+		{name: "idempotency key reused", err: srvc.ErrIdempotencyKeyReused, wantStatus: http.StatusUnprocessableEntity, wantCode: codeIdempotencyKeyReused},
+		{name: "mixed currencies", err: srvc.ErrMixedCurrencies, wantStatus: http.StatusUnprocessableEntity, wantCode: codeMixedCurrencies},
+		{name: "exchange rate not found", err: srvc.ErrExchangeRateNotFound, wantStatus: http.StatusUnprocessableEntity, wantCode: codeExchangeRateNotFound},
+		{name: "unexpected", err: errors.New("connection refused"), wantStatus: http.StatusInternalServerError, wantCode: codeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := newProblem(tt.err)
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode {
+				t.Fatalf("newProblem() = %d %q, want %d %q", problem.Status, problem.Code, tt.wantStatus, tt.wantCode)
+			}
+			if problem.Type != problemTypePrefix+tt.wantCode {
+				t.Fatalf("type = %q, want %q", problem.Type, problemTypePrefix+tt.wantCode)
+			}
+			if problem.Title == "" {
+				t.Fatalf("problem %q has no title", tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNewProblemHidesInternalErrors(t *testing.T) {
+	problem := newProblem(errors.New("pq: password authentication failed"))
+	if problem.Detail != "" {
+		t.Fatalf("detail = %q, want none", problem.Detail)
+	}
+}
+
+func TestNewProblemFieldErrors(t *testing.T) {
+	problem := newProblem(errors.Join(
+		invalidField("price", errors.New("must be a decimal")),
+		fmt.Errorf("dates: %w", invalidField("start_date", errors.New("must be MM-YYYY"))),
+	))
+
+	if problem.Status != http.StatusBadRequest || problem.Detail != "2 fields are invalid" {
+		t.Fatalf("newProblem() = %d %q, want 400 with 2 fields", problem.Status, problem.Detail)
+	}
+	want := []fieldErrorDTO{
+		{Field: "price", Message: "must be a decimal"},
+		{Field: "start_date", Message: "must be MM-YYYY"},
+	}
+	if len(problem.Errors) != len(want) {
+		t.Fatalf("errors = %+v, want %+v", problem.Errors, want)
+	}
+	for i := range want {
+		if problem.Errors[i] != want[i] {
+			t.Fatalf("errors = %+v, want %+v", problem.Errors, want)
+		}
+	}
+
+	problem = newProblem(invalidField("price", errors.New("must be a decimal")))
+	if problem.Detail != "price: must be a decimal" {
+		t.Fatalf("detail = %q, want the field error", problem.Detail)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	price := invalidField("price", errors.New("invalid"))
+	currency := invalidField("currency", errors.New("invalid"))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: 0},
+		{name: "other error", err: errors.New("boom"), want: 0},
+		{name: "field", err: price, want: 1},
+		{name: "wrapped", err: fmt.Errorf("parse: %w", price), want: 1},
+		{name: "joined", err: errors.Join(price, errors.New("boom"), currency), want: 2},
+		{name: "nested", err: fmt.Errorf("parse: %w", errors.Join(price, fmt.Errorf("x: %w", currency))), want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldErrors(tt.err); len(got) != tt.want {
+				t.Fatalf("fieldErrors() = %v, want %d errors", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemTitles(t *testing.T) {
+	codes := []string{
+		codeValidationFailed, codeMalformedRequest, codeUnsupportedMediaType, codeRequestTooLarge,
+		codeNotAcceptable, codeNotFound, codeForbidden, codeInvalidCursor, codePreconditionFailed,
+		codeInvalidTransition, codeInvalidPriceChange, codePriceHistoryConflict, codeIdempotencyKeyReused, codeMixedCurrencies,
+		codeExchangeRateNotFound, codeBatchItemNotApplied, codeInternal,
+	}
+
+	for _, code := range codes {
+		if problemTitles[code] == "" {
+			t.Errorf("code %q has no title", code)
+		}
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleError(w, srvc.ErrNotFound)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := w.Header().Get("Content-Type"); got != problemContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, problemContentType)
+	}
+
+	var problem problemDTO
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("unmarshal problem: %v", err)
+	}
+	want := problemDTO{Type: "/problems/not_found", Title: problemTitles[codeNotFound], Status: http.StatusNotFound, Code: codeNotFound}
+	if problem.Type != want.Type || problem.Title != want.Title || problem.Status != want.Status || problem.Code != want.Code {
+		t.Fatalf("problem = %+v, want %+v", problem, want)
+	}
+}
+
+func TestRequestBodyError(t *testing.T) {
+	problem := newProblem(requestBodyError(&http.MaxBytesError{Limit: 1024}))
+	if problem.Status != http.StatusRequestEntityTooLarge || problem.Code != codeRequestTooLarge {
+		t.Fatalf("newProblem() = %d %q, want 413 %q", problem.Status, problem.Code, codeRequestTooLarge)
+	}
+
+	problem = newProblem(requestBodyError(errors.New("unexpected EOF")))
+	if problem.Status != http.StatusBadRequest || problem.Code != codeMalformedRequest {
+		t.Fatalf("newProblem() = %d %q, want 400 %q", problem.Status, problem.Code, codeMalformedRequest)
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the subscription versions listed in the If-Match header. A missing header or "*" yields
+// nil, which allows any version. Weak validators never match, as If-Match uses strong comparison.
+func parseIfMatch(r *http.Request) ([]int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := make([]int64, 0)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, fmt.Errorf("malformed entity tag %q", tag)
+		}
+
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			// Not a tag this server issued, so it cannot match.
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	// Every listed tag is unmatchable; use a version that never exists so the write is rejected.
+	if len(versions) == 0 {
+		versions = append(versions, 0)
+	}
+
+	return versions, nil
+}
+
+func ifMatchAllows(ifMatch []int64, version int64) bool {
+	return ifMatch == nil || slices.Contains(ifMatch, version)
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatETag(t *testing.T) {
+	if got := formatETag(42); got != `"42"` {
+		t.Fatalf("formatETag(42) = %s, want \"42\"", got)
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    []int64
+		wantErr bool
+	}{
+		{name: "missing", header: ""},
+		{name: "any", header: " * "},
+		{name: "one", header: `"3"`, want: []int64{3}},
+		{name: "several", header: `"3", "5" ,"8"`, want: []int64{3, 5, 8}},
+		{name: "weak tags are skipped", header: `W/"3", "5"`, want: []int64{5}},
+		{name: "only weak tags", header: `W/"3"`, want: []int64{0}},
+		{name: "foreign tag", header: `"abc"`, want: []int64{0}},
+		{name: "foreign and own tag", header: `"abc", "7"`, want: []int64{7}},
+		{name: "unquoted", header: `3`, wantErr: true},
+		{name: "half quoted", header: `"3`, wantErr: true},
+		{name: "lone quote", header: `"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/subscriptions/x", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			got, err := parseIfMatch(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIfMatch(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Fatalf("parseIfMatch(%q) = %#v, want %#v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfMatchAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch []int64
+		version int64
+		want    bool
+	}{
+		{name: "no header", version: 3, want: true},
+		{name: "listed", ifMatch: []int64{2, 3}, version: 3, want: true},
+		{name: "stale", ifMatch: []int64{2}, version: 3},
+		{name: "unmatchable", ifMatch: []int64{0}, version: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ifMatchAllows(tt.ifMatch, tt.version); got != tt.want {
+				t.Fatalf("ifMatchAllows(%v, %d) = %v, want %v", tt.ifMatch, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionETag(t *testing.T) {
+	sub := &entity.Subscription{ID: uuid.New(), UserID: uuid.New(), Price: entity.Money{Amount: 100, Currency: "RUB"}, Version: 7}
+	fake := &fakeService{
+		getSubscription: func(context.Context, uuid.UUID) (*entity.Subscription, error) {
+			return sub, nil
+		},
+	}
+
+	w := serve(t, fake, httptest.NewRequest(http.MethodGet, "/subscriptions/"+sub.ID.String(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"7"` {
+		t.Fatalf("ETag = %s, want \"7\"", etag)
+	}
+}
+
+func TestPutSubscriptionIfMatch(t *testing.T) {
+	body := `{"service_name":"Netflix","price":"9.99","currency":"USD","start_date":"07-2025"}`
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+		wantPassed []int64
+	}{
+		{name: "current version", ifMatch: `"3"`, wantStatus: http.StatusOK, wantPassed: []int64{3}},
+		{name: "stale version", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed, wantPassed: []int64{2}},
+		{name: "no header", wantStatus: http.StatusOK},
+		{name: "malformed header", ifMatch: `3`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var passed []int64
+			fake := &fakeService{
+				updateSubscription: func(_ context.Context, _ uuid.UUID, _ *entity.UpdateSubscriptionData, ifMatch []int64, _ string) (int64, error) {
+					passed = ifMatch
+					if ifMatch != nil && !slices.Contains(ifMatch, 3) {
+						return 0, srvc.ErrPreconditionFailed
+					}
+					return 4, nil
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodPut, "/subscriptions/"+uuid.NewString(), strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			w := serve(t, fake, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !slices.Equal(passed, tt.wantPassed) {
+				t.Fatalf("If-Match passed to the service = %v, want %v", passed, tt.wantPassed)
+			}
+			if tt.wantStatus == http.StatusOK && w.Header().Get("ETag") != `"4"` {
+				t.Fatalf("ETag = %s, want the new version \"4\"", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxImportBodySize = 10 << 20
+
+// GetExchangeRates godoc
+// @Summary Get exchange rates
+// @Description List stored exchange rates, optionally for one currency pair
+// @Tags admin
+// @Produce json
+// @Param base_currency query string false "Base currency (ISO 4217)"
+// @Param quote_currency query string false "Quote currency (ISO 4217)"
+// @Success 200 {object} exchangeRatesDTO
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/exchange-rates [get]
+func (c *controller) getExchangeRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	base, err := parseOptionalCurrency(query.Get("base_currency"), "")
+	if err != nil {
+		handleError(w, invalidField("base_currency", err))
+		return
+	}
+
+	quote, err := parseOptionalCurrency(query.Get("quote_currency"), "")
+	if err != nil {
+		handleError(w, invalidField("quote_currency", err))
+		return
+	}
+
+	filter := &entity.GetExchangeRatesFilter{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+	}
+
+	ctx := r.Context()
+	rates, err := c.service.GetExchangeRates(ctx, filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	var resp = exchangeRatesDTO{
+		Rates: make([]exchangeRateDTO, 0, len(rates)),
+	}
+	for _, rate := range rates {
+		resp.Rates = append(resp.Rates, exchangeRateDTO{
+			BaseCurrency:  rate.BaseCurrency,
+			QuoteCurrency: rate.QuoteCurrency,
+			EffectiveFrom: rate.EffectiveFrom.Format(timeFormat),
+			Rate:          rate.Rate,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	return
+}
+
+// PutExchangeRates godoc
+// @Summary Set exchange rates
+// @Description Create or replace exchange rates. A rate applies from its effective_from month until the next rate of the same pair.
+// @Tags admin
+// @Accept json
+// @Param rates body exchangeRatesDTO true "Exchange rates"
+// @Success 200 "OK"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/exchange-rates [put]
+func (c *controller) putExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var req exchangeRatesDTO
+
+	err := decodeJSONBody(r.Body, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rates := make([]entity.ExchangeRate, 0, len(req.Rates))
+	for _, dto := range req.Rates {
+		rate, err := parseExchangeRate(dto.BaseCurrency, dto.QuoteCurrency, dto.EffectiveFrom, dto.Rate)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		rates = append(rates, rate)
+	}
+
+	ctx := r.Context()
+	err = c.service.SetExchangeRates(ctx, rates)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// ImportExchangeRates godoc
+// @Summary Import exchange rates from CSV
+// @Description Create or replace exchange rates from a CSV file with the header base_currency,quote_currency,effective_from,rate.
+// @Description Either every row is stored or none.
+// @Tags admin
+// @Accept text/csv
+// @Success 200 "OK"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 413 {object} problemDTO "The file exceeds 10 MiB"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/exchange-rates/import [post]
+func (c *controller) importExchangeRates(w http.ResponseWriter, r *http.Request) {
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		handleError(w, requestBodyError(fmt.Errorf("read CSV header: %w", err)))
+		return
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var indexes [4]int
+	for i, name := range []string{"base_currency", "quote_currency", "effective_from", "rate"} {
+		index, ok := columns[name]
+		if !ok {
+			handleError(w, malformedRequest(fmt.Errorf("missing column %q", name)))
+			return
+		}
+		indexes[i] = index
+	}
+	reader.FieldsPerRecord = len(header)
+
+	rates := make([]entity.ExchangeRate, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			handleError(w, requestBodyError(err))
+			return
+		}
+
+		rate, err := parseExchangeRate(record[indexes[0]], record[indexes[1]], record[indexes[2]], record[indexes[3]])
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		rates = append(rates, rate)
+	}
+
+	ctx := r.Context()
+	err = c.service.SetExchangeRates(ctx, rates)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// DeleteExchangeRate godoc
+// @Summary Delete an exchange rate
+// @Tags admin
+// @Param base path string true "Base currency (ISO 4217)"
+// @Param quote path string true "Quote currency (ISO 4217)"
+// @Param effective_from path string true "Month the rate takes effect (MM-YYYY)"
+// @Success 200 "OK"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/exchange-rates/{base}/{quote}/{effective_from} [delete]
+func (c *controller) deleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	base, err := parseCurrency(r.PathValue("base"))
+	if err != nil {
+		handleError(w, invalidField("base", err))
+		return
+	}
+
+	quote, err := parseCurrency(r.PathValue("quote"))
+	if err != nil {
+		handleError(w, invalidField("quote", err))
+		return
+	}
+
+	effectiveFrom, err := time.Parse(timeFormat, r.PathValue("effective_from"))
+	if err != nil {
+		handleError(w, invalidField("effective_from", err))
+		return
+	}
+
+	rate := &entity.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		EffectiveFrom: effectiveFrom,
+	}
+
+	ctx := r.Context()
+	err = c.service.DeleteExchangeRate(ctx, rate)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return
+}
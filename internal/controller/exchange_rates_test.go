@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postExchangeRates posts body as a CSV file to the exchange rate import, storing the rates in stored.
+func postExchangeRates(t *testing.T, body string, stored *[]entity.ExchangeRate) *httptest.ResponseRecorder {
+	t.Helper()
+
+	fake := &fakeService{
+		setExchangeRates: func(_ context.Context, rates []entity.ExchangeRate) error {
+			*stored = rates
+			return nil
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/exchange-rates/import", strings.NewReader(body))
+	r.Header.Set("Content-Type", csvContentType)
+
+	return serve(t, fake, r)
+}
+
+func TestImportExchangeRates(t *testing.T) {
+	var stored []entity.ExchangeRate
+	body := "Rate,base_currency,quote_currency,effective_from\n" +
+		"81.25,usd,RUB,07-2025\n" +
+		"0.92,USD,EUR,08-2025\n"
+
+	w := postExchangeRates(t, body, &stored)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+	}
+	if len(stored) != 2 || stored[0].BaseCurrency != "USD" || stored[0].Rate != "81.25" || stored[1].QuoteCurrency != "EUR" {
+		t.Fatalf("stored = %+v, want both rows", stored)
+	}
+}
+
+func TestImportExchangeRatesInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "missing column",
+			body:       "base_currency,quote_currency,rate\nUSD,RUB,81\n",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeMalformedRequest,
+		},
+		{
+			name:       "invalid row",
+			body:       "base_currency,quote_currency,effective_from,rate\nUSD,RUB,07-2025,0\n",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeValidationFailed,
+		},
+		{
+			name: "too large",
+			body: "base_currency,quote_currency,effective_from,rate\n" +
+				strings.Repeat("USD,RUB,07-2025,81.25\n", maxImportBodySize/len("USD,RUB,07-2025,81.25\n")+1),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   codeRequestTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored []entity.ExchangeRate
+
+			w := postExchangeRates(t, tt.body, &stored)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			var problem problemDTO
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Fatalf("code = %q, want %q", problem.Code, tt.wantCode)
+			}
+			if stored != nil {
+				t.Fatalf("stored %d rates, want none", len(stored))
+			}
+		})
+	}
+}
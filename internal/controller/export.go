@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// exportFlushRows is how many rows are written between flushes of an export to the client.
+const exportFlushRows = 1000
+
+// exportWriter writes the rows of an export in one format.
+type exportWriter interface {
+	writeHeader() error
+	writeRow(dto *exportSubscriptionDTO) error
+	flush() error
+}
+
+// ExportSubscriptions godoc
+// @Summary Export subscriptions
+// @Description Stream every subscription matching the filters, without pagination, as CSV or newline-delimited JSON depending on the Accept header: the type with the higher q-value, NDJSON if both are equally preferred or there is no Accept header. A type with q=0 is never used.
+// @Description The filters are those of GET /subscriptions. Price changes are not exported, price is the price from start_date. The CSV columns the import reads have its default names, but POST /subscriptions/import ignores id, cancelled_at and version and only accepts the trial and active statuses, so an export cannot be re-imported as it is.
+// @Description Rows are sent while they are read, so an error after the first row can only end the response early.
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param Accept header string false "text/csv or application/x-ndjson"
+// @Param sort query string false "Sort field and order: start_date, end_date, price or service_name, optionally followed by :asc or :desc (default start_date:asc)"
+// @Param user_id query []string false "Filter by User IDs, repeated or comma-separated" collectionFormat(multi)
+// @Param service_name query []string false "Filter by Service names" collectionFormat(multi)
+// @Param start_date query string false "Start of the date range (MM-YYYY)"
+// @Param end_date query string false "End of the date range (MM-YYYY)"
+// @Param match query string false "How the date range is matched: contained (default) - whole period inside the range, overlaps - active at any point of the range, active_at - active in the start_date month" Enums(contained, overlaps, active_at)
+// @Param price_min query string false "Minimum price, decimal in the subscription's currency"
+// @Param price_max query string false "Maximum price, decimal in the subscription's currency"
+// @Param status query []string false "Filter by status (default: every status except cancelled)" collectionFormat(multi) Enums(trial, active, paused, cancelled, expired)
+// @Success 200 {array} exportSubscriptionDTO "One subscription per line"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 406 {object} problemDTO "Not Acceptable"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/export [get]
+func (c *controller) exportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateExportType(r.Header.Get("Accept"))
+	if !ok {
+		handleError(w, notAcceptable(csvContentType, ndjsonContentType))
+		return
+	}
+
+	query := r.URL.Query()
+
+	sort, err := parseSort(query.Get("sort"))
+	if err != nil {
+		handleError(w, invalidField("sort", err))
+		return
+	}
+
+	filter, err := parseSubscriptionsFilter(query)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = defaultListStatuses
+	}
+
+	var export exportWriter
+	if contentType == csvContentType {
+		export = newCSVExportWriter(w)
+	} else {
+		export = newNDJSONExportWriter(w)
+	}
+
+	// The response starts with the first row, so that an error before it still gets a proper status.
+	rows := 0
+	start := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		return export.writeHeader()
+	}
+
+	ctx := r.Context()
+	err = c.service.ExportSubscriptions(ctx, filter, sort, func(sub *entity.Subscription) error {
+		if rows == 0 {
+			err := start()
+			if err != nil {
+				return err
+			}
+		}
+
+		dto := newSubscriptionExportDTO(sub)
+		err := export.writeRow(&dto)
+		if err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			return export.flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		if rows == 0 {
+			handleError(w, err)
+			return
+		}
+
+		slog.Error("export aborted", slog.Int("rows", rows), slog.String("error", err.Error()))
+		return
+	}
+
+	if rows == 0 {
+		err = start()
+	}
+	if err == nil {
+		err = export.flush()
+	}
+	if err != nil {
+		slog.Error("export aborted", slog.Int("rows", rows), slog.String("error", err.Error()))
+	}
+}
+
+// negotiateExportType picks the export format from the Accept header: the one with the higher q-value, NDJSON on a
+// tie. Each format takes the q-value of the most specific media range matching it, and q=0 rules it out. Without an
+// Accept header NDJSON is used.
+func negotiateExportType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ndjsonContentType, true
+	}
+
+	csvQ, ndjsonQ := acceptQuality(accept, csvContentType), acceptQuality(accept, ndjsonContentType)
+	switch {
+	case ndjsonQ > 0 && ndjsonQ >= csvQ:
+		return ndjsonContentType, true
+	case csvQ > 0:
+		return csvContentType, true
+	default:
+		return "", false
+	}
+}
+
+// acceptQuality returns the q-value the Accept header gives mediaType, 0 if it is not accepted. Media ranges that
+// cannot be parsed, or have an invalid q-value, are ignored.
+func acceptQuality(accept, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, 0
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		var rangeSpecificity int
+		switch mediaRange {
+		case mediaType:
+			rangeSpecificity = 3
+		case mainType + "/*":
+			rangeSpecificity = 2
+		case "*/*":
+			rangeSpecificity = 1
+		default:
+			continue
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		quality, specificity = q, rangeSpecificity
+	}
+
+	return quality
+}
+
+type csvExportWriter struct {
+	w      *csv.Writer
+	client *http.ResponseController
+}
+
+func newCSVExportWriter(w http.ResponseWriter) *csvExportWriter {
+	return &csvExportWriter{
+		w:      csv.NewWriter(w),
+		client: http.NewResponseController(w),
+	}
+}
+
+func (e *csvExportWriter) writeHeader() error {
+	return e.w.Write(exportCSVHeader)
+}
+
+func (e *csvExportWriter) writeRow(dto *exportSubscriptionDTO) error {
+	return e.w.Write(dto.csvRecord())
+}
+
+func (e *csvExportWriter) flush() error {
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+
+	return e.client.Flush()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+	client  *http.ResponseController
+}
+
+func newNDJSONExportWriter(w http.ResponseWriter) *ndjsonExportWriter {
+	return &ndjsonExportWriter{
+		encoder: json.NewEncoder(w),
+		client:  http.NewResponseController(w),
+	}
+}
+
+func (e *ndjsonExportWriter) writeHeader() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) writeRow(dto *exportSubscriptionDTO) error {
+	return e.encoder.Encode(dto)
+}
+
+func (e *ndjsonExportWriter) flush() error {
+	return e.client.Flush()
+}
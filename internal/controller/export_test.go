@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateExportType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		wantOK bool
+	}{
+		{accept: "", want: ndjsonContentType, wantOK: true},
+		{accept: "*/*", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv", want: csvContentType, wantOK: true},
+		{accept: "text/*", want: csvContentType, wantOK: true},
+		{accept: "application/x-ndjson", want: ndjsonContentType, wantOK: true},
+		{accept: "application/*", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv, application/x-ndjson", want: ndjsonContentType, wantOK: true},
+		{accept: "application/x-ndjson, text/csv", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv;q=0, application/x-ndjson", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv, application/x-ndjson;q=0.5", want: csvContentType, wantOK: true},
+		{accept: "text/csv;q=0.9, application/x-ndjson;q=0.8", want: csvContentType, wantOK: true},
+		{accept: "application/x-ndjson;q=0, */*", want: csvContentType, wantOK: true},
+		{accept: "*/*;q=0.1, text/csv", want: csvContentType, wantOK: true},
+		{accept: "text/csv;q=0.5, */*", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv;q=0, */*;q=0.5", want: ndjsonContentType, wantOK: true},
+		{accept: "TEXT/CSV", want: csvContentType, wantOK: true},
+		{accept: "text/csv;q=x, application/x-ndjson;q=0.1", want: ndjsonContentType, wantOK: true},
+		{accept: "text/csv;q=0, application/x-ndjson;q=0"},
+		{accept: "*/*;q=0"},
+		{accept: "application/json"},
+		{accept: "text/html, application/xml;q=0.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := negotiateExportType(tt.accept)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("negotiateExportType(%q) = %q, %v, want %q, %v", tt.accept, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// exportSubscriptions returns n subscriptions to export, named service-0 to service-<n-1>.
+func exportSubscriptions(t *testing.T, n int) []*entity.Subscription {
+	t.Helper()
+
+	price, err := entity.ParseMoney("9.99", "USD")
+	if err != nil {
+		t.Fatalf("ParseMoney() error = %v", err)
+	}
+
+	subs := make([]*entity.Subscription, 0, n)
+	for i := range n {
+		subs = append(subs, &entity.Subscription{
+			ID:              uuid.New(),
+			UserID:          uuid.New(),
+			ServiceName:     "service-" + strconv.Itoa(i),
+			Price:           price,
+			BillingPeriod:   entity.BillingPeriodMonthly,
+			BillingInterval: 1,
+			StartDate:       time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+			Status:          entity.SubscriptionStatusActive,
+			Version:         1,
+		})
+	}
+
+	return subs
+}
+
+// exportService streams subs, then fails with err if it is set.
+func exportService(subs []*entity.Subscription, err error) *fakeService {
+	return &fakeService{
+		exportSubscriptions: func(_ context.Context, _ *entity.GetSubscriptionsFilter, _ entity.Sort, fn func(*entity.Subscription) error) error {
+			for _, sub := range subs {
+				if fnErr := fn(sub); fnErr != nil {
+					return fnErr
+				}
+			}
+			return err
+		},
+	}
+}
+
+func getExport(t *testing.T, fake *fakeService, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+
+	return serve(t, fake, r)
+}
+
+func TestExportSubscriptionsCSV(t *testing.T) {
+	// More rows than exportFlushRows, so that the export is flushed while it is written.
+	subs := exportSubscriptions(t, exportFlushRows+1)
+
+	w := getExport(t, exportService(subs, nil), "text/csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != csvContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, csvContentType)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(records) != len(subs)+1 {
+		t.Fatalf("got %d records, want a header and %d rows", len(records), len(subs))
+	}
+	if !slices.Equal(records[0], exportCSVHeader) {
+		t.Fatalf("header = %v, want %v", records[0], exportCSVHeader)
+	}
+
+	last := records[len(records)-1]
+	if last[0] != subs[len(subs)-1].ID.String() || last[2] != subs[len(subs)-1].ServiceName {
+		t.Fatalf("last row = %v, want the last subscription", last)
+	}
+}
+
+func TestExportSubscriptionsNDJSON(t *testing.T) {
+	subs := exportSubscriptions(t, 3)
+
+	w := getExport(t, exportService(subs, nil), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != ndjsonContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, ndjsonContentType)
+	}
+
+	scanner := bufio.NewScanner(w.Body)
+	lines := 0
+	for scanner.Scan() {
+		var dto exportSubscriptionDTO
+		err := json.Unmarshal(scanner.Bytes(), &dto)
+		if err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if dto.ID != subs[lines].ID.String() || dto.Price != "9.99" {
+			t.Fatalf("line %d = %+v, want subscription %s priced 9.99", lines+1, dto, subs[lines].ID)
+		}
+		lines++
+	}
+	if lines != len(subs) {
+		t.Fatalf("got %d lines, want %d", lines, len(subs))
+	}
+}
+
+func TestExportSubscriptionsEmpty(t *testing.T) {
+	w := getExport(t, exportService(nil, nil), "text/csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got, want := strings.TrimSpace(w.Body.String()), strings.Join(exportCSVHeader, ","); got != want {
+		t.Fatalf("body = %q, want only the header %q", got, want)
+	}
+}
+
+func TestExportSubscriptionsErrors(t *testing.T) {
+	failure := errors.New("connection reset")
+
+	t.Run("before the first row", func(t *testing.T) {
+		w := getExport(t, exportService(nil, failure), "text/csv")
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+		}
+	})
+
+	t.Run("after the first row", func(t *testing.T) {
+		w := getExport(t, exportService(exportSubscriptions(t, 2), failure), "application/x-ndjson")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if lines := strings.Count(w.Body.String(), "\n"); lines != 2 {
+			t.Fatalf("got %d lines, want the 2 rows sent before the error", lines)
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := getExport(t, &fakeService{}, "text/csv;q=0, application/json")
+		if w.Code != http.StatusNotAcceptable {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNotAcceptable)
+		}
+	})
+}
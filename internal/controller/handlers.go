@@ -2,53 +2,132 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	_ "github.com/BernsteinMondy/subscription-service/docs"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
 	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
 	"github.com/google/uuid"
 	"github.com/swaggo/http-swagger"
 	"net/http"
 )
 
+// MapHandlers registers the routes, each requiring the permission it is mapped to. Swagger UI is public.
 func (c *controller) MapHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("GET /swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
 
-	mux.HandleFunc("GET /subscriptions", c.getSubscriptions)
-	mux.HandleFunc("GET /subscriptions/{id}", c.getSubscription)
-	mux.HandleFunc("GET /subscriptions/price", c.getSubscriptionsTotalPrice)
+	handle := func(pattern string, permission auth.Permission, handler http.HandlerFunc) {
+		mux.Handle(pattern, c.authorizer.Require(permission, handler))
+	}
 
-	mux.HandleFunc("POST /subscriptions", c.postSubscription)
-	mux.HandleFunc("DELETE /subscriptions/{id}", c.deleteSubscription)
-	mux.HandleFunc("PUT /subscriptions/{id}", c.putSubscription)
+	handle("GET /subscriptions", auth.PermissionSubscriptionsRead, c.getSubscriptions)
+	handle("GET /subscriptions/{id}", auth.PermissionSubscriptionsRead, c.getSubscription)
+	handle("GET /subscriptions/price", auth.PermissionReportsRead, c.getSubscriptionsTotalPrice)
+	handle("GET /subscriptions/report/monthly", auth.PermissionReportsRead, c.getMonthlyReport)
+	handle("GET /subscriptions/export", auth.PermissionSubscriptionsRead, c.exportSubscriptions)
+
+	handle("POST /subscriptions", auth.PermissionSubscriptionsWrite, c.postSubscription)
+	handle("DELETE /subscriptions/{id}", auth.PermissionSubscriptionsWrite, c.deleteSubscription)
+	handle("PUT /subscriptions/{id}", auth.PermissionSubscriptionsWrite, c.putSubscription)
+	handle("PATCH /subscriptions/{id}", auth.PermissionSubscriptionsWrite, c.patchSubscription)
+	handle("POST /subscriptions:batch", auth.PermissionSubscriptionsWrite, c.postSubscriptionsBatch)
+	handle("POST /subscriptions:batchUpdate", auth.PermissionSubscriptionsWrite, c.updateSubscriptionsBatch)
+	handle("POST /subscriptions:batchDelete", auth.PermissionSubscriptionsWrite, c.deleteSubscriptionsBatch)
+	handle("POST /subscriptions/import", auth.PermissionSubscriptionsWrite, c.importSubscriptions)
+
+	handle("GET /subscriptions/{id}/history", auth.PermissionSubscriptionsRead, c.getSubscriptionHistory)
+	handle("POST /subscriptions/{id}/prices", auth.PermissionSubscriptionsWrite, c.postPriceChange)
+	handle("POST /subscriptions/{id}/activate", auth.PermissionSubscriptionsWrite, c.activateSubscription)
+	handle("POST /subscriptions/{id}/pause", auth.PermissionSubscriptionsWrite, c.pauseSubscription)
+	handle("POST /subscriptions/{id}/resume", auth.PermissionSubscriptionsWrite, c.resumeSubscription)
+	handle("POST /subscriptions/{id}/cancel", auth.PermissionSubscriptionsWrite, c.cancelSubscription)
+
+	handle("DELETE /admin/subscriptions/{id}", auth.PermissionAdmin, c.hardDeleteSubscription)
+	handle("POST /admin/subscriptions/expire", auth.PermissionAdmin, c.expireSubscriptions)
+
+	handle("GET /admin/exchange-rates", auth.PermissionAdmin, c.getExchangeRates)
+	handle("PUT /admin/exchange-rates", auth.PermissionAdmin, c.putExchangeRates)
+	handle("POST /admin/exchange-rates/import", auth.PermissionAdmin, c.importExchangeRates)
+	handle("DELETE /admin/exchange-rates/{base}/{quote}/{effective_from}", auth.PermissionAdmin, c.deleteExchangeRate)
+
+	handle("GET /admin/role-assignments", auth.PermissionAdmin, c.getRoleAssignments)
+	handle("PUT /admin/role-assignments/{subject}/{role}", auth.PermissionAdmin, c.putRoleAssignment)
+	handle("DELETE /admin/role-assignments/{subject}/{role}", auth.PermissionAdmin, c.deleteRoleAssignment)
 }
 
 // GetSubscriptions godoc
-// @Summary Get all subscriptions
-// @Description Retrieve all subscriptions
+// @Summary Get subscriptions
+// @Description Retrieve a page of subscriptions. Pass next_cursor from the previous response as cursor to get the next page.
 // @Tags subscriptions
 // @Produce json
-// @Success 200 {object} getSubscriptionsResponseDTO "Array of subscriptions"
-// @Failure 500 "Internal Server Error - Returns only status code"
+// @Param limit query int false "Page size (1-1000, default 100)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param sort query string false "Sort field and order: start_date, end_date, price or service_name, optionally followed by :asc or :desc (default start_date:asc)"
+// @Param user_id query []string false "Filter by User IDs, repeated or comma-separated" collectionFormat(multi)
+// @Param service_name query []string false "Filter by Service names" collectionFormat(multi)
+// @Param start_date query string false "Start of the date range (MM-YYYY)"
+// @Param end_date query string false "End of the date range (MM-YYYY)"
+// @Param match query string false "How the date range is matched: contained (default) - whole period inside the range, overlaps - active at any point of the range, active_at - active in the start_date month" Enums(contained, overlaps, active_at)
+// @Param price_min query string false "Minimum price, decimal in the subscription's currency"
+// @Param price_max query string false "Maximum price, decimal in the subscription's currency"
+// @Param status query []string false "Filter by status (default: every status except cancelled)" collectionFormat(multi) Enums(trial, active, paused, cancelled, expired)
+// @Success 200 {object} getSubscriptionsResponseDTO "Page of subscriptions"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions [get]
 func (c *controller) getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		handleError(w, invalidField("limit", err))
+		return
+	}
+
+	sort, err := parseSort(query.Get("sort"))
+	if err != nil {
+		handleError(w, invalidField("sort", err))
+		return
+	}
+
+	filter, err := parseSubscriptionsFilter(query)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = defaultListStatuses
+	}
+
+	params := &entity.ListSubscriptionsParams{
+		Filter: filter,
+		Sort:   sort,
+		Limit:  limit,
+	}
+
+	cursorStr := query.Get("cursor")
+	if cursorStr != "" {
+		params.Cursor, err = decodeCursor(cursorStr)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+	}
+
 	ctx := r.Context()
-	subs, err := c.service.GetAllSubscriptions(ctx)
+	page, err := c.service.ListSubscriptions(ctx, params)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	subscriptionsResult := make([]getSubscriptionReadDTO, 0, len(subs))
-	for _, sub := range subs {
-		subscriptionsResult = append(subscriptionsResult, getSubscriptionReadDTO{
-			ID:          sub.ID.String(),
-			UserID:      sub.UserID.String(),
-			ServiceName: sub.ServiceName,
-			Price:       int(sub.Price),
-			StartDate:   sub.StartDate.Format(timeFormat),
-			EndDate:     sub.EndDate.Format(timeFormat),
-		})
+	subscriptionsResult := make([]getSubscriptionReadDTO, 0, len(page.Subscriptions))
+	for _, sub := range page.Subscriptions {
+		subscriptionsResult = append(subscriptionsResult, newSubscriptionReadDTO(&sub))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -56,6 +135,10 @@ func (c *controller) getSubscriptions(w http.ResponseWriter, r *http.Request) {
 	var resp = getSubscriptionsResponseDTO{
 		Subscriptions: subscriptionsResult,
 	}
+	if page.NextCursor != nil {
+		resp.NextCursor = encodeCursor(page.NextCursor)
+	}
+
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -67,61 +150,161 @@ func (c *controller) getSubscriptions(w http.ResponseWriter, r *http.Request) {
 
 // GetSubscriptionsTotalPrice godoc
 // @Summary Get total price of subscriptions
-// @Description Calculate total price of subscriptions with filtering
+// @Description Calculate total price of subscriptions with filtering.
+// @Description Paused periods are left out: monthly_accrual skips the months a subscription stays paused throughout, and sum skips a subscription that stays paused for all of its months within the range.
+// @Description Paused periods are left out: monthly_accrual skips the months a subscription stays paused throughout, and sum skips subscriptions paused for all of their months within the range (with no end_date, paused and not resumed).
+// @Description Prices of weekly, quarterly and yearly subscriptions are normalised to a month.
+// @Description With target_currency, monthly_accrual converts every month at the rate in effect for that month; sum converts at the rate of the month the subscription enters the range.
 // @Tags subscriptions
 // @Produce json
-// @Param service_name query string false "Filter by Service name"
-// @Param user_id query string false "User ID" Format(uuid)
+// @Param service_name query []string false "Filter by Service names" collectionFormat(multi)
+// @Param user_id query []string false "Filter by User IDs, repeated or comma-separated" collectionFormat(multi)
 // @Param start_date query string true "Start date (MM-YYYY)"
-// @Param end_date query string true "End date (MM-YYYY)"
+// @Param end_date query string true "End date (MM-YYYY), optional with match=active_at"
+// @Param match query string false "How the date range is matched: contained (default) - whole period inside the range, overlaps - active at any point of the range, active_at - active in the start_date month" Enums(contained, overlaps, active_at)
+// @Param price_min query string false "Minimum price, decimal in the subscription's currency"
+// @Param price_max query string false "Maximum price, decimal in the subscription's currency"
+// @Param mode query string false "Total calculation mode (default sum); monthly_accrual defaults match to overlaps" Enums(sum, monthly_accrual)
+// @Param target_currency query string false "Convert prices into this currency (ISO 4217); required when subscriptions use different currencies"
 // @Success 200 {object} getTotalPriceResponseDTO "Total price of all the subscriptions"
-// @Failure 400 "Bad Request"
-// @Failure 500 "Internal Server Error"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 422 {object} problemDTO "Mixed currencies without target_currency, or a missing exchange rate"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions/price [get]
 func (c *controller) getSubscriptionsTotalPrice(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
-	filter := &entity.GetSubscriptionsFilter{}
+	filter, err := parseSubscriptionsFilter(query)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if filter.StartDate.IsZero() || filter.EndDate.IsZero() {
+		handleError(w, errors.Join(requiredQueryParam(query, "start_date"), requiredQueryParam(query, "end_date")))
+		return
+	}
+
+	mode, err := parseTotalMode(query.Get("mode"))
+	if err != nil {
+		handleError(w, invalidField("mode", err))
+		return
+	}
+
+	// Accrual counts the months a subscription spends inside the range, so by default it should also see the
+	// subscriptions that only partially overlap it.
+	if mode == entity.TotalModeMonthlyAccrual && query.Get("match") == "" {
+		filter.Match = entity.DateMatchOverlaps
+	}
+
+	targetCurrency, err := parseOptionalCurrency(query.Get("target_currency"), "")
+	if err != nil {
+		handleError(w, invalidField("target_currency", err))
+		return
+	}
+
+	ctx := r.Context()
+	totalPrice, err := c.service.GetSubscriptionsTotalSumFilter(ctx, filter, mode, targetCurrency)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var resp = getTotalPriceResponseDTO{
+		TotalPrice: totalPrice.String(),
+		Currency:   totalPrice.Currency,
+	}
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	return
+}
+
+// GetMonthlyReport godoc
+// @Summary Get monthly spend report
+// @Description For every month of the range returns the total price, the number of active subscriptions and a per-service breakdown
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "User ID" Format(uuid)
+// @Param start_date query string true "First month of the report (MM-YYYY)"
+// @Param end_date query string true "Last month of the report (MM-YYYY)"
+// @Param target_currency query string false "Convert prices into this currency (ISO 4217) at the rate of each month"
+// @Success 200 {object} monthlyReportResponseDTO
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 422 {object} problemDTO "Mixed currencies without target_currency, or a missing exchange rate"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/report/monthly [get]
+func (c *controller) getMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	startDate, endDate, err := parseStartAndEndDate(query.Get("start_date"), query.Get("end_date"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
 
-	startDateStr := query.Get("start_date")
-	endDateStr := query.Get("end_date")
-	serviceName := query.Get("service_name")
+	if endDate.After(startDate.AddDate(0, maxReportMonths-1, 0)) {
+		handleError(w, invalidField("end_date", fmt.Errorf("report covers at most %d months", maxReportMonths)))
+		return
+	}
 
-	if serviceName != "" {
-		filter.ServiceName = serviceName
+	filter := &entity.GetSubscriptionsFilter{
+		StartDate: startDate,
+		EndDate:   endDate,
 	}
 
 	userIDStr := query.Get("user_id")
 	if userIDStr != "" {
 		userID, err := uuid.Parse(userIDStr)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			handleError(w, invalidField("user_id", err))
 			return
 		}
-		filter.UserID = userID
+		filter.UserIDs = []uuid.UUID{userID}
 	}
 
-	startDate, endDate, err := parseStartAndEndDate(startDateStr, endDateStr)
+	targetCurrency, err := parseOptionalCurrency(query.Get("target_currency"), "")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("target_currency", err))
 		return
 	}
 
-	filter.StartDate = startDate
-	filter.EndDate = endDate
-
 	ctx := r.Context()
-	totalPrice, err := c.service.GetSubscriptionsTotalSumFilter(ctx, filter)
+	report, err := c.service.GetMonthlyReport(ctx, filter, targetCurrency)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	var resp = monthlyReportResponseDTO{
+		Currency: report.Currency,
+		Months:   make([]monthReportDTO, 0, len(report.Months)),
+	}
+	for _, month := range report.Months {
+		services := make([]serviceSpendDTO, 0, len(month.Services))
+		for _, spend := range month.Services {
+			services = append(services, serviceSpendDTO{
+				ServiceName:         spend.ServiceName,
+				TotalPrice:          entity.Money{Amount: spend.TotalPrice, Currency: report.Currency}.String(),
+				ActiveSubscriptions: spend.ActiveSubscriptions,
+			})
+		}
 
-	var resp = getTotalPriceResponseDTO{
-		TotalPrice: int(totalPrice),
+		resp.Months = append(resp.Months, monthReportDTO{
+			Month:               month.Month.Format(timeFormat),
+			TotalPrice:          entity.Money{Amount: month.TotalPrice, Currency: report.Currency}.String(),
+			ActiveSubscriptions: month.ActiveSubscriptions,
+			Services:            services,
+		})
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -138,16 +321,17 @@ func (c *controller) getSubscriptionsTotalPrice(w http.ResponseWriter, r *http.R
 // @Produce json
 // @Param id path string true "Subscription ID" Format(uuid)
 // @Success 200 {object} getSubscriptionReadDTO
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {string} ETag "Subscription version, usable in If-Match"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions/{id} [get]
 func (c *controller) getSubscription(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("id", err))
 		return
 	}
 
@@ -158,16 +342,10 @@ func (c *controller) getSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var resp = getSubscriptionReadDTO{
-		ID:          sub.ID.String(),
-		UserID:      sub.UserID.String(),
-		ServiceName: sub.ServiceName,
-		Price:       int(sub.Price),
-		StartDate:   sub.StartDate.Format(timeFormat),
-		EndDate:     sub.EndDate.Format(timeFormat),
-	}
+	var resp = newSubscriptionReadDTO(sub)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(sub.Version))
 
 	err = json.NewEncoder(w).Encode(&resp)
 	if err != nil {
@@ -181,57 +359,52 @@ func (c *controller) getSubscription(w http.ResponseWriter, r *http.Request) {
 
 // CreateSubscription godoc
 // @Summary Create a new subscription
-// @Description Create a new subscription for a user
+// @Description Create a new subscription for a user. Omit end_date for an open-ended subscription.
+// @Description price is a decimal string in major units, e.g. "499.90", charged in currency (ISO 4217, default RUB) every billing_interval billing_periods (default: every month).
+// @Description status is active by default; create the subscription as trial and activate it later when the trial ends.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Description Send an Idempotency-Key to retry safely: a repeated request with the same key returns the subscription created first.
 // @Param subscription body createSubscriptionRequestDTO true "Subscription data"
+// @Param Idempotency-Key header string false "Unique key of this create request, up to 255 characters"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
 // @Success 201 {object} createSubscriptionResponseDTO "Returns the ID of the created subscription"
-// @Failure 400 "Bad Request"
-// @Failure 500 "Internal Server Error"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 403 {object} problemDTO "user_id is not the caller's own user ID"
+// @Failure 422 {object} problemDTO "Idempotency-Key was already used with a different request"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions [post]
 func (c *controller) postSubscription(w http.ResponseWriter, r *http.Request) {
-	var req createSubscriptionRequestDTO
-
-	err := json.NewDecoder(r.Body).Decode(&req)
+	idempotencyKey, err := parseIdempotencyKey(r.Header.Get("Idempotency-Key"))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("Idempotency-Key", err))
 		return
 	}
 
-	userID, err := uuid.Parse(req.UserID)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	var req createSubscriptionRequestDTO
 
-	startDate, endDate, err := parseStartAndEndDate(req.StartDate, req.EndDate)
+	err = decodeJSONBody(r.Body, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, err)
 		return
 	}
 
-	if req.Price < 0 {
-		w.WriteHeader(http.StatusBadRequest)
+	data, err := parseCreateSubscription(&req)
+	if err != nil {
+		handleError(w, err)
 		return
 	}
 
-	data := &entity.CreateSubscriptionData{
-		UserID:      userID,
-		ServiceName: req.ServiceName,
-		Price:       int32(req.Price),
-		StartDate:   startDate,
-		EndDate:     endDate,
-	}
-
 	ctx := r.Context()
-	id, err := c.service.NewSubscription(ctx, data)
+	id, err := c.service.NewSubscription(ctx, data, idempotencyKey, actorFromRequest(r))
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 
 	var resp = createSubscriptionResponseDTO{
 		ID: id.String(),
@@ -242,32 +415,91 @@ func (c *controller) postSubscription(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	return
 }
 
 // DeleteSubscription godoc
-// @Summary Delete a subscription
-// @Description Cancel and remove a subscription by ID
+// @Summary Cancel a subscription
+// @Description Cancel a subscription by ID. The subscription is kept with status cancelled and ends with the current month, or with the last month of its current billing cycle if at_period_end is set.
+// @Description Cancelled subscriptions are hidden from the subscription list by default but still count towards totals and reports of the months they were active.
 // @Tags subscriptions
 // @Param id path string true "Subscription ID" Format(uuid)
+// @Param at_period_end query bool false "End the subscription with its current billing cycle instead of the current month"
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
 // @Success 200 "OK"
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Subscription is already cancelled or expired"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions/{id} [delete]
 func (c *controller) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("id", err))
+		return
+	}
+
+	atPeriodEnd, err := parseOptionalBool(r.URL.Query().Get("at_period_end"))
+	if err != nil {
+		handleError(w, invalidField("at_period_end", err))
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
 		return
 	}
 
 	ctx := r.Context()
-	err = c.service.CancelSubscription(ctx, id)
+	version, err := c.service.CancelSubscription(ctx, id, atPeriodEnd, actorFromRequest(r), ifMatch)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(version))
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// HardDeleteSubscription godoc
+// @Summary Permanently delete a subscription
+// @Description Remove a subscription and its scheduled prices. Unlike cancellation, the subscription no longer counts towards any totals or reports.
+// @Description Its history is kept: a deleted event is recorded and GET /subscriptions/{id}/history stays available.
+// @Description Only callers with the admin:* permission may use it; MapHandlers guards the route.
+// @Tags admin
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 403 {object} problemDTO "Caller lacks the admin:* permission"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/subscriptions/{id} [delete]
+func (c *controller) hardDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		handleError(w, invalidField("id", err))
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
+		return
+	}
+
+	ctx := r.Context()
+	err = c.service.DeleteSubscription(ctx, id, ifMatch, actorFromRequest(r))
 	if err != nil {
 		handleError(w, err)
 		return
@@ -279,54 +511,141 @@ func (c *controller) deleteSubscription(w http.ResponseWriter, r *http.Request)
 
 // UpdateSubscription godoc
 // @Summary Update a subscription
-// @Description Update an existing subscription by ID
+// @Description Update an existing subscription by ID. Omit end_date to make the subscription open-ended.
+// @Description While price changes are scheduled, the currency cannot change and new start and end dates must keep every change after start_date and not after end_date.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription ID (UUID)"
 // @Param subscription body updateSubscriptionCreateDTO true "Updated subscription data"
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
 // @Success 200 "OK"
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Update conflicts with the scheduled prices"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
 // @Router /subscriptions/{id} [put]
 func (c *controller) putSubscription(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("id", err))
 		return
 	}
 
 	var req updateSubscriptionCreateDTO
 
-	err = json.NewDecoder(r.Body).Decode(&req)
+	err = decodeJSONBody(r.Body, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	data, err := parseUpdateSubscription(&req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
+		return
+	}
+
+	ctx := r.Context()
+	version, err := c.service.UpdateSubscription(ctx, id, data, ifMatch, actorFromRequest(r))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, err)
 		return
 	}
 
-	startDate, endDate, err := parseStartAndEndDate(req.StartDate, req.EndDate)
+	w.Header().Set("ETag", formatETag(version))
+	w.WriteHeader(http.StatusOK)
+	return
+}
+
+// PatchSubscription godoc
+// @Summary Partially update a subscription
+// @Description Update only the fields present in a JSON Merge Patch (RFC 7396) body. A null end_date makes the subscription open-ended.
+// @Description The patched subscription is validated as a whole, and checked against the scheduled prices like PUT.
+// @Tags subscriptions
+// @Accept application/merge-patch+json
+// @Param id path string true "Subscription ID (UUID)"
+// @Param patch body updateSubscriptionCreateDTO true "Fields to change"
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Update conflicts with the scheduled prices"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 415 {object} problemDTO "Unsupported Media Type"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id} [patch]
+func (c *controller) patchSubscription(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		handleError(w, invalidField("id", err))
 		return
 	}
 
-	data := &entity.UpdateSubscriptionData{
-		ServiceName: req.ServiceName,
-		Price:       int32(req.Price),
-		StartDate:   startDate,
-		EndDate:     endDate,
+	if !isMergePatchContentType(r.Header.Get("Content-Type")) {
+		handleError(w, unsupportedMediaType(mergePatchContentType))
+		return
+	}
+
+	patch, err := decodeMergePatch(r.Body)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
+		return
 	}
 
 	ctx := r.Context()
-	err = c.service.UpdateSubscription(ctx, id, data)
+	sub, err := c.service.GetSubscription(ctx, id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !ifMatchAllows(ifMatch, sub.Version) {
+		handleError(w, srvc.ErrPreconditionFailed)
+		return
+	}
+
+	req, err := applySubscriptionMergePatch(newUpdateSubscriptionDTO(sub), patch)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	data, err := parseUpdateSubscription(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// The patch was merged into this version; writing it over a newer one would lose that update.
+	version, err := c.service.UpdateSubscription(ctx, id, data, []int64{sub.Version}, actorFromRequest(r))
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", formatETag(version))
 	w.WriteHeader(http.StatusOK)
 	return
 }
@@ -0,0 +1,524 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/BernsteinMondy/subscription-service/internal/middleware"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubAuthorizer enforces permissions with the real middleware but never calls the handlers, so routing and
+// permissions can be tested without a service.
+type stubAuthorizer struct {
+	authorizer *middleware.Authorizer
+}
+
+func (a stubAuthorizer) Require(permission auth.Permission, _ http.Handler) http.Handler {
+	return a.authorizer.Require(permission, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Permission", string(permission))
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// fakeRoleStore maps subjects to their assigned roles.
+type fakeRoleStore map[string][]string
+
+func (f fakeRoleStore) GetSubjectRoles(_ context.Context, subject string) ([]string, error) {
+	return f[subject], nil
+}
+
+var routePermissions = []struct {
+	method     string
+	path       string
+	permission auth.Permission
+}{
+	{http.MethodGet, "/subscriptions", auth.PermissionSubscriptionsRead},
+	{http.MethodGet, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e", auth.PermissionSubscriptionsRead},
+	{http.MethodGet, "/subscriptions/price", auth.PermissionReportsRead},
+	{http.MethodGet, "/subscriptions/report/monthly", auth.PermissionReportsRead},
+	{http.MethodGet, "/subscriptions/export", auth.PermissionSubscriptionsRead},
+	{http.MethodGet, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/history", auth.PermissionSubscriptionsRead},
+
+	{http.MethodPost, "/subscriptions", auth.PermissionSubscriptionsWrite},
+	{http.MethodDelete, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e", auth.PermissionSubscriptionsWrite},
+	{http.MethodPut, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e", auth.PermissionSubscriptionsWrite},
+	{http.MethodPatch, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions:batch", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions:batchUpdate", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions:batchDelete", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/import", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/prices", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/activate", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/pause", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/resume", auth.PermissionSubscriptionsWrite},
+	{http.MethodPost, "/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e/cancel", auth.PermissionSubscriptionsWrite},
+
+	{http.MethodDelete, "/admin/subscriptions/b6fa4d7c-8f90-4f92-912e-92c644c57a1e", auth.PermissionAdmin},
+	{http.MethodPost, "/admin/subscriptions/expire", auth.PermissionAdmin},
+	{http.MethodGet, "/admin/exchange-rates", auth.PermissionAdmin},
+	{http.MethodPut, "/admin/exchange-rates", auth.PermissionAdmin},
+	{http.MethodPost, "/admin/exchange-rates/import", auth.PermissionAdmin},
+	{http.MethodDelete, "/admin/exchange-rates/USD/RUB/08-2025", auth.PermissionAdmin},
+	{http.MethodGet, "/admin/role-assignments", auth.PermissionAdmin},
+	{http.MethodPut, "/admin/role-assignments/alice/operator", auth.PermissionAdmin},
+	{http.MethodDelete, "/admin/role-assignments/alice/operator", auth.PermissionAdmin},
+}
+
+func TestRoutePermissions(t *testing.T) {
+	roles := []string{auth.RoleAdmin, auth.RoleOperator, auth.RoleReadOnly, auth.RoleUser}
+
+	grants := map[string]map[auth.Permission]bool{
+		auth.RoleAdmin: {
+			auth.PermissionSubscriptionsRead:  true,
+			auth.PermissionSubscriptionsWrite: true,
+			auth.PermissionReportsRead:        true,
+			auth.PermissionAdmin:              true,
+		},
+		auth.RoleOperator: {
+			auth.PermissionSubscriptionsRead:  true,
+			auth.PermissionSubscriptionsWrite: true,
+			auth.PermissionReportsRead:        true,
+		},
+		auth.RoleReadOnly: {
+			auth.PermissionSubscriptionsRead: true,
+			auth.PermissionReportsRead:       true,
+		},
+		auth.RoleUser: {
+			auth.PermissionSubscriptionsRead:  true,
+			auth.PermissionSubscriptionsWrite: true,
+			auth.PermissionReportsRead:        true,
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(nil, stubAuthorizer{middleware.NewAuthorizer(fakeRoleStore{})}).MapHandlers(mux)
+
+	for _, route := range routePermissions {
+		for _, role := range roles {
+			t.Run(route.method+" "+route.path+" as "+role, func(t *testing.T) {
+				r := httptest.NewRequest(route.method, route.path, nil)
+				r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Subject: "alice", Roles: []string{role}}))
+				w := httptest.NewRecorder()
+
+				mux.ServeHTTP(w, r)
+
+				if !grants[role][route.permission] {
+					assertForbidden(t, w, route.permission)
+					return
+				}
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+				}
+				if got := w.Header().Get("X-Permission"); got != string(route.permission) {
+					t.Fatalf("route requires %q, want %q", got, route.permission)
+				}
+			})
+		}
+	}
+}
+
+func TestSwaggerIsPublic(t *testing.T) {
+	mux := http.NewServeMux()
+	New(nil, stubAuthorizer{middleware.NewAuthorizer(fakeRoleStore{})}).MapHandlers(mux)
+
+	r := httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil)
+	r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Subject: "alice", Roles: []string{auth.RoleReadOnly}}))
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, r)
+
+	if w.Code == http.StatusForbidden || w.Header().Get("X-Permission") != "" {
+		t.Fatalf("swagger UI requires a permission")
+	}
+}
+
+func assertForbidden(t *testing.T, w *httptest.ResponseRecorder, permission auth.Permission) {
+	t.Helper()
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	var problem map[string]any
+	err := json.NewDecoder(w.Body).Decode(&problem)
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	if problem["permission"] != string(permission) {
+		t.Fatalf("problem names permission %v, want %q", problem["permission"], permission)
+	}
+}
+
+func TestDeleteSubscriptionCancels(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name            string
+		query           string
+		wantAtPeriodEnd bool
+	}{
+		{name: "now", query: ""},
+		{name: "at period end", query: "?at_period_end=true", wantAtPeriodEnd: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cancelled bool
+			srvc := &fakeService{
+				cancelSubscription: func(_ context.Context, gotID uuid.UUID, atPeriodEnd bool, actor string, ifMatch []int64) (int64, error) {
+					if gotID != id || atPeriodEnd != tt.wantAtPeriodEnd || actor != "bob" {
+						t.Errorf("CancelSubscription(%v, %v, %q), want (%v, %v, bob)", gotID, atPeriodEnd, actor, id, tt.wantAtPeriodEnd)
+					}
+					if len(ifMatch) != 1 || ifMatch[0] != 3 {
+						t.Errorf("ifMatch = %v, want [3]", ifMatch)
+					}
+					cancelled = true
+					return 4, nil
+				},
+				deleteSubscription: func(context.Context, uuid.UUID, []int64, string) error {
+					t.Error("DELETE /subscriptions/{id} deleted the subscription")
+					return nil
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodDelete, "/subscriptions/"+id.String()+tt.query, nil)
+			r.Header.Set("If-Match", `"3"`)
+			r.Header.Set("X-Actor", "bob")
+
+			w := serve(t, srvc, r)
+
+			if w.Code != http.StatusOK || !cancelled {
+				t.Fatalf("status = %d, cancelled = %v, want 200 and cancelled", w.Code, cancelled)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"4"` {
+				t.Fatalf("ETag = %s, want \"4\"", etag)
+			}
+		})
+	}
+}
+
+func TestHardDeleteSubscriptionRequiresAdmin(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		role        string
+		wantDeleted bool
+	}{
+		{role: auth.RoleAdmin, wantDeleted: true},
+		{role: auth.RoleOperator},
+		{role: auth.RoleUser},
+		{role: auth.RoleReadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			var deleted bool
+			srvc := &fakeService{
+				deleteSubscription: func(context.Context, uuid.UUID, []int64, string) error {
+					deleted = true
+					return nil
+				},
+			}
+
+			mux := http.NewServeMux()
+			New(srvc, middleware.NewAuthorizer(fakeRoleStore{})).MapHandlers(mux)
+
+			r := httptest.NewRequest(http.MethodDelete, "/admin/subscriptions/"+id.String(), nil)
+			r = r.WithContext(auth.WithIdentity(r.Context(), &auth.Identity{Subject: "alice", Roles: []string{tt.role}}))
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, r)
+
+			if deleted != tt.wantDeleted {
+				t.Fatalf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if !tt.wantDeleted {
+				assertForbidden(t, w, auth.PermissionAdmin)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsHidesCancelledByDefault(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		wantStatuses []entity.SubscriptionStatus
+	}{
+		{
+			name:         "no status filter",
+			query:        "",
+			wantStatuses: defaultListStatuses,
+		},
+		{
+			name:         "cancelled requested",
+			query:        "?status=cancelled",
+			wantStatuses: []entity.SubscriptionStatus{entity.SubscriptionStatusCancelled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var statuses []entity.SubscriptionStatus
+			srvc := &fakeService{
+				listSubscriptions: func(_ context.Context, params *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error) {
+					statuses = params.Filter.Statuses
+					return &entity.SubscriptionsPage{}, nil
+				},
+			}
+
+			w := serve(t, srvc, httptest.NewRequest(http.MethodGet, "/subscriptions"+tt.query, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Fatalf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+
+	if slices.Contains(defaultListStatuses, entity.SubscriptionStatusCancelled) {
+		t.Fatalf("defaultListStatuses = %v, want cancelled left out", defaultListStatuses)
+	}
+}
+
+func TestGetSubscriptionsInvalidCursor(t *testing.T) {
+	fake := &fakeService{
+		listSubscriptions: func(context.Context, *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error) {
+			t.Fatal("ListSubscriptions called with an invalid cursor")
+			return nil, nil
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions?sort=price&cursor="+rawCursor("price", "1e3"), nil)
+	w := serve(t, fake, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+
+	var problem problemDTO
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("unmarshal problem: %v", err)
+	}
+	if problem.Code != codeInvalidCursor {
+		t.Fatalf("code = %q, want %q", problem.Code, codeInvalidCursor)
+	}
+}
+
+func TestJSONBodiesRejectUnknownFields(t *testing.T) {
+	id := uuid.New().String()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{name: "create", method: http.MethodPost, target: "/subscriptions", body: `{"service_name":"Netflix","plan":"basic"}`},
+		{name: "update", method: http.MethodPut, target: "/subscriptions/" + id, body: `{"service_name":"Netflix","plan":"basic"}`},
+		{name: "batch delete", method: http.MethodPost, target: "/subscriptions:batchDelete?mode=partial", body: `{"ids":["` + id + `"],"plan":"basic"}`},
+		{name: "price change", method: http.MethodPost, target: "/subscriptions/" + id + "/prices", body: `{"price":"12.00","effective_from":"01-2026","plan":"basic"}`},
+		{name: "exchange rates", method: http.MethodPut, target: "/admin/exchange-rates", body: `{"rates":[],"plan":"basic"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := serve(t, &fakeService{}, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+
+			var problem problemDTO
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if problem.Code != codeValidationFailed || len(problem.Errors) != 1 || problem.Errors[0].Field != "plan" {
+				t.Fatalf("problem = %+v, want a validation error for plan", problem)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsTotalPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantMode  entity.TotalMode
+		wantMatch entity.DateMatch
+	}{
+		{name: "sum", query: "start_date=01-2025&end_date=12-2025", wantMode: entity.TotalModeSum, wantMatch: entity.DateMatchContained},
+		{
+			name:      "accrual overlaps by default",
+			query:     "start_date=01-2025&end_date=12-2025&mode=monthly_accrual",
+			wantMode:  entity.TotalModeMonthlyAccrual,
+			wantMatch: entity.DateMatchOverlaps,
+		},
+		{
+			name:      "accrual keeps an explicit match",
+			query:     "start_date=01-2025&end_date=12-2025&mode=monthly_accrual&match=contained",
+			wantMode:  entity.TotalModeMonthlyAccrual,
+			wantMatch: entity.DateMatchContained,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotFilter *entity.GetSubscriptionsFilter
+				gotMode   entity.TotalMode
+			)
+			fake := &fakeService{
+				getSubscriptionsTotalSumFilter: func(_ context.Context, filter *entity.GetSubscriptionsFilter, mode entity.TotalMode, _ string) (*entity.Money, error) {
+					gotFilter, gotMode = filter, mode
+					return &entity.Money{Amount: 12345, Currency: "RUB"}, nil
+				},
+			}
+
+			w := serve(t, fake, httptest.NewRequest(http.MethodGet, "/subscriptions/price?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+			}
+			if gotMode != tt.wantMode || gotFilter.Match != tt.wantMatch {
+				t.Fatalf("mode, match = %q, %q, want %q, %q", gotMode, gotFilter.Match, tt.wantMode, tt.wantMatch)
+			}
+
+			var resp getTotalPriceResponseDTO
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatalf("unmarshal response: %v", err)
+			}
+			if resp.TotalPrice != "123.45" || resp.Currency != "RUB" {
+				t.Fatalf("response = %+v, want 123.45 RUB", resp)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsTotalPriceRequiresRange(t *testing.T) {
+	w := serve(t, &fakeService{}, httptest.NewRequest(http.MethodGet, "/subscriptions/price?mode=monthly_accrual", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	var problem problemDTO
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("unmarshal problem: %v", err)
+	}
+
+	fields := make([]string, 0, len(problem.Errors))
+	for _, fe := range problem.Errors {
+		fields = append(fields, fe.Field)
+	}
+	if !slices.Equal(fields, []string{"start_date", "end_date"}) {
+		t.Fatalf("invalid fields = %v, want start_date and end_date", fields)
+	}
+}
+
+func TestGetMonthlyReportRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantField string
+	}{
+		{name: "longest range", query: "start_date=01-2020&end_date=12-2029"},
+		{name: "too long", query: "start_date=01-2020&end_date=01-2030", wantField: "end_date"},
+		{name: "inverted", query: "start_date=02-2025&end_date=01-2025", wantField: "end_date"},
+		{name: "missing start", query: "end_date=01-2025", wantField: "start_date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeService{
+				getMonthlyReport: func(context.Context, *entity.GetSubscriptionsFilter, string) (*entity.MonthlyReport, error) {
+					return &entity.MonthlyReport{Currency: "RUB"}, nil
+				},
+			}
+
+			w := serve(t, fake, httptest.NewRequest(http.MethodGet, "/subscriptions/report/monthly?"+tt.query, nil))
+			if tt.wantField == "" {
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+				}
+				return
+			}
+
+			var problem problemDTO
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if w.Code != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != tt.wantField {
+				t.Fatalf("status %d, problem %+v, want 400 for %s", w.Code, problem, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestPostSubscriptionIdempotencyKey(t *testing.T) {
+	body := `{"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","service_name":"Netflix","price":"9.99","start_date":"07-2025"}`
+
+	tests := []struct {
+		name       string
+		key        string
+		serviceErr error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "no key", wantStatus: http.StatusCreated},
+		{name: "key", key: "retry-1", wantStatus: http.StatusCreated},
+		{name: "invalid key", key: "retry 1", wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed},
+		{name: "reused key", key: "retry-1", serviceErr: srvc.ErrIdempotencyKeyReused, wantStatus: http.StatusUnprocessableEntity, wantCode: codeIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotKey *string
+			fake := &fakeService{
+				newSubscription: func(_ context.Context, _ *entity.CreateSubscriptionData, idempotencyKey, _ string) (uuid.UUID, error) {
+					gotKey = &idempotencyKey
+					return uuid.New(), tt.serviceErr
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				r.Header.Set("Idempotency-Key", tt.key)
+			}
+
+			w := serve(t, fake, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantCode != "" {
+				var problem problemDTO
+				err := json.Unmarshal(w.Body.Bytes(), &problem)
+				if err != nil {
+					t.Fatalf("unmarshal problem: %v", err)
+				}
+				if problem.Code != tt.wantCode {
+					t.Fatalf("code = %q, want %q", problem.Code, tt.wantCode)
+				}
+			}
+			if tt.wantCode != codeValidationFailed && (gotKey == nil || *gotKey != tt.key) {
+				t.Fatalf("key passed to the service = %v, want %q", gotKey, tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// importColumn is a field of createSubscriptionRequestDTO read from an imported CSV file. The column holding it is
+// named by the <field>_column query parameter and defaults to the field's name.
+type importColumn struct {
+	field    string
+	required bool
+	set      func(req *createSubscriptionRequestDTO, value string) error
+}
+
+var importColumns = []importColumn{
+	{field: "user_id", required: true, set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.UserID = value
+		return nil
+	}},
+	{field: "service_name", required: true, set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.ServiceName = value
+		return nil
+	}},
+	{field: "price", required: true, set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.Price = amountDTO(value)
+		return nil
+	}},
+	{field: "start_date", required: true, set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.StartDate = value
+		return nil
+	}},
+	{field: "end_date", set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.EndDate = value
+		return nil
+	}},
+	{field: "currency", set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.Currency = value
+		return nil
+	}},
+	{field: "billing_period", set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.BillingPeriod = value
+		return nil
+	}},
+	{field: "billing_interval", set: func(req *createSubscriptionRequestDTO, value string) error {
+		if value == "" {
+			return nil
+		}
+
+		interval, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("billing interval parse failed: %w", err)
+		}
+		req.BillingInterval = interval
+		return nil
+	}},
+	{field: "status", set: func(req *createSubscriptionRequestDTO, value string) error {
+		req.Status = value
+		return nil
+	}},
+}
+
+// ImportSubscriptions godoc
+// @Summary Import subscriptions from CSV
+// @Description Create subscriptions from a CSV file with a header row. Each row is validated like POST /subscriptions.
+// @Description The columns are found by name in the header; the <field>_column parameters map a field to a differently named column. user_id, service_name, price and start_date are required, the other fields are optional.
+// @Description Other columns, such as id, cancelled_at and version of a GET /subscriptions/export file, are ignored and every row creates a new subscription. status may only be trial or active.
+// @Description With dry_run=true the file is only validated. Otherwise the subscriptions are created in one transaction, and if any row is invalid none of them are.
+// @Tags subscriptions
+// @Accept text/csv
+// @Produce json
+// @Param dry_run query bool false "Validate the file without creating subscriptions"
+// @Param user_id_column query string false "Column holding user_id" default(user_id)
+// @Param service_name_column query string false "Column holding service_name" default(service_name)
+// @Param price_column query string false "Column holding price" default(price)
+// @Param start_date_column query string false "Column holding start_date" default(start_date)
+// @Param end_date_column query string false "Column holding end_date" default(end_date)
+// @Param currency_column query string false "Column holding currency" default(currency)
+// @Param billing_period_column query string false "Column holding billing_period" default(billing_period)
+// @Param billing_interval_column query string false "Column holding billing_interval" default(billing_interval)
+// @Param status_column query string false "Column holding status" default(status)
+// @Param X-Actor header string false "Who makes the change, recorded in the subscriptions' history; ignored for authenticated requests, which record the token subject"
+// @Success 200 {object} importSubscriptionsResponseDTO "Dry run: the validation report"
+// @Success 201 {object} importSubscriptionsResponseDTO "The subscriptions were created"
+// @Failure 400 {object} importSubscriptionsResponseDTO "Some rows are invalid, nothing was created"
+// @Failure 403 {object} problemDTO "user_id is not the caller's own user ID, also reported by a dry run"
+// @Failure 413 {object} problemDTO "The file exceeds 10 MiB"
+// @Failure 415 {object} problemDTO "Unsupported Media Type"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/import [post]
+func (c *controller) importSubscriptions(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != csvContentType {
+		handleError(w, unsupportedMediaType(csvContentType))
+		return
+	}
+
+	query := r.URL.Query()
+
+	dryRun, err := parseOptionalBool(query.Get("dry_run"))
+	if err != nil {
+		handleError(w, invalidField("dry_run", err))
+		return
+	}
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		handleError(w, requestBodyError(fmt.Errorf("read CSV header: %w", err)))
+		return
+	}
+
+	indexes, err := mapImportColumns(header, query)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	reader.FieldsPerRecord = len(header)
+
+	resp := importSubscriptionsResponseDTO{
+		DryRun: dryRun,
+		Errors: make([]importLineErrorDTO, 0),
+	}
+	subscriptions := make([]*entity.CreateSubscriptionData, 0)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var (
+			parseErr *csv.ParseError
+			tooLarge *http.MaxBytesError
+		)
+		if errors.As(err, &parseErr) && !errors.As(err, &tooLarge) {
+			resp.Rows++
+			resp.Errors = append(resp.Errors, importLineErrorDTO{Line: parseErr.Line, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			handleError(w, requestBodyError(err))
+			return
+		}
+
+		resp.Rows++
+		line, _ := reader.FieldPos(0)
+
+		data, err := parseImportRecord(record, indexes)
+		if err != nil {
+			resp.Errors = append(resp.Errors, newImportLineErrors(line, err)...)
+			continue
+		}
+		subscriptions = append(subscriptions, data)
+	}
+
+	resp.Valid = len(subscriptions)
+
+	ctx := r.Context()
+
+	status := http.StatusOK
+	switch {
+	case dryRun:
+		// The same checks NewSubscriptions makes, so a dry run passes only if the import would.
+		err = c.service.ValidateNewSubscriptions(ctx, subscriptions)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+	case len(resp.Errors) > 0:
+		status = http.StatusBadRequest
+	case len(subscriptions) > 0:
+		ids, err := c.service.NewSubscriptions(ctx, subscriptions, actorFromRequest(r))
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		resp.IDs = make([]string, 0, len(ids))
+		for _, id := range ids {
+			resp.IDs = append(resp.IDs, id.String())
+		}
+		resp.Created = len(ids)
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// mapImportColumns returns the index in header of each of importColumns, -1 for an absent optional column.
+func mapImportColumns(header []string, query url.Values) ([]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	indexes := make([]int, len(importColumns))
+	for i, column := range importColumns {
+		name := column.field
+		if mapped := query.Get(column.field + "_column"); mapped != "" {
+			name = mapped
+		}
+
+		index, ok := positions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if column.required {
+				return nil, malformedRequest(fmt.Errorf("missing column %q for %s", name, column.field))
+			}
+			index = -1
+		}
+		indexes[i] = index
+	}
+
+	return indexes, nil
+}
+
+func parseImportRecord(record []string, indexes []int) (*entity.CreateSubscriptionData, error) {
+	var req createSubscriptionRequestDTO
+
+	for i, column := range importColumns {
+		if indexes[i] < 0 {
+			continue
+		}
+
+		err := column.set(&req, strings.TrimSpace(record[indexes[i]]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseCreateSubscription(&req)
+}
+
+// newImportLineErrors reports err of the row at line, one entry per invalid field.
+func newImportLineErrors(line int, err error) []importLineErrorDTO {
+	fields := fieldErrors(err)
+	if len(fields) == 0 {
+		return []importLineErrorDTO{{Line: line, Error: err.Error()}}
+	}
+
+	lineErrors := make([]importLineErrorDTO, 0, len(fields))
+	for _, field := range fields {
+		lineErrors = append(lineErrors, importLineErrorDTO{Line: line, Field: field.field, Error: field.err.Error()})
+	}
+
+	return lineErrors
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMapImportColumns(t *testing.T) {
+	// The indexes follow importColumns: user_id, service_name, price, start_date, end_date, currency,
+	// billing_period, billing_interval, status.
+	tests := []struct {
+		name    string
+		header  []string
+		query   url.Values
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "default names",
+			header: []string{"user_id", "service_name", "price", "start_date"},
+			want:   []int{0, 1, 2, 3, -1, -1, -1, -1, -1},
+		},
+		{
+			name:   "case and spaces",
+			header: []string{" Start_Date", "PRICE", "Service_Name ", "User_ID", "Status"},
+			want:   []int{3, 2, 1, 0, -1, -1, -1, -1, 4},
+		},
+		{
+			name:   "mapped columns",
+			header: []string{"customer", "product", "amount", "from", "to"},
+			query: url.Values{
+				"user_id_column":      {"Customer"},
+				"service_name_column": {"product"},
+				"price_column":        {"amount"},
+				"start_date_column":   {"from"},
+				"end_date_column":     {"to"},
+			},
+			want: []int{0, 1, 2, 3, 4, -1, -1, -1, -1},
+		},
+		{
+			name:    "missing required column",
+			header:  []string{"user_id", "service_name", "price"},
+			wantErr: true,
+		},
+		{
+			name:    "mapped to a missing column",
+			header:  []string{"user_id", "service_name", "price", "start_date"},
+			query:   url.Values{"price_column": {"amount"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapImportColumns(tt.header, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mapImportColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("mapImportColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// postImport posts body as a CSV file to the import endpoint.
+func postImport(t *testing.T, fake *fakeService, query string, body string) (*httptest.ResponseRecorder, importSubscriptionsResponseDTO) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	w := serve(t, fake, r)
+
+	var resp importSubscriptionsResponseDTO
+	if w.Header().Get("Content-Type") == "application/json" {
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+	}
+
+	return w, resp
+}
+
+const validImport = "user_id,service_name,price,start_date\n" +
+	"60601fee-2bf1-4721-ae6f-7636e79a0cba,Netflix,9.99,07-2025\n" +
+	"60601fee-2bf1-4721-ae6f-7636e79a0cba,Spotify,4.99,08-2025\n"
+
+const invalidImport = "user_id,service_name,price,start_date\n" +
+	"60601fee-2bf1-4721-ae6f-7636e79a0cba,Netflix,9.99,07-2025\n" +
+	"not-a-uuid,Spotify,4.99,08-2025\n" +
+	"60601fee-2bf1-4721-ae6f-7636e79a0cba,Hulu,4.99\n"
+
+func TestImportSubscriptionsLineErrors(t *testing.T) {
+	fake := &fakeService{
+		newSubscriptions: func(context.Context, []*entity.CreateSubscriptionData, string) ([]uuid.UUID, error) {
+			t.Fatal("NewSubscriptions called for a file with invalid rows")
+			return nil, nil
+		},
+	}
+
+	w, resp := postImport(t, fake, "", invalidImport)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+	if resp.Rows != 3 || resp.Valid != 1 || resp.Created != 0 {
+		t.Fatalf("rows, valid, created = %d, %d, %d, want 3, 1, 0", resp.Rows, resp.Valid, resp.Created)
+	}
+	if len(resp.Errors) != 2 {
+		t.Fatalf("errors = %+v, want 2", resp.Errors)
+	}
+	if got := resp.Errors[0]; got.Line != 3 || got.Field != "user_id" {
+		t.Fatalf("first error = %+v, want line 3, field user_id", got)
+	}
+	if got := resp.Errors[1]; got.Line != 4 || got.Field != "" {
+		t.Fatalf("second error = %+v, want line 4 without a field", got)
+	}
+}
+
+func TestImportSubscriptionsDryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		validateErr error
+		wantStatus  int
+		wantValid   int
+	}{
+		{name: "valid", body: validImport, wantStatus: http.StatusOK, wantValid: 2},
+		{name: "invalid rows", body: invalidImport, wantStatus: http.StatusOK, wantValid: 1},
+		{name: "other user", body: validImport, validateErr: srvc.ErrForbidden, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var validated []*entity.CreateSubscriptionData
+			fake := &fakeService{
+				newSubscriptions: func(context.Context, []*entity.CreateSubscriptionData, string) ([]uuid.UUID, error) {
+					t.Fatal("NewSubscriptions called in a dry run")
+					return nil, nil
+				},
+				validateNewSubscriptions: func(_ context.Context, data []*entity.CreateSubscriptionData) error {
+					validated = data
+					return tt.validateErr
+				},
+			}
+
+			w, resp := postImport(t, fake, "?dry_run=true", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.validateErr != nil {
+				return
+			}
+			if !resp.DryRun || resp.Valid != tt.wantValid || resp.Created != 0 {
+				t.Fatalf("response = %+v, want a dry run with %d valid rows", resp, tt.wantValid)
+			}
+			if len(validated) != tt.wantValid {
+				t.Fatalf("validated %d subscriptions, want %d", len(validated), tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestImportSubscriptionsCommit(t *testing.T) {
+	var created []*entity.CreateSubscriptionData
+	fake := &fakeService{
+		newSubscriptions: func(_ context.Context, data []*entity.CreateSubscriptionData, _ string) ([]uuid.UUID, error) {
+			created = data
+			ids := make([]uuid.UUID, len(data))
+			for i := range ids {
+				ids[i] = uuid.New()
+			}
+			return ids, nil
+		},
+	}
+
+	w, resp := postImport(t, fake, "", validImport)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if resp.DryRun || resp.Created != 2 || len(resp.IDs) != 2 {
+		t.Fatalf("response = %+v, want 2 created subscriptions", resp)
+	}
+	if len(created) != 2 || created[1].ServiceName != "Spotify" {
+		t.Fatalf("created = %+v, want the two rows in order", created)
+	}
+}
+
+func TestImportSubscriptionsForbidden(t *testing.T) {
+	fake := &fakeService{
+		newSubscriptions: func(context.Context, []*entity.CreateSubscriptionData, string) ([]uuid.UUID, error) {
+			return nil, srvc.ErrForbidden
+		},
+	}
+
+	w, _ := postImport(t, fake, "", validImport)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusForbidden, w.Body)
+	}
+}
+
+func TestImportSubscriptionsTooLarge(t *testing.T) {
+	row := "60601fee-2bf1-4721-ae6f-7636e79a0cba,Netflix,9.99,07-2025\n"
+	body := "user_id,service_name,price,start_date\n" + strings.Repeat(row, maxImportBodySize/len(row)+1)
+
+	w, _ := postImport(t, &fakeService{}, "", body)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+
+	var problem problemDTO
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("unmarshal problem: %v", err)
+	}
+	if problem.Code != codeRequestTooLarge {
+		t.Fatalf("code = %q, want %q", problem.Code, codeRequestTooLarge)
+	}
+}
+
+func TestImportSubscriptionsUnsupportedMediaType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader(validImport))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := serve(t, &fakeService{}, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestImportExportedFile(t *testing.T) {
+	subs := exportSubscriptions(t, 2)
+
+	export := func() string {
+		w := getExport(t, exportService(subs, nil), "text/csv")
+		if w.Code != http.StatusOK {
+			t.Fatalf("export status = %d, want %d", w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+
+	var created []*entity.CreateSubscriptionData
+	fake := &fakeService{
+		newSubscriptions: func(_ context.Context, data []*entity.CreateSubscriptionData, _ string) ([]uuid.UUID, error) {
+			created = data
+			return make([]uuid.UUID, len(data)), nil
+		},
+	}
+
+	// id, cancelled_at and version are ignored, so active subscriptions are imported as new ones.
+	w, _ := postImport(t, fake, "", export())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if len(created) != 2 || created[1].ServiceName != subs[1].ServiceName || created[1].Status != entity.SubscriptionStatusActive {
+		t.Fatalf("created = %+v, want the exported subscriptions", created)
+	}
+
+	// Statuses a subscription cannot be created in are rejected.
+	subs[1].Status = entity.SubscriptionStatusPaused
+	created = nil
+
+	w, resp := postImport(t, fake, "", export())
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Line != 3 || resp.Errors[0].Field != "status" {
+		t.Fatalf("errors = %+v, want a status error on line 3", resp.Errors)
+	}
+	if created != nil {
+		t.Fatal("NewSubscriptions called for a file with a paused subscription")
+	}
+}
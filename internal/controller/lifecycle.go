@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// GetSubscriptionHistory godoc
+// @Summary Get the history of a subscription
+// @Description List every change of a subscription, oldest first, with who made it, when, and snapshots of the subscription before and after. The history of a deleted subscription remains available.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Success 200 {object} subscriptionHistoryResponseDTO
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/history [get]
+func (c *controller) getSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		handleError(w, invalidField("id", err))
+		return
+	}
+
+	ctx := r.Context()
+	events, err := c.service.GetSubscriptionHistory(ctx, id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	resp := subscriptionHistoryResponseDTO{
+		Events: make([]subscriptionEventDTO, 0, len(events)),
+	}
+	for i := range events {
+		resp.Events = append(resp.Events, newSubscriptionEventDTO(&events[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// ActivateSubscription godoc
+// @Summary End a subscription's trial
+// @Description Move a trial subscription to active.
+// @Tags subscriptions
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Subscription is not in trial"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/activate [post]
+func (c *controller) activateSubscription(w http.ResponseWriter, r *http.Request) {
+	c.transitionSubscription(w, r, c.service.ActivateSubscription)
+}
+
+// PauseSubscription godoc
+// @Summary Pause a subscription
+// @Description Pause an active subscription. Months the subscription stays paused throughout are left out of totals and reports.
+// @Tags subscriptions
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Subscription is not active"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/pause [post]
+func (c *controller) pauseSubscription(w http.ResponseWriter, r *http.Request) {
+	c.transitionSubscription(w, r, c.service.PauseSubscription)
+}
+
+// ResumeSubscription godoc
+// @Summary Resume a subscription
+// @Description Make a paused subscription active again.
+// @Tags subscriptions
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Subscription is not paused"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/resume [post]
+func (c *controller) resumeSubscription(w http.ResponseWriter, r *http.Request) {
+	c.transitionSubscription(w, r, c.service.ResumeSubscription)
+}
+
+// CancelSubscription godoc
+// @Summary Cancel a subscription
+// @Description Same as DELETE /subscriptions/{id}: the subscription is kept with status cancelled and ends with the current month, or with the last month of its current billing cycle if at_period_end is set.
+// @Tags subscriptions
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param at_period_end query bool false "End the subscription with its current billing cycle instead of the current month"
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 409 {object} problemDTO "Subscription is already cancelled or expired"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/cancel [post]
+func (c *controller) cancelSubscription(w http.ResponseWriter, r *http.Request) {
+	atPeriodEnd, err := parseOptionalBool(r.URL.Query().Get("at_period_end"))
+	if err != nil {
+		handleError(w, invalidField("at_period_end", err))
+		return
+	}
+
+	c.transitionSubscription(w, r, func(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error) {
+		return c.service.CancelSubscription(ctx, id, atPeriodEnd, actor, ifMatch)
+	})
+}
+
+// transitionSubscription runs a status change of the subscription named in the path and writes its new ETag.
+func (c *controller) transitionSubscription(
+	w http.ResponseWriter,
+	r *http.Request,
+	transition func(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error),
+) {
+	idStr := r.PathValue("id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		handleError(w, invalidField("id", err))
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
+		return
+	}
+
+	ctx := r.Context()
+	version, err := transition(ctx, id, actorFromRequest(r), ifMatch)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(version))
+	w.WriteHeader(http.StatusOK)
+}
+
+// ExpireSubscriptions godoc
+// @Summary Expire ended subscriptions
+// @Description Move every trial, active or paused subscription whose end date lies before the current month to expired.
+// @Tags admin
+// @Produce json
+// @Param X-Actor header string false "Who makes the change, recorded in the subscriptions' history; ignored for authenticated requests, which record the token subject"
+// @Success 200 {object} expireSubscriptionsResponseDTO "Number of expired subscriptions"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/subscriptions/expire [post]
+func (c *controller) expireSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	expired, err := c.service.ExpireSubscriptions(ctx, actorFromRequest(r))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := expireSubscriptionsResponseDTO{
+		Expired: expired,
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPauseSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceErr error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "paused", wantStatus: http.StatusOK},
+		{
+			name:       "invalid transition",
+			serviceErr: fmt.Errorf("%w: cancelled to paused", srvc.ErrInvalidTransition),
+			wantStatus: http.StatusConflict,
+			wantCode:   codeInvalidTransition,
+		},
+		{name: "stale version", serviceErr: srvc.ErrPreconditionFailed, wantStatus: http.StatusPreconditionFailed, wantCode: codePreconditionFailed},
+		{name: "not found", serviceErr: srvc.ErrNotFound, wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeService{
+				pauseSubscription: func(context.Context, uuid.UUID, string, []int64) (int64, error) {
+					return 5, tt.serviceErr
+				},
+			}
+
+			w := serve(t, fake, httptest.NewRequest(http.MethodPost, "/subscriptions/"+uuid.NewString()+"/pause", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantCode == "" {
+				if etag := w.Header().Get("ETag"); etag != `"5"` {
+					t.Fatalf("ETag = %s, want \"5\"", etag)
+				}
+				return
+			}
+
+			var problem problemDTO
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Fatalf("code = %q, want %q", problem.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// isMergePatchContentType accepts application/merge-patch+json and, for clients that cannot set it,
+// plain application/json.
+func isMergePatchContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == mergePatchContentType || mediaType == "application/json"
+}
+
+// mergePatch applies an RFC 7396 JSON Merge Patch to target. Both are decoded JSON values.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// decodeMergePatch decodes a merge patch document.
+func decodeMergePatch(body io.Reader) (any, error) {
+	patch, err := decodeJSONValue(body)
+	if err != nil {
+		return nil, malformedRequest(err)
+	}
+
+	return patch, nil
+}
+
+// decodeJSONValue decodes any JSON value. Numbers are kept as json.Number so that prices survive the round trip
+// through applySubscriptionMergePatch exactly.
+func decodeJSONValue(r io.Reader) (any, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// applySubscriptionMergePatch patches the JSON representation of current. Patches naming fields that cannot be
+// changed, or setting a field to a value of the wrong type, are rejected as invalid fields.
+func applySubscriptionMergePatch(current *updateSubscriptionCreateDTO, patch any) (*updateSubscriptionCreateDTO, error) {
+	if _, ok := patch.(map[string]any); !ok {
+		return nil, malformedRequest(errors.New("merge patch must be a JSON object"))
+	}
+
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("marshal current: %w", err)
+	}
+
+	target, err := decodeJSONValue(bytes.NewReader(currentJSON))
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal current: %w", err)
+	}
+
+	mergedJSON, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return nil, fmt.Errorf("marshal merged: %w", err)
+	}
+
+	var merged updateSubscriptionCreateDTO
+	err = decodeJSONBody(bytes.NewReader(mergedJSON), &merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return &merged, nil
+}
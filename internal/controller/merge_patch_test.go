@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeJSON(t *testing.T, s string) any {
+	t.Helper()
+
+	value, err := decodeJSONValue(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+
+	return value
+}
+
+// TestMergePatch runs the examples of RFC 7396, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target+" + "+tt.patch, func(t *testing.T) {
+			got := mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("mergePatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplySubscriptionMergePatch(t *testing.T) {
+	current := &updateSubscriptionCreateDTO{
+		ServiceName:     "Yandex Plus",
+		Price:           "499.00",
+		Currency:        "RUB",
+		BillingPeriod:   "monthly",
+		BillingInterval: 1,
+		StartDate:       "08-2025",
+		EndDate:         "09-2025",
+	}
+
+	tests := []struct {
+		name      string
+		patch     string
+		want      func(dto *updateSubscriptionCreateDTO)
+		wantField string
+		wantErr   bool
+	}{
+		{
+			name:  "price as a string",
+			patch: `{"price":"599.00"}`,
+			want:  func(dto *updateSubscriptionCreateDTO) { dto.Price = "599.00" },
+		},
+		{
+			name:  "price as a long number is kept exactly",
+			patch: `{"price":92233720368547758.07}`,
+			want:  func(dto *updateSubscriptionCreateDTO) { dto.Price = "92233720368547758.07" },
+		},
+		{
+			name:  "exponent is kept for validation to reject, as on POST",
+			patch: `{"price":1e2}`,
+			want:  func(dto *updateSubscriptionCreateDTO) { dto.Price = "1e2" },
+		},
+		{
+			name:  "null removes end_date",
+			patch: `{"end_date":null}`,
+			want:  func(dto *updateSubscriptionCreateDTO) { dto.EndDate = "" },
+		},
+		{
+			name:  "several fields",
+			patch: `{"service_name":"Kinopoisk","billing_interval":3}`,
+			want: func(dto *updateSubscriptionCreateDTO) {
+				dto.ServiceName = "Kinopoisk"
+				dto.BillingInterval = 3
+			},
+		},
+		{
+			name:      "unknown field",
+			patch:     `{"user_id":"b6fa4d7c-8f90-4f92-912e-92c644c57a1e"}`,
+			wantField: "user_id",
+		},
+		{
+			name:      "wrong type",
+			patch:     `{"billing_interval":"three"}`,
+			wantField: "billing_interval",
+		},
+		{
+			name:      "fractional integer",
+			patch:     `{"billing_interval":1.5}`,
+			wantField: "billing_interval",
+		},
+		{
+			name:    "not an object",
+			patch:   `["price"]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applySubscriptionMergePatch(current, decodeJSON(t, tt.patch))
+
+			switch {
+			case tt.wantField != "":
+				assertFieldError(t, err, tt.wantField)
+				return
+			case tt.wantErr:
+				if err == nil || len(fieldErrors(err)) > 0 {
+					t.Fatalf("error = %v, want a malformed request", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("applySubscriptionMergePatch() error = %v", err)
+			}
+
+			want := *current
+			tt.want(&want)
+			if *got != want {
+				t.Fatalf("applySubscriptionMergePatch() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestAmountDTOWrongTypeIsAFieldError(t *testing.T) {
+	current := &updateSubscriptionCreateDTO{ServiceName: "Yandex Plus", Price: "499.00", StartDate: "08-2025"}
+
+	for _, patch := range []string{`{"price":true}`, `{"price":{"amount":1}}`, `{"price":[1]}`} {
+		t.Run(patch, func(t *testing.T) {
+			merged, err := applySubscriptionMergePatch(current, decodeJSON(t, patch))
+			if err == nil {
+				_, err = parseUpdateSubscription(merged)
+			}
+
+			assertFieldError(t, err, "price")
+		})
+	}
+}
+
+func TestPatchSubscription(t *testing.T) {
+	id := uuid.New()
+	sub := &entity.Subscription{
+		ID:            id,
+		UserID:        uuid.New(),
+		ServiceName:   "Yandex Plus",
+		Price:         entity.Money{Amount: 49900, Currency: "RUB"},
+		BillingPeriod: entity.BillingPeriodMonthly,
+		StartDate:     time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC),
+		Version:       4,
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantPrice  int64
+		wantField  string
+	}{
+		{name: "large numeric price", body: `{"price":12345678901234.56}`, wantStatus: http.StatusOK, wantPrice: 1234567890123456},
+		{name: "exponent price", body: `{"price":1e2}`, wantStatus: http.StatusBadRequest, wantField: "price"},
+		{name: "unknown field", body: `{"status":"paused"}`, wantStatus: http.StatusBadRequest, wantField: "status"},
+		{name: "wrong type", body: `{"service_name":42}`, wantStatus: http.StatusBadRequest, wantField: "service_name"},
+		{name: "malformed", body: `{"price":`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *entity.UpdateSubscriptionData
+			srvc := &fakeService{
+				getSubscription: func(_ context.Context, _ uuid.UUID) (*entity.Subscription, error) {
+					return sub, nil
+				},
+				updateSubscription: func(_ context.Context, _ uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, _ string) (int64, error) {
+					if len(ifMatch) != 1 || ifMatch[0] != sub.Version {
+						t.Errorf("ifMatch = %v, want [%d]", ifMatch, sub.Version)
+					}
+					updated = data
+					return sub.Version + 1, nil
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodPatch, "/subscriptions/"+id.String(), strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", mergePatchContentType)
+
+			w := serve(t, srvc, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				if updated == nil || updated.Price.Amount != tt.wantPrice {
+					t.Fatalf("updated price = %+v, want %d", updated, tt.wantPrice)
+				}
+				return
+			}
+
+			var problem problemDTO
+			err := json.NewDecoder(w.Body).Decode(&problem)
+			if err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+
+			wantCode := codeMalformedRequest
+			if tt.wantField != "" {
+				wantCode = codeValidationFailed
+			}
+			if problem.Code != wantCode {
+				t.Fatalf("problem code = %q, want %q", problem.Code, wantCode)
+			}
+			if tt.wantField != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.wantField) {
+				t.Fatalf("problem errors = %+v, want one for %s", problem.Errors, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// SchedulePriceChange godoc
+// @Summary Schedule a price change
+// @Description Change a subscription's price from the month effective_from on, keeping the earlier prices for totals and reports of earlier months.
+// @Description effective_from must lie after start_date, not before the current month and not after end_date. A change already scheduled for the same month is replaced.
+// @Tags subscriptions
+// @Accept json
+// @Param id path string true "Subscription ID" Format(uuid)
+// @Param price_change body schedulePriceChangeRequestDTO true "New price, a decimal string in the subscription's currency, and the month it takes effect (MM-YYYY)"
+// @Param If-Match header string false "ETag the subscription must still have"
+// @Param X-Actor header string false "Who makes the change, recorded in the subscription's history; ignored for authenticated requests, which record the token subject"
+// @Success 200 "OK"
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Not Found"
+// @Failure 412 {object} problemDTO "Precondition Failed"
+// @Failure 422 {object} problemDTO "Price change cannot be scheduled"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /subscriptions/{id}/prices [post]
+func (c *controller) postPriceChange(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		handleError(w, invalidField("id", err))
+		return
+	}
+
+	var req schedulePriceChangeRequestDTO
+
+	err = decodeJSONBody(r.Body, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !decimalRegexp.MatchString(string(req.Price)) {
+		handleError(w, invalidField("price", fmt.Errorf("price must be a non-negative decimal number")))
+		return
+	}
+
+	effectiveFrom, err := time.Parse(timeFormat, req.EffectiveFrom)
+	if err != nil {
+		handleError(w, invalidField("effective_from", err))
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, invalidField("If-Match", err))
+		return
+	}
+
+	ctx := r.Context()
+	version, err := c.service.SchedulePriceChange(ctx, id, string(req.Price), effectiveFrom, actorFromRequest(r), ifMatch)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(version))
+	w.WriteHeader(http.StatusOK)
+}
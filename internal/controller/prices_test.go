@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	srvc "github.com/BernsteinMondy/subscription-service/internal/service"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostPriceChange(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		ifMatch    string
+		serviceErr error
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{name: "scheduled", body: `{"price":"12.00","effective_from":"01-2026"}`, wantStatus: http.StatusOK},
+		{name: "matching ETag", body: `{"price":"12.00","effective_from":"01-2026"}`, ifMatch: `"3"`, wantStatus: http.StatusOK},
+		{name: "invalid id", id: "nope", body: `{"price":"12.00","effective_from":"01-2026"}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed, wantField: "id"},
+		{name: "malformed body", body: `{"price":`, wantStatus: http.StatusBadRequest, wantCode: codeMalformedRequest},
+		{name: "negative price", body: `{"price":"-1","effective_from":"01-2026"}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed, wantField: "price"},
+		{name: "invalid effective_from", body: `{"price":"12.00","effective_from":"2026-01"}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed, wantField: "effective_from"},
+		{name: "invalid If-Match", body: `{"price":"12.00","effective_from":"01-2026"}`, ifMatch: "3", wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed, wantField: "If-Match"},
+		{
+			name:       "rejected",
+			body:       `{"price":"12.00","effective_from":"01-2026"}`,
+			serviceErr: fmt.Errorf("%w: effective date is in the past", srvc.ErrInvalidPriceChange),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   codeInvalidPriceChange,
+		},
+		{
+			name:       "stale ETag",
+			body:       `{"price":"12.00","effective_from":"01-2026"}`,
+			serviceErr: srvc.ErrPreconditionFailed,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   codePreconditionFailed,
+		},
+		{name: "not found", body: `{"price":"12.00","effective_from":"01-2026"}`, serviceErr: srvc.ErrNotFound, wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAmount string
+			var gotEffectiveFrom time.Time
+			fake := &fakeService{
+				schedulePriceChange: func(_ context.Context, _ uuid.UUID, amount string, effectiveFrom time.Time, _ string, _ []int64) (int64, error) {
+					gotAmount, gotEffectiveFrom = amount, effectiveFrom
+					return 4, tt.serviceErr
+				},
+			}
+
+			target := id.String()
+			if tt.id != "" {
+				target = tt.id
+			}
+			r := httptest.NewRequest(http.MethodPost, "/subscriptions/"+target+"/prices", strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			w := serve(t, fake, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				if got := w.Header().Get("ETag"); got != `"4"` {
+					t.Fatalf("ETag = %q, want %q", got, `"4"`)
+				}
+				if gotAmount != "12.00" || !gotEffectiveFrom.Equal(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Fatalf("scheduled %s from %v, want 12.00 from 01-2026", gotAmount, gotEffectiveFrom)
+				}
+				return
+			}
+
+			var problem problemDTO
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Fatalf("code = %q, want %q", problem.Code, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.wantField) {
+				t.Fatalf("errors = %+v, want one for %s", problem.Errors, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GetRoleAssignments godoc
+// @Summary Get role assignments
+// @Description List the roles assigned to token subjects, optionally of one subject. Roles of the token's roles claim are not listed.
+// @Tags admin
+// @Produce json
+// @Param subject query string false "Token subject"
+// @Success 200 {object} roleAssignmentsDTO
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/role-assignments [get]
+func (c *controller) getRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	subject := strings.TrimSpace(r.URL.Query().Get("subject"))
+
+	ctx := r.Context()
+	assignments, err := c.service.GetRoleAssignments(ctx, subject)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	var resp = roleAssignmentsDTO{
+		Assignments: make([]roleAssignmentDTO, 0, len(assignments)),
+	}
+	for _, assignment := range assignments {
+		resp.Assignments = append(resp.Assignments, roleAssignmentDTO{
+			Subject:    assignment.Subject,
+			Role:       assignment.Role,
+			AssignedBy: assignment.AssignedBy,
+			AssignedAt: assignment.AssignedAt.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	return
+}
+
+// PutRoleAssignment godoc
+// @Summary Assign a role
+// @Description Grant a role to a token subject. Roles take effect with the subject's next request.
+// @Tags admin
+// @Param subject path string true "Token subject"
+// @Param role path string true "Role" Enums(admin, operator, read-only, user)
+// @Success 201 "Role assigned"
+// @Success 204 "Subject already had the role"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/role-assignments/{subject}/{role} [put]
+func (c *controller) putRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	subject, role, err := parseRoleAssignmentPath(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	created, err := c.service.AssignRole(ctx, subject, role, actorFromRequest(r))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// DeleteRoleAssignment godoc
+// @Summary Revoke a role
+// @Tags admin
+// @Param subject path string true "Token subject"
+// @Param role path string true "Role" Enums(admin, operator, read-only, user)
+// @Success 204 "Role revoked"
+// @Failure 400 {object} problemDTO "Bad Request"
+// @Failure 404 {object} problemDTO "Subject does not have the role"
+// @Failure 500 {object} problemDTO "Internal Server Error"
+// @Router /admin/role-assignments/{subject}/{role} [delete]
+func (c *controller) deleteRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	subject, role, err := parseRoleAssignmentPath(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	err = c.service.RevokeRole(ctx, subject, role)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func parseRoleAssignmentPath(r *http.Request) (string, string, error) {
+	var errs []error
+
+	subject := strings.TrimSpace(r.PathValue("subject"))
+	if subject == "" {
+		errs = append(errs, invalidField("subject", errors.New("is required")))
+	}
+
+	role := r.PathValue("role")
+	if !auth.IsRole(role) {
+		errs = append(errs, invalidField("role", fmt.Errorf("must be one of %s", strings.Join(auth.Roles(), ", "))))
+	}
+
+	return subject, role, errors.Join(errs...)
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// validationRule checks or normalises a field. arg is the text after "=" in the rule, e.g. "255" for "max=255".
+type validationRule func(value reflect.Value, arg string) error
+
+// validationRules are the rules usable in validate tags. A tag lists rules separated by commas and they run in that
+// order, so normalising rules such as trim come before the checks. Apart from required, checks skip empty strings.
+var validationRules = map[string]validationRule{
+	"required": func(value reflect.Value, _ string) error {
+		if value.IsZero() {
+			return errors.New("is required")
+		}
+		return nil
+	},
+	"trim": func(value reflect.Value, _ string) error {
+		value.SetString(strings.TrimSpace(value.String()))
+		return nil
+	},
+	"single_space": func(value reflect.Value, _ string) error {
+		value.SetString(strings.Join(strings.Fields(value.String()), " "))
+		return nil
+	},
+	"upper": func(value reflect.Value, _ string) error {
+		value.SetString(strings.ToUpper(value.String()))
+		return nil
+	},
+	"max": func(value reflect.Value, arg string) error {
+		limit := mustAtoi(arg)
+		if value.Kind() == reflect.String {
+			if utf8.RuneCountInString(value.String()) > limit {
+				return fmt.Errorf("must be at most %d characters long", limit)
+			}
+			return nil
+		}
+		if value.Int() > int64(limit) {
+			return fmt.Errorf("must be at most %d", limit)
+		}
+		return nil
+	},
+	"min": func(value reflect.Value, arg string) error {
+		if limit := mustAtoi(arg); value.Int() < int64(limit) {
+			return fmt.Errorf("must be at least %d", limit)
+		}
+		return nil
+	},
+	"oneof": func(value reflect.Value, arg string) error {
+		allowed := strings.Fields(arg)
+		for _, option := range allowed {
+			if value.String() == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	},
+	"uuid": func(value reflect.Value, _ string) error {
+		if _, err := uuid.Parse(value.String()); err != nil {
+			return errors.New("must be a UUID")
+		}
+		return nil
+	},
+	"month": func(value reflect.Value, _ string) error {
+		if _, err := time.Parse(timeFormat, value.String()); err != nil {
+			return errors.New("must be a month in MM-YYYY format")
+		}
+		return nil
+	},
+	"decimal": func(value reflect.Value, _ string) error {
+		if !decimalRegexp.MatchString(value.String()) {
+			return errors.New("must be a non-negative decimal number")
+		}
+		return nil
+	},
+	"currency": func(value reflect.Value, _ string) error {
+		if !currencyRegexp.MatchString(value.String()) {
+			return errors.New("must be an ISO 4217 currency code")
+		}
+		return nil
+	},
+}
+
+func mustAtoi(arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic(fmt.Sprintf("validation rule argument %q is not a number", arg))
+	}
+	return n
+}
+
+// validate normalises and checks the fields of the struct req points to, as declared by their validate tags.
+// Fields of embedded structs are validated too. Every violation is returned, one fieldError per invalid field
+// combined with errors.Join.
+func validate(req any) error {
+	return validateStruct(reflect.ValueOf(req).Elem())
+}
+
+func validateStruct(value reflect.Value) error {
+	var errs []error
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			errs = append(errs, validateStruct(value.Field(i)))
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+
+		err := validateField(value.Field(i), tag)
+		if err != nil {
+			errs = append(errs, invalidField(jsonFieldName(field), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateField applies the rules of tag to value, stopping at the first violation.
+func validateField(value reflect.Value, tag string) error {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		apply, ok := validationRules[name]
+		if !ok {
+			panic(fmt.Sprintf("unknown validation rule %q", name))
+		}
+
+		if name != "required" && value.Kind() == reflect.String && value.String() == "" {
+			continue
+		}
+
+		err := apply(value, arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldParser collects the errors of validate and of the parsing that follows it, so that every invalid field of a
+// request is reported at once.
+type fieldParser struct {
+	errs    []error
+	invalid map[string]bool
+}
+
+// newFieldParser starts from validateErr, the result of validate.
+func newFieldParser(validateErr error) *fieldParser {
+	parser := &fieldParser{invalid: make(map[string]bool)}
+	parser.add(validateErr)
+
+	return parser
+}
+
+// parse calls fn and records its error, unless one of fields, the fields fn reads, is already invalid: such a
+// field is reported once.
+func (p *fieldParser) parse(fn func() error, fields ...string) {
+	for _, field := range fields {
+		if p.invalid[field] {
+			return
+		}
+	}
+
+	p.add(fn())
+}
+
+func (p *fieldParser) add(err error) {
+	if err == nil {
+		return
+	}
+
+	p.errs = append(p.errs, err)
+	for _, field := range fieldErrors(err) {
+		p.invalid[field.field] = true
+	}
+}
+
+// err returns every recorded error combined with errors.Join, or nil.
+func (p *fieldParser) err() error {
+	return errors.Join(p.errs...)
+}
+
+// decodeJSONBody decodes the request body into dst, rejecting properties dst does not have.
+func decodeJSONBody(body io.Reader, dst any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+
+	return nil
+}
+
+// jsonDecodeError reports unknown fields and values of the wrong type as invalid fields, and anything else as a
+// malformed request.
+func jsonDecodeError(err error) error {
+	// encoding/json reports unknown fields only through the message.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return invalidField(strings.Trim(name, `"`), errors.New("is not a known field"))
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalidField(typeErr.Field, typeError(typeErr.Type))
+	}
+
+	return malformedRequest(err)
+}
+
+func typeError(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeError(t.Elem())
+	case reflect.String:
+		return errors.New("must be a string")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return errors.New("must be an integer")
+	case reflect.Float32, reflect.Float64:
+		return errors.New("must be a number")
+	case reflect.Bool:
+		return errors.New("must be a boolean")
+	case reflect.Slice, reflect.Array:
+		return errors.New("must be an array")
+	default:
+		return errors.New("must be an object")
+	}
+}
@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validatedNameDTO struct {
+	Name string `json:"name" validate:"trim,single_space,required,max=5"`
+}
+
+type validatedDTO struct {
+	validatedNameDTO
+	Currency string `json:"currency,omitempty" validate:"trim,upper,currency"`
+	Period   string `json:"period" validate:"oneof=weekly monthly"`
+	Count    int    `json:"count" validate:"min=0,max=10"`
+	UserID   string `validate:"uuid"`
+	Month    string `json:"month" validate:"trim,month"`
+	Price    string `json:"price" validate:"decimal"`
+	Ignored  string `json:"ignored"`
+}
+
+func TestValidate(t *testing.T) {
+	req := validatedDTO{
+		validatedNameDTO: validatedNameDTO{Name: "  a   b "},
+		Currency:         " usd ",
+		Period:           "weekly",
+		Count:            10,
+		UserID:           "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		Month:            " 07-2025",
+		Price:            "9.99",
+		Ignored:          " kept ",
+	}
+
+	err := validate(&req)
+	if err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	want := validatedDTO{
+		validatedNameDTO: validatedNameDTO{Name: "a b"},
+		Currency:         "USD",
+		Period:           "weekly",
+		Count:            10,
+		UserID:           "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		Month:            "07-2025",
+		Price:            "9.99",
+		Ignored:          " kept ",
+	}
+	if req != want {
+		t.Fatalf("validated = %+v, want %+v", req, want)
+	}
+}
+
+func TestValidateOptionalFields(t *testing.T) {
+	err := validate(&validatedDTO{validatedNameDTO: validatedNameDTO{Name: "a"}})
+	if err != nil {
+		t.Fatalf("validate() error = %v, want empty optional fields to pass", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	req := validatedDTO{
+		validatedNameDTO: validatedNameDTO{Name: "   "},
+		Currency:         "usdollar",
+		Period:           "daily",
+		Count:            -1,
+		UserID:           "nope",
+		Month:            "2025-07",
+		Price:            "-1",
+	}
+
+	fields := fieldErrors(validate(&req))
+
+	want := map[string]string{
+		"name":     "is required",
+		"currency": "must be an ISO 4217 currency code",
+		"period":   "must be one of weekly, monthly",
+		"count":    "must be at least 0",
+		"UserID":   "must be a UUID",
+		"month":    "must be a month in MM-YYYY format",
+		"price":    "must be a non-negative decimal number",
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("got %d field errors %v, want %d", len(fields), fields, len(want))
+	}
+	for _, field := range fields {
+		if got := field.err.Error(); got != want[field.field] {
+			t.Errorf("%s: error = %q, want %q", field.field, got, want[field.field])
+		}
+	}
+}
+
+func TestValidateMax(t *testing.T) {
+	err := validate(&validatedDTO{validatedNameDTO: validatedNameDTO{Name: "ёжиков"}, Count: 11})
+
+	fields := fieldErrors(err)
+	if len(fields) != 2 {
+		t.Fatalf("got field errors %v, want name and count", fields)
+	}
+	if fields[0].field != "name" || fields[0].err.Error() != "must be at most 5 characters long" {
+		t.Fatalf("first error = %v, want name too long", fields[0])
+	}
+	if fields[1].field != "count" || fields[1].err.Error() != "must be at most 10" {
+		t.Fatalf("second error = %v, want count too large", fields[1])
+	}
+}
+
+func TestJSONFieldName(t *testing.T) {
+	typ := reflect.TypeOf(validatedDTO{})
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{field: "Currency", want: "currency"},
+		{field: "Period", want: "period"},
+		{field: "UserID", want: "UserID"},
+	}
+
+	for _, tt := range tests {
+		field, _ := typ.FieldByName(tt.field)
+		if got := jsonFieldName(field); got != tt.want {
+			t.Fatalf("jsonFieldName(%s) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeJSONBody(t *testing.T) {
+	type item struct {
+		Name  string  `json:"name"`
+		Count int     `json:"count"`
+		Tags  []int   `json:"tags"`
+		Ratio float64 `json:"ratio"`
+		On    *bool   `json:"on"`
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+		wantMsg   string
+		wantCode  string
+	}{
+		{name: "valid", body: `{"name":"a","count":1,"tags":[1],"ratio":0.5,"on":true}`},
+		{name: "unknown field", body: `{"name":"a","colour":"red"}`, wantField: "colour", wantMsg: "is not a known field"},
+		{name: "string", body: `{"name":1}`, wantField: "name", wantMsg: "must be a string"},
+		{name: "integer", body: `{"count":1.5}`, wantField: "count", wantMsg: "must be an integer"},
+		{name: "array", body: `{"tags":{}}`, wantField: "tags", wantMsg: "must be an array"},
+		{name: "number", body: `{"ratio":"x"}`, wantField: "ratio", wantMsg: "must be a number"},
+		{name: "boolean", body: `{"on":"yes"}`, wantField: "on", wantMsg: "must be a boolean"},
+		{name: "syntax", body: `{"name":`, wantCode: codeMalformedRequest},
+		{name: "wrong top-level type", body: `[]`, wantCode: codeMalformedRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst item
+			err := decodeJSONBody(strings.NewReader(tt.body), &dst)
+
+			switch {
+			case tt.wantField != "":
+				assertFieldError(t, err, tt.wantField)
+				var fieldErr *fieldError
+				if errors.As(err, &fieldErr) && fieldErr.err.Error() != tt.wantMsg {
+					t.Fatalf("error = %q, want %q", fieldErr.err, tt.wantMsg)
+				}
+			case tt.wantCode != "":
+				if got := newProblem(err).Code; got != tt.wantCode {
+					t.Fatalf("code = %q, want %q", got, tt.wantCode)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("decodeJSONBody() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestTypeError(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: "", want: "must be a string"},
+		{value: new(string), want: "must be a string"},
+		{value: uint8(0), want: "must be an integer"},
+		{value: float32(0), want: "must be a number"},
+		{value: false, want: "must be a boolean"},
+		{value: [2]int{}, want: "must be an array"},
+		{value: struct{}{}, want: "must be an object"},
+		{value: map[string]int{}, want: "must be an object"},
+	}
+
+	for _, tt := range tests {
+		if got := typeError(reflect.TypeOf(tt.value)).Error(); got != tt.want {
+			t.Errorf("typeError(%T) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFieldParser(t *testing.T) {
+	parser := newFieldParser(invalidField("price", errors.New("must be a non-negative decimal number")))
+
+	calls := 0
+	parser.parse(func() error {
+		calls++
+		return invalidField("price", errors.New("too many fraction digits"))
+	}, "price", "currency")
+	parser.parse(func() error {
+		calls++
+		return invalidField("end_date", errors.New("start date is after end date"))
+	}, "start_date", "end_date")
+	parser.parse(func() error {
+		calls++
+		return nil
+	}, "status")
+
+	if calls != 2 {
+		t.Fatalf("parse functions called %d times, want 2: fields already invalid are not parsed again", calls)
+	}
+
+	fields := fieldErrors(parser.err())
+	if len(fields) != 2 || fields[0].field != "price" || fields[1].field != "end_date" {
+		t.Fatalf("field errors = %v, want price and end_date", fields)
+	}
+
+	if err := newFieldParser(nil).err(); err != nil {
+		t.Fatalf("err() without errors = %v, want nil", err)
+	}
+}
+
+func TestParseCreateSubscriptionCollectsErrors(t *testing.T) {
+	req := createSubscriptionRequestDTO{
+		UserID:      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		ServiceName: " ",
+		Price:       "9.999",
+		Currency:    "usd",
+		StartDate:   "12-2025",
+		EndDate:     "07-2025",
+		Status:      "paused",
+	}
+
+	_, err := parseCreateSubscription(&req)
+
+	fields := fieldErrors(err)
+	want := []string{"service_name", "status", "end_date", "price"}
+	if len(fields) != len(want) {
+		t.Fatalf("field errors = %v, want %v", fields, want)
+	}
+	for i, field := range fields {
+		if field.field != want[i] {
+			t.Fatalf("field errors = %v, want %v", fields, want)
+		}
+	}
+}
+
+func TestParseCreateSubscriptionReportsFieldsOnce(t *testing.T) {
+	req := createSubscriptionRequestDTO{
+		UserID:      "nope",
+		ServiceName: "Netflix",
+		Price:       "9.99",
+		StartDate:   "2025-07",
+	}
+
+	_, err := parseCreateSubscription(&req)
+
+	fields := fieldErrors(err)
+	if len(fields) != 2 || fields[0].field != "user_id" || fields[1].field != "start_date" {
+		t.Fatalf("field errors = %v, want user_id and start_date once each", fields)
+	}
+}
+
+func TestParseUpdateSubscriptionCollectsErrors(t *testing.T) {
+	req := updateSubscriptionCreateDTO{
+		ServiceName:   "Netflix",
+		Price:         "100.5",
+		Currency:      "JPY",
+		BillingPeriod: "daily",
+		StartDate:     "12-2025",
+		EndDate:       "07-2025",
+	}
+
+	_, err := parseUpdateSubscription(&req)
+
+	fields := fieldErrors(err)
+	want := []string{"billing_period", "end_date", "price"}
+	if len(fields) != len(want) {
+		t.Fatalf("field errors = %v, want %v", fields, want)
+	}
+	for i, field := range fields {
+		if field.field != want[i] {
+			t.Fatalf("field errors = %v, want %v", fields, want)
+		}
+	}
+
+	data, err := parseUpdateSubscription(&updateSubscriptionCreateDTO{ServiceName: " Netflix ", Price: "100", Currency: "JPY", StartDate: "07-2025"})
+	if err != nil {
+		t.Fatalf("parseUpdateSubscription() error = %v", err)
+	}
+	if data.ServiceName != "Netflix" || data.Price.Currency != "JPY" || data.EndDate != nil {
+		t.Fatalf("parseUpdateSubscription() = %+v, want Netflix for 100 JPY, open-ended", data)
+	}
+}
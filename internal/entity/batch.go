@@ -0,0 +1,29 @@
+package entity
+
+import "github.com/google/uuid"
+
+// BatchMode selects what happens to a batch when some of its items fail.
+type BatchMode string
+
+const (
+	// BatchModeAtomic applies either every item of the batch or none of them.
+	BatchModeAtomic BatchMode = "atomic"
+	// BatchModePartial applies every item that succeeds and reports the others.
+	BatchModePartial BatchMode = "partial"
+)
+
+// SubscriptionUpdate is one item of a batch update.
+type SubscriptionUpdate struct {
+	ID   uuid.UUID
+	Data *UpdateSubscriptionData
+	// IfMatch lists the versions the subscription may have; empty means any.
+	IfMatch []int64
+}
+
+// BatchItemResult is the outcome of one item of a batch, in the order of the batch. Err is nil for an item that
+// was applied, or would have been had the whole atomic batch succeeded.
+type BatchItemResult struct {
+	ID      uuid.UUID
+	Version int64
+	Err     error
+}
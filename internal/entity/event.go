@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Change says who changes a subscription and when, as recorded in the subscription's history.
+type Change struct {
+	Actor string
+	At    time.Time
+}
+
+// SubscriptionEventType is the kind of change a SubscriptionEvent records.
+type SubscriptionEventType string
+
+const (
+	SubscriptionEventCreated       SubscriptionEventType = "created"
+	SubscriptionEventUpdated       SubscriptionEventType = "updated"
+	SubscriptionEventStatusChanged SubscriptionEventType = "status_changed"
+	SubscriptionEventDeleted       SubscriptionEventType = "deleted"
+)
+
+// SubscriptionEvent is one entry of a subscription's history.
+type SubscriptionEvent struct {
+	ID             int64
+	SubscriptionID uuid.UUID
+	Type           SubscriptionEventType
+	Actor          string
+	CreatedAt      time.Time
+	// Before and After are JSON snapshots of the stored subscription row. Before is nil for a created
+	// subscription, After for a deleted one.
+	Before json.RawMessage
+	After  json.RawMessage
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// ExchangeRate says how many units of QuoteCurrency one unit of BaseCurrency is worth from EffectiveFrom
+// until the next rate for the same pair takes effect.
+type ExchangeRate struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	EffectiveFrom time.Time
+	// Rate is a positive decimal number, e.g. "92.5".
+	Rate string
+}
+
+type GetExchangeRatesFilter struct {
+	BaseCurrency  string
+	QuoteCurrency string
+}
@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// IdempotencyKey remembers which subscription a create request produced, so that a retry carrying the same
+// Idempotency-Key gets the original response instead of creating a duplicate.
+type IdempotencyKey struct {
+	Key string
+	// RequestHash identifies the request the key was first used with.
+	RequestHash    string
+	SubscriptionID uuid.UUID
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// subscriptionTransitions lists the statuses each status may change to. Cancelled and expired are final.
+var subscriptionTransitions = map[SubscriptionStatus][]SubscriptionStatus{
+	SubscriptionStatusTrial:  {SubscriptionStatusActive, SubscriptionStatusCancelled, SubscriptionStatusExpired},
+	SubscriptionStatusActive: {SubscriptionStatusPaused, SubscriptionStatusCancelled, SubscriptionStatusExpired},
+	SubscriptionStatusPaused: {SubscriptionStatusActive, SubscriptionStatusCancelled, SubscriptionStatusExpired},
+}
+
+// CanTransition reports whether a subscription in status from may move to status to.
+func CanTransition(from, to SubscriptionStatus) bool {
+	for _, allowed := range subscriptionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StatusTransition is a change of a subscription's status, recorded together with who made it and when.
+type StatusTransition struct {
+	SubscriptionID uuid.UUID
+	From           SubscriptionStatus
+	To             SubscriptionStatus
+	Change
+	// EndDate, if set, replaces the subscription's end date, e.g. when it is cancelled.
+	EndDate *time.Time
+}
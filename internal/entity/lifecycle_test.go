@@ -0,0 +1,38 @@
+package entity
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	statuses := []SubscriptionStatus{
+		SubscriptionStatusTrial,
+		SubscriptionStatusActive,
+		SubscriptionStatusPaused,
+		SubscriptionStatusCancelled,
+		SubscriptionStatusExpired,
+	}
+
+	allowed := map[[2]SubscriptionStatus]bool{
+		{SubscriptionStatusTrial, SubscriptionStatusActive}:     true,
+		{SubscriptionStatusTrial, SubscriptionStatusCancelled}:  true,
+		{SubscriptionStatusTrial, SubscriptionStatusExpired}:    true,
+		{SubscriptionStatusActive, SubscriptionStatusPaused}:    true,
+		{SubscriptionStatusActive, SubscriptionStatusCancelled}: true,
+		{SubscriptionStatusActive, SubscriptionStatusExpired}:   true,
+		{SubscriptionStatusPaused, SubscriptionStatusActive}:    true,
+		{SubscriptionStatusPaused, SubscriptionStatusCancelled}: true,
+		{SubscriptionStatusPaused, SubscriptionStatusExpired}:   true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]SubscriptionStatus{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	if CanTransition("", SubscriptionStatusActive) || CanTransition(SubscriptionStatusActive, "deleted") {
+		t.Error("CanTransition allows an unknown status")
+	}
+}
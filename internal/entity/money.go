@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// DefaultMinorUnits is the number of minor unit digits of currencies not listed in CurrencyMinorUnits.
+const DefaultMinorUnits = 2
+
+// CurrencyMinorUnits lists ISO 4217 currencies whose minor unit is not a hundredth of the major one.
+var CurrencyMinorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0,
+	"VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+var (
+	ErrInvalidAmount  = errors.New("invalid amount")
+	ErrAmountTooLarge = errors.New("amount is too large")
+)
+
+var decimalRegexp = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]+))?$`)
+
+// Money is an amount in the minor units of its currency, e.g. kopecks for RUB.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func MinorUnitsOf(currency string) int {
+	if units, ok := CurrencyMinorUnits[currency]; ok {
+		return units
+	}
+	return DefaultMinorUnits
+}
+
+// ParseMoney parses a decimal amount in major units, e.g. "10.99", into Money of the given currency.
+func ParseMoney(amount, currency string) (Money, error) {
+	match := decimalRegexp.FindStringSubmatch(strings.TrimSpace(amount))
+	if match == nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	sign, whole, fraction := match[1], match[2], strings.TrimRight(match[3], "0")
+
+	units := MinorUnitsOf(currency)
+	if len(fraction) > units {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d fraction digits", ErrInvalidAmount, currency, units)
+	}
+	fraction += strings.Repeat("0", units-len(fraction))
+
+	minor, ok := new(big.Int).SetString(sign+whole+fraction, 10)
+	if !ok {
+		return Money{}, ErrInvalidAmount
+	}
+
+	if !minor.IsInt64() {
+		return Money{}, ErrAmountTooLarge
+	}
+
+	return Money{
+		Amount:   minor.Int64(),
+		Currency: currency,
+	}, nil
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount as a decimal in major units, e.g. "10.99".
+func (m Money) String() string {
+	digits := new(big.Int).Abs(big.NewInt(m.Amount)).String()
+
+	units := MinorUnitsOf(m.Currency)
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+
+	if units == 0 {
+		return sign + digits
+	}
+
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{amount: "10.99", currency: "RUB", want: 1099},
+		{amount: "10", currency: "RUB", want: 1000},
+		{amount: "10.5", currency: "RUB", want: 1050},
+		{amount: "10.990", currency: "RUB", want: 1099},
+		{amount: "0.01", currency: "USD", want: 1},
+		{amount: "0", currency: "USD", want: 0},
+		{amount: " 7.25 ", currency: "USD", want: 725},
+		{amount: "-5.00", currency: "RUB", want: -500},
+		{amount: "100", currency: "JPY", want: 100},
+		{amount: "100.0", currency: "JPY", want: 100},
+		{amount: "1.234", currency: "KWD", want: 1234},
+		{amount: "1.2", currency: "KWD", want: 1200},
+		{amount: "92233720368547758.07", currency: "RUB", want: 9223372036854775807},
+		{amount: "-92233720368547758.08", currency: "RUB", want: -9223372036854775808},
+		{amount: "10.999", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "100.5", currency: "JPY", wantErr: ErrInvalidAmount},
+		{amount: "1.2345", currency: "KWD", wantErr: ErrInvalidAmount},
+		{amount: "92233720368547758.08", currency: "RUB", wantErr: ErrAmountTooLarge},
+		{amount: "9223372036854775808", currency: "JPY", wantErr: ErrAmountTooLarge},
+		{amount: "", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "abc", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "1e2", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: ".5", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "5.", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "+5", currency: "RUB", wantErr: ErrInvalidAmount},
+		{amount: "1,5", currency: "RUB", wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := ParseMoney(tt.amount, tt.currency)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseMoney() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.Amount != tt.want || got.Currency != tt.currency {
+				t.Fatalf("ParseMoney() = %+v, want %d %s", got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: Money{Amount: 1099, Currency: "RUB"}, want: "10.99"},
+		{money: Money{Amount: 0, Currency: "RUB"}, want: "0.00"},
+		{money: Money{Amount: 5, Currency: "RUB"}, want: "0.05"},
+		{money: Money{Amount: 50, Currency: "RUB"}, want: "0.50"},
+		{money: Money{Amount: -5, Currency: "RUB"}, want: "-0.05"},
+		{money: Money{Amount: -1099, Currency: "USD"}, want: "-10.99"},
+		{money: Money{Amount: 100, Currency: "JPY"}, want: "100"},
+		{money: Money{Amount: 1, Currency: "KWD"}, want: "0.001"},
+		{money: Money{Amount: 1234, Currency: "KWD"}, want: "1.234"},
+		{money: Money{Amount: -9223372036854775808, Currency: "RUB"}, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := tt.money.String()
+			if got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+
+			parsed, err := ParseMoney(got, tt.money.Currency)
+			if err != nil || parsed != tt.money {
+				t.Fatalf("ParseMoney(%q) = %+v, %v, want %+v", got, parsed, err, tt.money)
+			}
+		})
+	}
+}
+
+func TestMinorUnitsOf(t *testing.T) {
+	tests := map[string]int{"RUB": 2, "USD": 2, "JPY": 0, "KWD": 3, "XXX": DefaultMinorUnits}
+
+	for currency, want := range tests {
+		if got := MinorUnitsOf(currency); got != want {
+			t.Errorf("MinorUnitsOf(%s) = %d, want %d", currency, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+type SortField string
+
+const (
+	SortByStartDate   SortField = "start_date"
+	SortByEndDate     SortField = "end_date"
+	SortByPrice       SortField = "price"
+	SortByServiceName SortField = "service_name"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+type Sort struct {
+	Field SortField
+	Order SortOrder
+}
+
+// Cursor points at the last subscription of the previous page: Value is its sort key and ID breaks ties
+// between rows with equal keys, so rows inserted between requests never shift the page boundary.
+type Cursor struct {
+	Sort  Sort
+	Value string
+	ID    uuid.UUID
+}
+
+type ListSubscriptionsParams struct {
+	Filter *GetSubscriptionsFilter
+	Sort   Sort
+	Limit  int
+	Cursor *Cursor
+}
+
+type SubscriptionsPage struct {
+	Subscriptions []Subscription
+	NextCursor    *Cursor
+}
+
+// SortValue returns the subscription's key for the given sort field in a form the repository can compare against.
+func (s *Subscription) SortValue(field SortField) string {
+	switch field {
+	case SortByEndDate:
+		if s.EndDate == nil {
+			return "infinity"
+		}
+		return s.EndDate.Format(time.RFC3339Nano)
+	case SortByPrice:
+		return strconv.FormatInt(s.Price.Amount, 10)
+	case SortByServiceName:
+		return s.ServiceName
+	default:
+		return s.StartDate.Format(time.RFC3339Nano)
+	}
+}
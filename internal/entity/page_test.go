@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortValue(t *testing.T) {
+	start := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	sub := &Subscription{
+		ServiceName: "Netflix",
+		Price:       Money{Amount: 999, Currency: "USD"},
+		StartDate:   start,
+		EndDate:     &end,
+	}
+	openEnded := &Subscription{StartDate: start}
+
+	tests := []struct {
+		name  string
+		sub   *Subscription
+		field SortField
+		want  string
+	}{
+		{name: "start date", sub: sub, field: SortByStartDate, want: "2025-07-01T00:00:00Z"},
+		{name: "end date", sub: sub, field: SortByEndDate, want: "2026-01-01T00:00:00Z"},
+		{name: "open end date", sub: openEnded, field: SortByEndDate, want: "infinity"},
+		{name: "price in minor units", sub: sub, field: SortByPrice, want: "999"},
+		{name: "service name", sub: sub, field: SortByServiceName, want: "Netflix"},
+		{name: "unknown field", sub: sub, field: "user_id", want: "2025-07-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.SortValue(tt.field); got != tt.want {
+				t.Fatalf("SortValue(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
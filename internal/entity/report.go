@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// MonthlyReport amounts are in the minor units of Currency.
+type MonthlyReport struct {
+	Currency string
+	Months   []MonthReport
+}
+
+type MonthReport struct {
+	Month               time.Time
+	TotalPrice          int64
+	ActiveSubscriptions int
+	Services            []ServiceSpend
+}
+
+type ServiceSpend struct {
+	ServiceName         string
+	TotalPrice          int64
+	ActiveSubscriptions int
+}
+
+// MonthlySpend is what the subscriptions of one service cost in one month, in minor units of Currency.
+type MonthlySpend struct {
+	Month               time.Time
+	ServiceName         string
+	Currency            string
+	TotalPrice          int64
+	ActiveSubscriptions int
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// RoleAssignment grants Role to the callers whose token subject is Subject.
+type RoleAssignment struct {
+	Subject    string
+	Role       string
+	AssignedBy string
+	AssignedAt time.Time
+}
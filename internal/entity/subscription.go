@@ -5,33 +5,137 @@ import (
 	"time"
 )
 
+// BillingPeriod is the unit of time a subscription's price is charged for.
+type BillingPeriod string
+
+const (
+	BillingPeriodWeekly    BillingPeriod = "weekly"
+	BillingPeriodMonthly   BillingPeriod = "monthly"
+	BillingPeriodQuarterly BillingPeriod = "quarterly"
+	BillingPeriodYearly    BillingPeriod = "yearly"
+)
+
+// SubscriptionStatus is where a subscription is in its lifecycle. See CanTransition for the allowed changes.
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusTrial  SubscriptionStatus = "trial"
+	SubscriptionStatusActive SubscriptionStatus = "active"
+	// SubscriptionStatusPaused subscriptions are not charged for the months they stay paused throughout.
+	SubscriptionStatusPaused SubscriptionStatus = "paused"
+	// SubscriptionStatusCancelled subscriptions are kept for history; their EndDate is the last month paid for.
+	SubscriptionStatusCancelled SubscriptionStatus = "cancelled"
+	// SubscriptionStatusExpired subscriptions reached their EndDate without being cancelled.
+	SubscriptionStatusExpired SubscriptionStatus = "expired"
+)
+
 type Subscription struct {
 	ID          uuid.UUID
 	UserID      uuid.UUID
 	ServiceName string
-	Price       int32
-	StartDate   time.Time
-	EndDate     time.Time
+	// Price is charged once every BillingInterval BillingPeriods, from StartDate until the first of PriceChanges.
+	Price           Money
+	BillingPeriod   BillingPeriod
+	BillingInterval int32
+	StartDate       time.Time
+	// EndDate is nil for open-ended subscriptions, which stay active indefinitely.
+	EndDate     *time.Time
+	Status      SubscriptionStatus
+	CancelledAt *time.Time
+	// PriceChanges are ordered by EffectiveFrom and share Price's currency.
+	PriceChanges []PriceChange
+	// Version is incremented on every update and guards concurrent writes.
+	Version int64
+}
+
+// PriceChange replaces a subscription's price from the month EffectiveFrom on, until the next change.
+type PriceChange struct {
+	Price         Money
+	EffectiveFrom time.Time
+}
+
+// PeriodEnd returns the first day of the last month of the billing cycle that contains at, or of the first cycle
+// if the subscription has not started yet. Weekly cycles are treated as ending within the month they are in.
+func (s *Subscription) PeriodEnd(at time.Time) time.Time {
+	var cycleMonths int
+	switch s.BillingPeriod {
+	case BillingPeriodWeekly:
+		cycleMonths = 1
+	case BillingPeriodQuarterly:
+		cycleMonths = 3 * int(s.BillingInterval)
+	case BillingPeriodYearly:
+		cycleMonths = 12 * int(s.BillingInterval)
+	default:
+		cycleMonths = int(s.BillingInterval)
+	}
+	cycleMonths = max(cycleMonths, 1)
+
+	start := s.StartDate.UTC()
+	at = at.UTC()
+
+	elapsed := max((at.Year()-start.Year())*12+int(at.Month()-start.Month()), 0)
+	cycles := elapsed/cycleMonths + 1
+
+	return time.Date(start.Year(), start.Month()+time.Month(cycles*cycleMonths-1), 1, 0, 0, 0, 0, time.UTC)
 }
 
 type CreateSubscriptionData struct {
-	UserID      uuid.UUID
-	ServiceName string
-	Price       int32
-	StartDate   time.Time
-	EndDate     time.Time
+	UserID          uuid.UUID
+	ServiceName     string
+	Price           Money
+	BillingPeriod   BillingPeriod
+	BillingInterval int32
+	StartDate       time.Time
+	EndDate         *time.Time
+	// Status is either SubscriptionStatusTrial or SubscriptionStatusActive.
+	Status SubscriptionStatus
 }
 
 type UpdateSubscriptionData struct {
-	ServiceName string
-	Price       int32
-	StartDate   time.Time
-	EndDate     time.Time
+	ServiceName     string
+	Price           Money
+	BillingPeriod   BillingPeriod
+	BillingInterval int32
+	StartDate       time.Time
+	EndDate         *time.Time
 }
 
+// DateMatch selects how the filter's date range is compared with a subscription's period.
+type DateMatch string
+
+const (
+	// DateMatchContained matches subscriptions whose whole period lies inside the range.
+	DateMatchContained DateMatch = "contained"
+	// DateMatchOverlaps matches subscriptions active at any point of the range.
+	DateMatchOverlaps DateMatch = "overlaps"
+	// DateMatchActiveAt matches subscriptions active at the filter's StartDate; EndDate is not used.
+	DateMatchActiveAt DateMatch = "active_at"
+)
+
 type GetSubscriptionsFilter struct {
-	UserID      uuid.UUID
-	ServiceName string
-	StartDate   time.Time
-	EndDate     time.Time
+	UserIDs      []uuid.UUID
+	ServiceNames []string
+	StartDate    time.Time
+	EndDate      time.Time
+	Match        DateMatch
+	// PriceMin and PriceMax are decimal amounts in major units, compared in each subscription's own currency.
+	PriceMin *string
+	PriceMax *string
+	// Statuses limits the result to subscriptions in one of the statuses; empty means any status.
+	Statuses []SubscriptionStatus
+	// OwnerID, if set, limits the result to the subscriptions of that user on top of UserIDs. The service sets it
+	// to scope a request to its caller.
+	OwnerID *uuid.UUID
 }
+
+// TotalMode selects how subscription prices add up to a total.
+type TotalMode string
+
+const (
+	// TotalModeSum adds each matching subscription's monthly price once. Prices of other billing periods are
+	// normalised to a month first.
+	TotalModeSum TotalMode = "sum"
+	// TotalModeMonthlyAccrual adds the monthly price for every month a subscription is active within the
+	// filter's date range, clipping periods that only partially overlap it.
+	TotalModeMonthlyAccrual TotalMode = "monthly_accrual"
+)
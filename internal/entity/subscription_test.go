@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month) time.Time {
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriodEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   BillingPeriod
+		interval int32
+		start    time.Time
+		at       time.Time
+		want     time.Time
+	}{
+		{name: "monthly", period: BillingPeriodMonthly, interval: 1, start: date(2025, time.January), at: date(2025, time.May), want: date(2025, time.May)},
+		{name: "every two months", period: BillingPeriodMonthly, interval: 2, start: date(2025, time.January), at: date(2025, time.May), want: date(2025, time.June)},
+		{name: "quarterly first month", period: BillingPeriodQuarterly, interval: 1, start: date(2025, time.January), at: date(2025, time.April), want: date(2025, time.June)},
+		{name: "quarterly last month", period: BillingPeriodQuarterly, interval: 1, start: date(2025, time.January), at: date(2025, time.March), want: date(2025, time.March)},
+		{name: "yearly across years", period: BillingPeriodYearly, interval: 1, start: date(2024, time.November), at: date(2025, time.February), want: date(2025, time.October)},
+		{name: "weekly", period: BillingPeriodWeekly, interval: 2, start: date(2025, time.January), at: date(2025, time.May), want: date(2025, time.May)},
+		{name: "not started", period: BillingPeriodQuarterly, interval: 1, start: date(2025, time.July), at: date(2025, time.May), want: date(2025, time.September)},
+		{name: "zero interval", period: BillingPeriodMonthly, start: date(2025, time.January), at: date(2025, time.May), want: date(2025, time.May)},
+		{
+			name:     "time of day and zone",
+			period:   BillingPeriodQuarterly,
+			interval: 1,
+			start:    date(2025, time.January),
+			at:       time.Date(2025, time.March, 31, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60)),
+			want:     date(2025, time.June),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{BillingPeriod: tt.period, BillingInterval: tt.interval, StartDate: tt.start}
+
+			if got := sub.PeriodEnd(tt.at); !got.Equal(tt.want) {
+				t.Fatalf("PeriodEnd(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
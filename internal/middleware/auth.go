@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"net/http"
+	"strings"
+)
+
+// publicPathPrefixes are served without authentication.
+var publicPathPrefixes = []string{"/swagger/"}
+
+type tokenVerifier interface {
+	Verify(token string) (*auth.Identity, error)
+}
+
+// AuthMiddleware requires a valid "Authorization: Bearer <JWT>" header on every request except those to public
+// paths, and puts the caller's identity on the request context. Missing, invalid and expired tokens get 401.
+func AuthMiddleware(verifier tokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range publicPathPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				writeUnauthorized(w, "", "bearer token is required")
+				return
+			}
+
+			identity, err := verifier.Verify(strings.TrimSpace(token))
+			if err != nil {
+				detail := "token is invalid"
+				if errors.Is(err, auth.ErrTokenExpired) {
+					detail = "token is expired"
+				}
+				writeUnauthorized(w, "invalid_token", detail)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// writeUnauthorized answers with the RFC 6750 challenge.
+func writeUnauthorized(w http.ResponseWriter, bearerError, detail string) {
+	challenge := `Bearer realm="subscription-service"`
+	if bearerError != "" {
+		challenge += `, error="` + bearerError + `", error_description="` + detail + `"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeProblem(w, http.StatusUnauthorized, "unauthorized", "Authentication required", detail, nil)
+}
+
+// writeProblem answers with an RFC 7807 problem like the controller's. extra holds extension members.
+func writeProblem(w http.ResponseWriter, status int, code, title, detail string, extra map[string]any) {
+	problem := map[string]any{
+		"type":   "/problems/" + code,
+		"title":  title,
+		"status": status,
+		"code":   code,
+	}
+	if detail != "" {
+		problem["detail"] = detail
+	}
+	for name, value := range extra {
+		problem[name] = value
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(problem)
+}
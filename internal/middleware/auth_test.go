@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeVerifier accepts the token "good" as alice, reports "old" as expired and rejects any other token.
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(token string) (*auth.Identity, error) {
+	switch token {
+	case "good":
+		return &auth.Identity{Subject: "alice"}, nil
+	case "old":
+		return nil, auth.ErrTokenExpired
+	default:
+		return nil, fmt.Errorf("%w: signature mismatch", auth.ErrInvalidToken)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantSubject   string
+		wantChallenge string
+		wantDetail    string
+	}{
+		{
+			name:          "valid token",
+			path:          "/subscriptions",
+			authorization: "Bearer good",
+			wantStatus:    http.StatusOK,
+			wantSubject:   "alice",
+		},
+		{
+			name:          "scheme is case-insensitive",
+			path:          "/subscriptions",
+			authorization: "bearer good",
+			wantStatus:    http.StatusOK,
+			wantSubject:   "alice",
+		},
+		{
+			name:          "missing header",
+			path:          "/subscriptions",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="subscription-service"`,
+			wantDetail:    "bearer token is required",
+		},
+		{
+			name:          "basic auth",
+			path:          "/subscriptions",
+			authorization: "Basic YWxpY2U6c2VjcmV0",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="subscription-service"`,
+			wantDetail:    "bearer token is required",
+		},
+		{
+			name:          "empty token",
+			path:          "/subscriptions",
+			authorization: "Bearer  ",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="subscription-service"`,
+			wantDetail:    "bearer token is required",
+		},
+		{
+			name:          "invalid token",
+			path:          "/subscriptions",
+			authorization: "Bearer forged",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="subscription-service", error="invalid_token", error_description="token is invalid"`,
+			wantDetail:    "token is invalid",
+		},
+		{
+			name:          "expired token",
+			path:          "/subscriptions",
+			authorization: "Bearer old",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="subscription-service", error="invalid_token", error_description="token is expired"`,
+			wantDetail:    "token is expired",
+		},
+		{
+			name:       "public path",
+			path:       "/swagger/index.html",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+					subject = identity.Subject
+				}
+			})
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			AuthMiddleware(fakeVerifier{})(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if subject != tt.wantSubject {
+				t.Fatalf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+			if tt.wantStatus != http.StatusUnauthorized {
+				return
+			}
+
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Fatalf("WWW-Authenticate = %q, want %q", got, tt.wantChallenge)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Fatalf("Content-Type = %q, want application/problem+json", got)
+			}
+
+			var problem map[string]any
+			err := json.NewDecoder(w.Body).Decode(&problem)
+			if err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+
+			want := map[string]any{
+				"type":   "/problems/unauthorized",
+				"title":  "Authentication required",
+				"status": float64(http.StatusUnauthorized),
+				"code":   "unauthorized",
+				"detail": tt.wantDetail,
+			}
+			for name, value := range want {
+				if problem[name] != value {
+					t.Fatalf("problem %s = %v, want %v", name, problem[name], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+type roleStore interface {
+	GetSubjectRoles(ctx context.Context, subject string) ([]string, error)
+}
+
+// Authorizer enforces the permissions of routes.
+type Authorizer struct {
+	roles roleStore
+}
+
+func NewAuthorizer(roles roleStore) *Authorizer {
+	return &Authorizer{
+		roles: roles,
+	}
+}
+
+// Require lets a request through to next only if the caller has permission. The roles assigned to the caller in
+// roles are added to those of its token, and the identity on the request context is replaced with one carrying
+// all of them; a caller without any role gets auth.DefaultRole. Callers without the permission get 403 naming it.
+//
+// Requests without an identity, which are only possible with authentication disabled, are let through.
+func (a *Authorizer) Require(permission auth.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		assigned, err := a.roles.GetSubjectRoles(r.Context(), identity.Subject)
+		if err != nil {
+			slog.Error("Failed to get roles", slog.String("subject", identity.Subject), slog.Any("error", err))
+			writeProblem(w, http.StatusInternalServerError, "internal_error", "Internal server error", "", nil)
+			return
+		}
+
+		withRoles := *identity
+		withRoles.Roles = slices.Clone(identity.Roles)
+		for _, role := range assigned {
+			if !slices.Contains(withRoles.Roles, role) {
+				withRoles.Roles = append(withRoles.Roles, role)
+			}
+		}
+
+		if len(withRoles.Roles) == 0 {
+			withRoles.Roles = []string{auth.DefaultRole}
+		}
+
+		if !withRoles.HasPermission(permission) {
+			writeProblem(w, http.StatusForbidden, "permission_denied", "Permission denied",
+				"missing permission "+string(permission),
+				map[string]any{"permission": permission},
+			)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), &withRoles)))
+	})
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeRoleStore maps subjects to their assigned roles.
+type fakeRoleStore map[string][]string
+
+func (f fakeRoleStore) GetSubjectRoles(_ context.Context, subject string) ([]string, error) {
+	return f[subject], nil
+}
+
+// authorize runs a request of identity through Require(permission) and returns the response and the identity the
+// next handler saw, nil if it was not called.
+func authorize(t *testing.T, store roleStore, identity *auth.Identity, permission auth.Permission) (*httptest.ResponseRecorder, *auth.Identity) {
+	t.Helper()
+
+	var seen *auth.Identity
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.IdentityFromContext(r.Context())
+		if seen == nil {
+			seen = &auth.Identity{}
+		}
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if identity != nil {
+		r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+	}
+	w := httptest.NewRecorder()
+
+	NewAuthorizer(store).Require(permission, next).ServeHTTP(w, r)
+
+	return w, seen
+}
+
+func TestRequireDefaultRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission auth.Permission
+		wantStatus int
+	}{
+		{name: "read", permission: auth.PermissionSubscriptionsRead, wantStatus: http.StatusOK},
+		{name: "write", permission: auth.PermissionSubscriptionsWrite, wantStatus: http.StatusOK},
+		{name: "reports", permission: auth.PermissionReportsRead, wantStatus: http.StatusOK},
+		{name: "admin", permission: auth.PermissionAdmin, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, seen := authorize(t, fakeRoleStore{}, &auth.Identity{Subject: "alice"}, tt.permission)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && (len(seen.Roles) != 1 || seen.Roles[0] != auth.DefaultRole) {
+				t.Fatalf("roles = %v, want [%s]", seen.Roles, auth.DefaultRole)
+			}
+		})
+	}
+}
+
+func TestRequireNoDefaultRoleWithAssignedRole(t *testing.T) {
+	store := fakeRoleStore{"alice": {auth.RoleReadOnly}}
+
+	w, _ := authorize(t, store, &auth.Identity{Subject: "alice"}, auth.PermissionSubscriptionsWrite)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireWithoutIdentity(t *testing.T) {
+	w, seen := authorize(t, fakeRoleStore{}, nil, auth.PermissionAdmin)
+
+	if w.Code != http.StatusOK || seen == nil {
+		t.Fatalf("status = %d, want the request let through", w.Code)
+	}
+}
+
+func TestRequireForbiddenNamesPermission(t *testing.T) {
+	w, seen := authorize(t, fakeRoleStore{}, &auth.Identity{Subject: "alice", Roles: []string{auth.RoleOperator}}, auth.PermissionAdmin)
+
+	if seen != nil {
+		t.Fatal("next handler was called")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	var problem map[string]any
+	err := json.NewDecoder(w.Body).Decode(&problem)
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	if problem["code"] != "permission_denied" || problem["permission"] != "admin:*" || problem["detail"] != "missing permission admin:*" {
+		t.Fatalf("problem = %v, want permission_denied naming admin:*", problem)
+	}
+}
+
+func TestRequireMergesAssignedRoles(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokenRoles []string
+		assigned   []string
+		permission auth.Permission
+		wantStatus int
+		wantRoles  []string
+	}{
+		{
+			name:       "assigned role adds a permission",
+			tokenRoles: []string{auth.RoleReadOnly},
+			assigned:   []string{auth.RoleOperator},
+			permission: auth.PermissionSubscriptionsWrite,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{auth.RoleReadOnly, auth.RoleOperator},
+		},
+		{
+			name:       "token role adds a permission",
+			tokenRoles: []string{auth.RoleAdmin},
+			assigned:   []string{auth.RoleReadOnly},
+			permission: auth.PermissionAdmin,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{auth.RoleAdmin, auth.RoleReadOnly},
+		},
+		{
+			name:       "role in both is kept once",
+			tokenRoles: []string{auth.RoleOperator},
+			assigned:   []string{auth.RoleOperator},
+			permission: auth.PermissionSubscriptionsWrite,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{auth.RoleOperator},
+		},
+		{
+			name:       "assigned only",
+			assigned:   []string{auth.RoleAdmin},
+			permission: auth.PermissionAdmin,
+			wantStatus: http.StatusOK,
+			wantRoles:  []string{auth.RoleAdmin},
+		},
+		{
+			name:       "neither grants the permission",
+			tokenRoles: []string{auth.RoleReadOnly},
+			assigned:   []string{auth.RoleOperator},
+			permission: auth.PermissionAdmin,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := &auth.Identity{Subject: "alice", Roles: tt.tokenRoles}
+			store := fakeRoleStore{"alice": tt.assigned, "bob": {auth.RoleAdmin}}
+
+			w, seen := authorize(t, store, identity, tt.permission)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if len(identity.Roles) != len(tt.tokenRoles) {
+				t.Fatalf("token identity modified: roles = %v", identity.Roles)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if len(seen.Roles) != len(tt.wantRoles) {
+				t.Fatalf("roles = %v, want %v", seen.Roles, tt.wantRoles)
+			}
+			for i := range tt.wantRoles {
+				if seen.Roles[i] != tt.wantRoles[i] {
+					t.Fatalf("roles = %v, want %v", seen.Roles, tt.wantRoles)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"strings"
+	"time"
+)
+
+// monthSeries expands [$start, $end] into one row per month, aliased as months(month). The series is generated
+// in UTC so month arithmetic does not depend on the session time zone.
+func monthSeries(startArg, endArg int) string {
+	return fmt.Sprintf(
+		`(SELECT m AT TIME ZONE 'UTC' AS month FROM generate_series($%d::timestamptz AT TIME ZONE 'UTC', $%d::timestamptz AT TIME ZONE 'UTC', interval '1 month') AS m) AS months`,
+		startArg, endArg,
+	)
+}
+
+// exchangeRate selects the rate converting minor units of the subscription's currency into minor units of the
+// target currency that is in effect at monthExpr. A stored rate for the opposite direction is used inverted.
+// The result is NULL when no rate is known.
+func exchangeRate(monthExpr string, targetArg int) string {
+	return fmt.Sprintf(`CASE WHEN s.currency = $%[1]d THEN 1 ELSE (
+		SELECT CASE WHEN er.base_currency = s.currency THEN er.rate ELSE 1 / er.rate END
+		FROM app.exchange_rates er
+		WHERE ((er.base_currency = s.currency AND er.quote_currency = $%[1]d) OR (er.base_currency = $%[1]d AND er.quote_currency = s.currency))
+			AND er.effective_from <= %[2]s
+		ORDER BY er.effective_from DESC, er.base_currency = s.currency DESC
+		LIMIT 1
+	) * %[3]s / %[4]s END`, targetArg, monthExpr, minorUnitScale(fmt.Sprintf("$%d", targetArg)), minorUnitScale("s.currency"))
+}
+
+// pausedThrough is true when the subscription stayed paused from the first moment of the month firstMonthExpr to
+// the last moment of the month lastMonthExpr; such months are not charged. lastMonthExpr may be infinity, which
+// only a pause that was never resumed covers.
+func pausedThrough(firstMonthExpr, lastMonthExpr string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM app.subscription_pauses p
+	WHERE p.subscription_id = s.id AND p.paused_at <= %s
+		AND (p.resumed_at IS NULL OR p.resumed_at >= (%s AT TIME ZONE 'UTC' + interval '1 month') AT TIME ZONE 'UTC'))`, firstMonthExpr, lastMonthExpr)
+}
+
+type spendQuery struct {
+	filter *entity.GetSubscriptionsFilter
+	// targetCurrency converts every price into this currency; when empty, spend is grouped by currency.
+	targetCurrency string
+	// accrue counts the monthly price once for every month of the filter's range the subscription is active and
+	// not paused throughout. Otherwise it is counted once, unless the subscription stayed paused for all of its
+	// months within the range.
+	accrue         bool
+	groupByMonth   bool
+	groupByService bool
+}
+
+// sql returns the query summing normalised monthly prices as described by q, with its arguments. Each row holds
+// the month, service name and currency of a group, its number of subscriptions, its total and how many of its
+// subscriptions lack an exchange rate.
+func (q *spendQuery) sql() (string, []interface{}) {
+	var (
+		queryBuilder strings.Builder
+		args         []interface{}
+		joins        []string
+		groupBy      []string
+		notPaused    string
+	)
+
+	// priceMonth is the month whose price and exchange rate apply.
+	priceMonth := "s.start_date"
+	if q.accrue {
+		args = append(args, q.filter.StartDate, q.filter.EndDate)
+		joins = append(joins, "JOIN "+monthSeries(1, 2)+" ON s.start_date <= months.month AND (s.end_date IS NULL OR s.end_date >= months.month) AND NOT "+pausedThrough("months.month", "months.month"))
+		priceMonth = "months.month"
+	} else {
+		if !q.filter.StartDate.IsZero() {
+			args = append(args, q.filter.StartDate)
+			priceMonth = fmt.Sprintf("GREATEST(s.start_date, $%d)", len(args))
+		}
+
+		// lastMonth is the last month of the subscription within the filter's range.
+		lastMonth := "COALESCE(s.end_date, 'infinity'::timestamptz)"
+		if !q.filter.EndDate.IsZero() {
+			args = append(args, q.filter.EndDate)
+			lastMonth = fmt.Sprintf("LEAST(%s, $%d)", lastMonth, len(args))
+		}
+		notPaused = "NOT " + pausedThrough(priceMonth, lastMonth)
+	}
+
+	// The column is not called price, which would make the filter's unqualified price conditions ambiguous.
+	joins = append(joins, "CROSS JOIN LATERAL (SELECT "+effectivePrice(priceMonth)+" AS amount) AS effective")
+
+	monthColumn := "NULL::timestamptz"
+	if q.groupByMonth {
+		monthColumn = "months.month"
+		groupBy = append(groupBy, "months.month")
+	}
+
+	serviceColumn := "''"
+	if q.groupByService {
+		serviceColumn = "s.service_name"
+		groupBy = append(groupBy, "s.service_name")
+	}
+
+	amount := monthlyPrice("effective.amount")
+	currencyColumn := "s.currency"
+	missingRates := "0"
+	if q.targetCurrency != "" {
+		args = append(args, q.targetCurrency)
+		joins = append(joins, "CROSS JOIN LATERAL (SELECT "+exchangeRate(priceMonth, len(args))+" AS rate) AS conversion")
+		amount += " * conversion.rate"
+		currencyColumn = fmt.Sprintf("$%d::text", len(args))
+		missingRates = "COUNT(*) FILTER (WHERE conversion.rate IS NULL)"
+	} else {
+		groupBy = append(groupBy, "s.currency")
+	}
+
+	queryBuilder.WriteString(fmt.Sprintf(
+		`SELECT %s, %s, %s, COUNT(*), COALESCE(ROUND(SUM(%s)), 0)::bigint, %s FROM app.subscriptions s`,
+		monthColumn, serviceColumn, currencyColumn, amount, missingRates,
+	))
+
+	for _, join := range joins {
+		queryBuilder.WriteString(" ")
+		queryBuilder.WriteString(join)
+	}
+
+	conditions, args := filterConditions(q.filter, args)
+	if notPaused != "" {
+		conditions = append(conditions, notPaused)
+	}
+	if len(conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	if len(groupBy) > 0 {
+		queryBuilder.WriteString(" GROUP BY ")
+		queryBuilder.WriteString(strings.Join(groupBy, ", "))
+		queryBuilder.WriteString(" ORDER BY ")
+		queryBuilder.WriteString(strings.Join(groupBy, ", "))
+	}
+
+	return queryBuilder.String(), args
+}
+
+// querySpend sums normalised monthly prices as described by q.
+func (r *repository) querySpend(ctx context.Context, q *spendQuery) (_ []entity.MonthlySpend, err error) {
+	query, args := q.sql()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	spend := make([]entity.MonthlySpend, 0)
+
+	for rows.Next() {
+		var (
+			row          entity.MonthlySpend
+			month        *time.Time
+			missingCount int
+		)
+		err = rows.Scan(&month, &row.ServiceName, &row.Currency, &row.ActiveSubscriptions, &row.TotalPrice, &missingCount)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+
+		if missingCount > 0 {
+			return nil, ErrRepoExchangeRateNotFound
+		}
+
+		if month != nil {
+			row.Month = month.UTC()
+		}
+
+		// Without grouping the aggregate yields a single row even if nothing matched.
+		if row.ActiveSubscriptions == 0 {
+			continue
+		}
+
+		spend = append(spend, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return spend, nil
+}
+
+// SumSubscriptionsPrice adds the monthly price of every subscription matching the filter once, one total per
+// currency. The price and, with a target currency, the exchange rate are those in effect in the month the
+// subscription enters the filter's range. Subscriptions paused throughout their months within the range, or
+// paused and never resumed when the range is open-ended, are left out.
+func (r *repository) SumSubscriptionsPrice(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.Money, error) {
+	spend, err := r.querySpend(ctx, &spendQuery{
+		filter:         filter,
+		targetCurrency: targetCurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totalsByCurrency(spend), nil
+}
+
+// SumSubscriptionsMonthlyAccrual adds every subscription's monthly price once per month it is active within the
+// filter's date range, skipping months it was paused throughout, one total per currency. Each month uses the
+// price in effect in it and, with a target currency, is converted at its own rate.
+func (r *repository) SumSubscriptionsMonthlyAccrual(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.Money, error) {
+	spend, err := r.querySpend(ctx, &spendQuery{
+		filter:         filter,
+		targetCurrency: targetCurrency,
+		accrue:         true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totalsByCurrency(spend), nil
+}
+
+// GetMonthlySpend groups the spend of every month within the filter's date range by service and currency.
+// Months without active subscriptions are not returned; subscriptions paused throughout a month do not count.
+func (r *repository) GetMonthlySpend(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.MonthlySpend, error) {
+	return r.querySpend(ctx, &spendQuery{
+		filter:         filter,
+		targetCurrency: targetCurrency,
+		accrue:         true,
+		groupByMonth:   true,
+		groupByService: true,
+	})
+}
+
+func totalsByCurrency(spend []entity.MonthlySpend) []entity.Money {
+	totals := make([]entity.Money, 0, len(spend))
+	for _, row := range spend {
+		totals = append(totals, entity.Money{
+			Amount:   row.TotalPrice,
+			Currency: row.Currency,
+		})
+	}
+
+	return totals
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/lib/pq"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonthSeries(t *testing.T) {
+	got := monthSeries(3, 4)
+
+	for _, want := range []string{"generate_series($3::timestamptz AT TIME ZONE 'UTC', $4::timestamptz AT TIME ZONE 'UTC', interval '1 month')", ") AS months"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("monthSeries(3, 4) = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestSpendQuerySQL(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      spendQuery
+		wantArgs   []interface{}
+		contains   []string
+		notContain []string
+	}{
+		{
+			name:     "sum",
+			query:    spendQuery{filter: &entity.GetSubscriptionsFilter{ServiceNames: []string{"Netflix"}}},
+			wantArgs: []interface{}{pq.StringArray{"Netflix"}},
+			contains: []string{
+				"SELECT NULL::timestamptz, '', s.currency, COUNT(*), COALESCE(ROUND(SUM(",
+				"effective_from <= s.start_date",
+				" WHERE service_name = ANY($1) AND NOT EXISTS (SELECT 1 FROM app.subscription_pauses p",
+				"p.paused_at <= s.start_date",
+				"p.resumed_at >= (COALESCE(s.end_date, 'infinity'::timestamptz) AT TIME ZONE 'UTC' + interval '1 month')",
+				" GROUP BY s.currency ORDER BY s.currency",
+			},
+			notContain: []string{"generate_series"},
+		},
+		{
+			name:     "sum from the range start",
+			query:    spendQuery{filter: &entity.GetSubscriptionsFilter{StartDate: start, EndDate: end}},
+			wantArgs: []interface{}{start, end, start, end},
+			contains: []string{
+				"effective_from <= GREATEST(s.start_date, $1)",
+				" WHERE start_date >= $3 AND end_date <= $4 AND NOT EXISTS (SELECT 1 FROM app.subscription_pauses p",
+				"p.paused_at <= GREATEST(s.start_date, $1)",
+				"p.resumed_at >= (LEAST(COALESCE(s.end_date, 'infinity'::timestamptz), $2) AT TIME ZONE 'UTC' + interval '1 month')",
+			},
+		},
+		{
+			name:     "sum without filter",
+			query:    spendQuery{filter: &entity.GetSubscriptionsFilter{}},
+			wantArgs: nil,
+			contains: []string{
+				" WHERE NOT EXISTS (SELECT 1 FROM app.subscription_pauses p",
+			},
+		},
+		{
+			name: "accrual",
+			query: spendQuery{
+				filter: &entity.GetSubscriptionsFilter{StartDate: start, EndDate: end, Match: entity.DateMatchOverlaps},
+				accrue: true,
+			},
+			wantArgs: []interface{}{start, end, start, end},
+			contains: []string{
+				"JOIN (SELECT m AT TIME ZONE 'UTC' AS month FROM generate_series($1::timestamptz AT TIME ZONE 'UTC', $2::timestamptz",
+				"effective_from <= months.month",
+				"AND NOT EXISTS (SELECT 1 FROM app.subscription_pauses p",
+				"p.paused_at <= months.month",
+				"p.resumed_at >= (months.month AT TIME ZONE 'UTC' + interval '1 month')",
+				" WHERE (end_date IS NULL OR end_date >= $3) AND start_date <= $4",
+				" GROUP BY s.currency",
+			},
+			notContain: []string{"LEAST(", "infinity"},
+		},
+		{
+			name: "converted accrual",
+			query: spendQuery{
+				filter:         &entity.GetSubscriptionsFilter{StartDate: start, EndDate: end, Match: entity.DateMatchOverlaps},
+				targetCurrency: "EUR",
+				accrue:         true,
+			},
+			wantArgs: []interface{}{start, end, "EUR", start, end},
+			contains: []string{
+				"SELECT NULL::timestamptz, '', $3::text, COUNT(*),",
+				" * conversion.rate",
+				"COUNT(*) FILTER (WHERE conversion.rate IS NULL)",
+				"CASE WHEN s.currency = $3 THEN 1",
+				"AND er.effective_from <= months.month",
+				" WHERE (end_date IS NULL OR end_date >= $4) AND start_date <= $5",
+			},
+			notContain: []string{"GROUP BY"},
+		},
+		{
+			name: "monthly spend by service",
+			query: spendQuery{
+				filter:         &entity.GetSubscriptionsFilter{StartDate: start, EndDate: end, Match: entity.DateMatchOverlaps},
+				accrue:         true,
+				groupByMonth:   true,
+				groupByService: true,
+			},
+			wantArgs: []interface{}{start, end, start, end},
+			contains: []string{
+				"SELECT months.month, s.service_name, s.currency,",
+				" GROUP BY months.month, s.service_name, s.currency ORDER BY months.month, s.service_name, s.currency",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := tt.query.sql()
+
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(query, want) {
+					t.Errorf("query = %q, want it to contain %q", query, want)
+				}
+			}
+			for _, unwanted := range tt.notContain {
+				if strings.Contains(query, unwanted) {
+					t.Errorf("query = %q, want it not to contain %q", query, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestTotalsByCurrency(t *testing.T) {
+	spend := []entity.MonthlySpend{
+		{Currency: "RUB", TotalPrice: 1500, ActiveSubscriptions: 2},
+		{Currency: "USD", TotalPrice: 999, ActiveSubscriptions: 1},
+	}
+
+	got := totalsByCurrency(spend)
+	want := []entity.Money{{Amount: 1500, Currency: "RUB"}, {Amount: 999, Currency: "USD"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("totalsByCurrency() = %v, want %v", got, want)
+	}
+
+	if got := totalsByCurrency(nil); got == nil || len(got) != 0 {
+		t.Fatalf("totalsByCurrency(nil) = %#v, want an empty slice", got)
+	}
+}
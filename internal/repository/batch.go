@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// insertChunkSize bounds the rows of one multi-row insert, keeping it well below PostgreSQL's limit of 65535
+// bind parameters.
+const insertChunkSize = 1000
+
+// errBatchAborted rolls back an atomic batch in which an item failed; the failure itself is in the item's result.
+var errBatchAborted = errors.New("batch aborted")
+
+// CreateSubscriptions stores the subscriptions with multi-row inserts in a single transaction and records their
+// creation in their history.
+func (r *repository) CreateSubscriptions(ctx context.Context, subscriptions []entity.Subscription, change *entity.Change) error {
+	const eventsQuery = `INSERT INTO app.subscription_events (subscription_id, type, actor, created_at, before, after)
+		SELECT s.id, 'created', $1, $2, NULL, ` + subscriptionSnapshot + ` FROM app.subscriptions s WHERE s.id = ANY($3::uuid[])`
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		ids := make([]string, 0, len(subscriptions))
+
+		for start := 0; start < len(subscriptions); start += insertChunkSize {
+			chunk := subscriptions[start:min(start+insertChunkSize, len(subscriptions))]
+
+			var (
+				queryBuilder strings.Builder
+				args         []interface{}
+			)
+
+			queryBuilder.WriteString(`INSERT INTO app.subscriptions (id, user_id, service_name, price, currency, billing_period, billing_interval, start_date, end_date, status) VALUES `)
+
+			for i, sub := range chunk {
+				if i > 0 {
+					queryBuilder.WriteString(", ")
+				}
+
+				n := len(args)
+				queryBuilder.WriteString(fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10))
+				args = append(args, sub.ID, sub.UserID, sub.ServiceName, sub.Price.Amount, sub.Price.Currency, sub.BillingPeriod, sub.BillingInterval, sub.StartDate, sub.EndDate, sub.Status)
+
+				ids = append(ids, sub.ID.String())
+			}
+
+			_, err := tx.ExecContext(ctx, queryBuilder.String(), args...)
+			if err != nil {
+				return fmt.Errorf("insert subscriptions: %w", err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, eventsQuery, change.Actor, change.At, pq.StringArray(ids))
+		if err != nil {
+			return fmt.Errorf("insert events: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateSubscriptions applies the updates in a single transaction, each one as UpdateSubscription would. A missing
+// subscription or a version mismatch is reported in the item's result; if atomic is set, it also rolls back the
+// whole batch.
+func (r *repository) UpdateSubscriptions(ctx context.Context, updates []entity.SubscriptionUpdate, atomic bool, change *entity.Change) ([]entity.BatchItemResult, error) {
+	return r.applyBatch(ctx, len(updates), atomic, func(tx *sql.Tx, i int) (uuid.UUID, int64, error) {
+		version, err := updateSubscription(ctx, tx, updates[i].ID, updates[i].Data, updates[i].IfMatch, change)
+		return updates[i].ID, version, err
+	})
+}
+
+// TransitionSubscriptions applies the transitions in a single transaction, each one as TransitionSubscription would
+// with the matching element of ifMatch. A missing subscription or a version mismatch is reported in the item's
+// result; if atomic is set, it also rolls back the whole batch.
+func (r *repository) TransitionSubscriptions(ctx context.Context, transitions []entity.StatusTransition, ifMatch [][]int64, atomic bool) ([]entity.BatchItemResult, error) {
+	return r.applyBatch(ctx, len(transitions), atomic, func(tx *sql.Tx, i int) (uuid.UUID, int64, error) {
+		version, err := transitionSubscription(ctx, tx, &transitions[i], ifMatch[i])
+		return transitions[i].SubscriptionID, version, err
+	})
+}
+
+// applyBatch calls apply for each of n items in a single transaction. ErrRepoNotFound and ErrRepoVersionMismatch
+// fail only the item, unless atomic is set; any other error fails the batch.
+func (r *repository) applyBatch(ctx context.Context, n int, atomic bool, apply func(tx *sql.Tx, i int) (uuid.UUID, int64, error)) ([]entity.BatchItemResult, error) {
+	results := make([]entity.BatchItemResult, n)
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		failed := false
+
+		for i := range results {
+			id, version, err := apply(tx, i)
+			if err != nil && !errors.Is(err, ErrRepoNotFound) && !errors.Is(err, ErrRepoVersionMismatch) {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+
+			results[i] = entity.BatchItemResult{ID: id, Version: version, Err: err}
+			failed = failed || err != nil
+		}
+
+		if atomic && failed {
+			return errBatchAborted
+		}
+
+		return nil
+	})
+	if errors.Is(err, errBatchAborted) {
+		// Nothing was written, so the versions of the items that succeeded do not exist.
+		for i := range results {
+			results[i].Version = 0
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -3,5 +3,8 @@ package repository
 import "errors"
 
 var (
-	ErrRepoNotFound = errors.New("repository: not found")
+	ErrRepoNotFound             = errors.New("repository: not found")
+	ErrRepoExchangeRateNotFound = errors.New("repository: exchange rate not found")
+	ErrRepoVersionMismatch      = errors.New("repository: version mismatch")
+	ErrRepoIdempotencyKeyReused = errors.New("repository: idempotency key reused with a different request")
 )
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+)
+
+// subscriptionSnapshot renders subscription s, including its price changes, as stored in the history.
+const subscriptionSnapshot = `to_jsonb(s) || jsonb_build_object('price_changes', COALESCE((
+		SELECT jsonb_agg(jsonb_build_object('effective_from', sp.effective_from, 'price', sp.price) ORDER BY sp.effective_from)
+		FROM app.subscription_prices sp WHERE sp.subscription_id = s.id
+	), '[]'::jsonb))`
+
+// lockSubscription locks the subscription row for the rest of the transaction and returns its snapshot as
+// stored in the history. It returns ErrRepoNotFound if there is no such subscription.
+func lockSubscription(ctx context.Context, tx *sql.Tx, id uuid.UUID) (json.RawMessage, error) {
+	const query = `SELECT ` + subscriptionSnapshot + ` FROM app.subscriptions s WHERE s.id = $1 FOR UPDATE OF s`
+
+	var snapshot []byte
+	err := tx.QueryRowContext(ctx, query, id).Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRepoNotFound
+		}
+		return nil, fmt.Errorf("query row: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// insertEvent records a change of the subscription. The After snapshot is taken from the subscription row as it is
+// now, unless the event is a deletion.
+func insertEvent(ctx context.Context, q querier, event *entity.SubscriptionEvent) error {
+	const query = `INSERT INTO app.subscription_events (subscription_id, type, actor, created_at, before, after)
+		VALUES ($1, $2, $3, $4, $5::jsonb, (SELECT ` + subscriptionSnapshot + ` FROM app.subscriptions s WHERE s.id = $1 AND $2 <> 'deleted'))`
+
+	_, err := q.ExecContext(ctx, query, event.SubscriptionID, event.Type, event.Actor, event.CreatedAt, jsonArg(event.Before))
+	if err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+
+	return nil
+}
+
+// GetSubscriptionEvents returns the history of the subscription, oldest first. The history of a deleted
+// subscription is kept.
+func (r *repository) GetSubscriptionEvents(ctx context.Context, id uuid.UUID) (_ []entity.SubscriptionEvent, err error) {
+	const query = `SELECT id, subscription_id, type, actor, created_at, before, after FROM app.subscription_events
+		WHERE subscription_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	events := make([]entity.SubscriptionEvent, 0)
+
+	for rows.Next() {
+		var (
+			event         entity.SubscriptionEvent
+			before, after []byte
+		)
+		err = rows.Scan(&event.ID, &event.SubscriptionID, &event.Type, &event.Actor, &event.CreatedAt, &before, &after)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+
+		event.CreatedAt = event.CreatedAt.UTC()
+		event.Before = before
+		event.After = after
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return events, nil
+}
+
+// jsonArg passes a JSON document as a query argument; lib/pq would send a raw []byte as bytea.
+func jsonArg(doc json.RawMessage) *string {
+	if doc == nil {
+		return nil
+	}
+
+	s := string(doc)
+	return &s
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONArg(t *testing.T) {
+	if got := jsonArg(nil); got != nil {
+		t.Fatalf("jsonArg(nil) = %q, want NULL", *got)
+	}
+
+	got := jsonArg(json.RawMessage(`{"price":999}`))
+	if got == nil || *got != `{"price":999}` {
+		t.Fatalf("jsonArg() = %v, want the document as text", got)
+	}
+}
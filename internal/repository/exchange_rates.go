@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"strings"
+)
+
+// UpsertExchangeRates stores the rates in a single transaction, replacing rates of the same pair and month.
+func (r *repository) UpsertExchangeRates(ctx context.Context, rates []entity.ExchangeRate) (err error) {
+	const query = `INSERT INTO app.exchange_rates (base_currency, quote_currency, effective_from, rate) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, effective_from) DO UPDATE SET rate = excluded.rate`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				err = errors.Join(err, fmt.Errorf("rollback tx: %w", rollbackErr))
+			}
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer func() {
+		closeErr := stmt.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close statement: %w", closeErr))
+		}
+	}()
+
+	for _, rate := range rates {
+		_, err = stmt.ExecContext(ctx, rate.BaseCurrency, rate.QuoteCurrency, rate.EffectiveFrom, rate.Rate)
+		if err != nil {
+			return fmt.Errorf("exec statement: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetExchangeRates(ctx context.Context, filter *entity.GetExchangeRatesFilter) (_ []entity.ExchangeRate, err error) {
+	var (
+		queryBuilder strings.Builder
+		args         []interface{}
+		conditions   []string
+	)
+
+	queryBuilder.WriteString(`SELECT base_currency, quote_currency, effective_from, rate FROM app.exchange_rates`)
+
+	if filter.BaseCurrency != "" {
+		conditions = append(conditions, fmt.Sprintf("base_currency = $%d", len(args)+1))
+		args = append(args, filter.BaseCurrency)
+	}
+
+	if filter.QuoteCurrency != "" {
+		conditions = append(conditions, fmt.Sprintf("quote_currency = $%d", len(args)+1))
+		args = append(args, filter.QuoteCurrency)
+	}
+
+	if len(conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	queryBuilder.WriteString(" ORDER BY base_currency, quote_currency, effective_from")
+
+	query := queryBuilder.String()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	rates := make([]entity.ExchangeRate, 0)
+
+	for rows.Next() {
+		var rate entity.ExchangeRate
+		err = rows.Scan(&rate.BaseCurrency, &rate.QuoteCurrency, &rate.EffectiveFrom, &rate.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		rate.EffectiveFrom = rate.EffectiveFrom.UTC()
+		rates = append(rates, rate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return rates, nil
+}
+
+func (r *repository) DeleteExchangeRate(ctx context.Context, rate *entity.ExchangeRate) error {
+	const query = `DELETE FROM app.exchange_rates WHERE base_currency = $1 AND quote_currency = $2 AND effective_from = $3`
+
+	res, err := r.db.ExecContext(ctx, query, rate.BaseCurrency, rate.QuoteCurrency, rate.EffectiveFrom)
+	if err != nil {
+		return fmt.Errorf("exec query: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepoNotFound
+	}
+
+	return nil
+}
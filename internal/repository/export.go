@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"strings"
+)
+
+// StreamSubscriptions calls fn with every subscription matching the filter, in sort order, as the rows are read
+// from the database, so the result is never held in memory. Price changes are not loaded. An error returned by fn
+// stops the iteration and is returned as is.
+func (r *repository) StreamSubscriptions(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) (err error) {
+	column, ok := sortColumns[sort.Field]
+	if !ok {
+		return fmt.Errorf("unknown sort field %q", sort.Field)
+	}
+
+	direction := "ASC"
+	if sort.Order == entity.SortDesc {
+		direction = "DESC"
+	}
+
+	var queryBuilder strings.Builder
+
+	queryBuilder.WriteString(`SELECT ` + subscriptionColumns + ` FROM app.subscriptions`)
+
+	conditions, args := filterConditions(filter, nil)
+	if len(conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY %s %s, id %s", column.name, direction, direction))
+
+	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return fmt.Errorf("scan row: %w", err)
+		}
+
+		err = fn(subscription)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return nil
+}
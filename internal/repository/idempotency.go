@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+)
+
+// CreateSubscriptionIdempotent creates the subscription unless key.Key was already used. A key used with the
+// same request hash returns the ID of the subscription it created; a key used with a different hash fails with
+// ErrRepoIdempotencyKeyReused. Expired keys are removed first and can be reused.
+func (r *repository) CreateSubscriptionIdempotent(ctx context.Context, subscription *entity.Subscription, key *entity.IdempotencyKey, change *entity.Change) (uuid.UUID, error) {
+	const (
+		deleteExpiredQuery = `DELETE FROM app.idempotency_keys WHERE expires_at <= $1`
+		claimQuery         = `INSERT INTO app.idempotency_keys (key, request_hash, subscription_id, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (key) DO NOTHING`
+		storedQuery = `SELECT request_hash, subscription_id FROM app.idempotency_keys WHERE key = $1`
+	)
+
+	id := subscription.ID
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, deleteExpiredQuery, key.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("delete expired idempotency keys: %w", err)
+		}
+
+		// A concurrent request with the same key blocks here until the other transaction finishes.
+		res, err := tx.ExecContext(ctx, claimQuery, key.Key, key.RequestHash, subscription.ID, key.CreatedAt, key.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("insert idempotency key: %w", err)
+		}
+
+		claimed, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		}
+
+		if claimed == 0 {
+			var requestHash string
+
+			err = tx.QueryRowContext(ctx, storedQuery, key.Key).Scan(&requestHash, &id)
+			if err != nil {
+				return fmt.Errorf("query row: %w", err)
+			}
+
+			if requestHash != key.RequestHash {
+				return ErrRepoIdempotencyKeyReused
+			}
+
+			return nil
+		}
+
+		return insertSubscription(ctx, tx, subscription, change)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
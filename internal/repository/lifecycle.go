@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"time"
+)
+
+// TransitionSubscription moves the subscription from transition.From to transition.To if its version is one of
+// ifMatch, records the transition in the subscription's history and returns the new version. An empty ifMatch
+// matches any version. Pausing opens a pause interval and resuming closes it; the interval of a paused
+// subscription that is cancelled or expires stays open.
+func (r *repository) TransitionSubscription(ctx context.Context, transition *entity.StatusTransition, ifMatch []int64) (int64, error) {
+	var version int64
+
+	err := r.inTx(ctx, func(tx *sql.Tx) (err error) {
+		version, err = transitionSubscription(ctx, tx, transition, ifMatch)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// transitionSubscription is TransitionSubscription within tx.
+func transitionSubscription(ctx context.Context, tx *sql.Tx, transition *entity.StatusTransition, ifMatch []int64) (int64, error) {
+	const (
+		updateQuery = `UPDATE app.subscriptions
+			SET status = $1::text,
+				cancelled_at = CASE WHEN $1::text = 'cancelled' THEN $2 ELSE cancelled_at END,
+				end_date = COALESCE($3, end_date),
+				version = version + 1
+			WHERE id = $4 AND status = $5 AND ($6::bigint[] IS NULL OR version = ANY($6)) RETURNING version`
+		pauseQuery  = `INSERT INTO app.subscription_pauses (subscription_id, paused_at) VALUES ($1, $2)`
+		resumeQuery = `UPDATE app.subscription_pauses SET resumed_at = $1 WHERE subscription_id = $2 AND resumed_at IS NULL`
+	)
+
+	before, err := lockSubscription(ctx, tx, transition.SubscriptionID)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int64
+	err = tx.QueryRowContext(ctx, updateQuery, transition.To, transition.At, transition.EndDate, transition.SubscriptionID, transition.From, versionsArg(ifMatch)).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Every status change bumps the version, so a changed status is reported as a version mismatch.
+			return 0, ErrRepoVersionMismatch
+		}
+		return 0, fmt.Errorf("update subscription status: %w", err)
+	}
+
+	err = insertTransition(ctx, tx, transition)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case transition.To == entity.SubscriptionStatusPaused:
+		_, err = tx.ExecContext(ctx, pauseQuery, transition.SubscriptionID, transition.At)
+		if err != nil {
+			return 0, fmt.Errorf("insert pause: %w", err)
+		}
+	case transition.From == entity.SubscriptionStatusPaused && transition.To == entity.SubscriptionStatusActive:
+		_, err = tx.ExecContext(ctx, resumeQuery, transition.At, transition.SubscriptionID)
+		if err != nil {
+			return 0, fmt.Errorf("close pause: %w", err)
+		}
+	}
+
+	err = insertEvent(ctx, tx, &entity.SubscriptionEvent{
+		SubscriptionID: transition.SubscriptionID,
+		Type:           entity.SubscriptionEventStatusChanged,
+		Actor:          transition.Actor,
+		CreatedAt:      transition.At,
+		Before:         before,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func insertTransition(ctx context.Context, q querier, transition *entity.StatusTransition) error {
+	const query = `INSERT INTO app.subscription_transitions (subscription_id, from_status, to_status, actor, created_at) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := q.ExecContext(ctx, query, transition.SubscriptionID, transition.From, transition.To, transition.Actor, transition.At)
+	if err != nil {
+		return fmt.Errorf("insert transition: %w", err)
+	}
+
+	return nil
+}
+
+// ExpireSubscriptions moves every trial, active or paused subscription that ended before endedBefore to the
+// expired status, recording the transition and the change in each subscription's history, and returns how many
+// subscriptions expired.
+func (r *repository) ExpireSubscriptions(ctx context.Context, endedBefore time.Time, change *entity.Change) (int64, error) {
+	const query = `WITH due AS (
+			SELECT s.id, s.status, ` + subscriptionSnapshot + ` AS before FROM app.subscriptions s
+			WHERE s.end_date < $1 AND s.status IN ('trial', 'active', 'paused')
+			FOR UPDATE OF s
+		), expired AS (
+			UPDATE app.subscriptions s SET status = 'expired', version = s.version + 1
+			FROM due WHERE s.id = due.id
+			RETURNING s.id, ` + subscriptionSnapshot + ` AS after
+		), transitions AS (
+			INSERT INTO app.subscription_transitions (subscription_id, from_status, to_status, actor, created_at)
+			SELECT due.id, due.status, 'expired', $2, $3 FROM due JOIN expired ON expired.id = due.id
+		)
+		INSERT INTO app.subscription_events (subscription_id, type, actor, created_at, before, after)
+		SELECT due.id, 'status_changed', $2, $3, due.before, expired.after FROM due JOIN expired ON expired.id = due.id`
+
+	res, err := r.db.ExecContext(ctx, query, endedBefore, change.Actor, change.At)
+	if err != nil {
+		return 0, fmt.Errorf("exec query: %w", err)
+	}
+
+	expired, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+
+	return expired, nil
+}
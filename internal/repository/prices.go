@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SchedulePriceChange stores the price change of the subscription, replacing a change effective from the same
+// month, if the subscription's version is one of ifMatch. It records the change in the subscription's history
+// and returns the new version. An empty ifMatch matches any version.
+func (r *repository) SchedulePriceChange(ctx context.Context, id uuid.UUID, priceChange *entity.PriceChange, ifMatch []int64, change *entity.Change) (int64, error) {
+	const (
+		versionQuery = `UPDATE app.subscriptions SET version = version + 1
+			WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2)) RETURNING version`
+		priceQuery = `INSERT INTO app.subscription_prices (subscription_id, effective_from, price) VALUES ($1, $2, $3)
+			ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = excluded.price`
+	)
+
+	var version int64
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, versionQuery, id, versionsArg(ifMatch)).Scan(&version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRepoVersionMismatch
+			}
+			return fmt.Errorf("query row: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, priceQuery, id, priceChange.EffectiveFrom, priceChange.Price.Amount)
+		if err != nil {
+			return fmt.Errorf("upsert price: %w", err)
+		}
+
+		return insertEvent(ctx, tx, &entity.SubscriptionEvent{
+			SubscriptionID: id,
+			Type:           entity.SubscriptionEventUpdated,
+			Actor:          change.Actor,
+			CreatedAt:      change.At,
+			Before:         before,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// attachPriceChanges loads the price changes of the subscriptions into their PriceChanges. Their amounts are minor
+// units of the subscription's currency, which the service keeps from changing while any are scheduled.
+func (r *repository) attachPriceChanges(ctx context.Context, subscriptions []entity.Subscription) (err error) {
+	const query = `SELECT subscription_id, effective_from, price FROM app.subscription_prices
+		WHERE subscription_id = ANY($1::uuid[]) ORDER BY subscription_id, effective_from`
+
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(subscriptions))
+	byID := make(map[uuid.UUID]*entity.Subscription, len(subscriptions))
+	for i := range subscriptions {
+		ids = append(ids, subscriptions[i].ID.String())
+		byID[subscriptions[i].ID] = &subscriptions[i]
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.StringArray(ids))
+	if err != nil {
+		return fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			id          uuid.UUID
+			priceChange entity.PriceChange
+		)
+		err = rows.Scan(&id, &priceChange.EffectiveFrom, &priceChange.Price.Amount)
+		if err != nil {
+			return fmt.Errorf("scan row: %w", err)
+		}
+
+		subscription := byID[id]
+		priceChange.EffectiveFrom = priceChange.EffectiveFrom.UTC()
+		priceChange.Price.Currency = subscription.Price.Currency
+		subscription.PriceChanges = append(subscription.PriceChanges, priceChange)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return nil
+}
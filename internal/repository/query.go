@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/lib/pq"
+	"sort"
+	"strings"
+)
+
+const subscriptionColumns = `id, user_id, service_name, price, currency, billing_period, billing_interval, start_date, end_date, status, cancelled_at, version`
+
+// monthlyPrice normalises priceExpr, a price of subscription s, to the price of one month, in minor units.
+func monthlyPrice(priceExpr string) string {
+	return fmt.Sprintf(`(%s::numeric * CASE s.billing_period
+	WHEN 'weekly' THEN 52.0 / 12
+	WHEN 'quarterly' THEN 1.0 / 3
+	WHEN 'yearly' THEN 1.0 / 12
+	ELSE 1 END / s.billing_interval)`, priceExpr)
+}
+
+// effectivePrice selects the price of subscription s in effect at monthExpr: the latest price change effective
+// by then, or the subscription's initial price.
+func effectivePrice(monthExpr string) string {
+	return fmt.Sprintf(`COALESCE((
+		SELECT sp.price FROM app.subscription_prices sp
+		WHERE sp.subscription_id = s.id AND sp.effective_from <= %s
+		ORDER BY sp.effective_from DESC
+		LIMIT 1
+	), s.price)`, monthExpr)
+}
+
+// sortColumn describes a keyset pagination column. name may be an expression; sqlType is what the cursor value
+// is cast to before the comparison.
+type sortColumn struct {
+	name    string
+	sqlType string
+}
+
+var sortColumns = map[entity.SortField]sortColumn{
+	entity.SortByStartDate:   {name: "start_date", sqlType: "timestamptz"},
+	entity.SortByEndDate:     {name: "COALESCE(end_date, 'infinity'::timestamptz)", sqlType: "timestamptz"},
+	entity.SortByPrice:       {name: "price", sqlType: "bigint"},
+	entity.SortByServiceName: {name: "service_name", sqlType: "text"},
+}
+
+// filterConditions appends placeholders for the filter to args and returns the WHERE conditions
+// together with the extended args.
+func filterConditions(filter *entity.GetSubscriptionsFilter, args []interface{}) ([]string, []interface{}) {
+	var conditions []string
+
+	if filter == nil {
+		return conditions, args
+	}
+
+	if len(filter.ServiceNames) > 0 {
+		conditions = append(conditions, fmt.Sprintf("service_name = ANY($%d)", len(args)+1))
+		args = append(args, pq.StringArray(filter.ServiceNames))
+	}
+
+	if len(filter.UserIDs) > 0 {
+		userIDs := make([]string, 0, len(filter.UserIDs))
+		for _, id := range filter.UserIDs {
+			userIDs = append(userIDs, id.String())
+		}
+		conditions = append(conditions, fmt.Sprintf("user_id = ANY($%d::uuid[])", len(args)+1))
+		args = append(args, pq.StringArray(userIDs))
+	}
+
+	if filter.OwnerID != nil {
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)+1))
+		args = append(args, *filter.OwnerID)
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)+1))
+		args = append(args, pq.StringArray(statuses))
+	}
+
+	conditions, args = dateConditions(filter, conditions, args)
+
+	if filter.PriceMin != nil {
+		conditions = append(conditions, fmt.Sprintf("price >= $%d::numeric * %s", len(args)+1, minorUnitScale("currency")))
+		args = append(args, *filter.PriceMin)
+	}
+
+	if filter.PriceMax != nil {
+		conditions = append(conditions, fmt.Sprintf("price <= $%d::numeric * %s", len(args)+1, minorUnitScale("currency")))
+		args = append(args, *filter.PriceMax)
+	}
+
+	return conditions, args
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSubscription scans a row selected with subscriptionColumns.
+func scanSubscription(row scanner) (*entity.Subscription, error) {
+	var subscription entity.Subscription
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.ServiceName,
+		&subscription.Price.Amount,
+		&subscription.Price.Currency,
+		&subscription.BillingPeriod,
+		&subscription.BillingInterval,
+		&subscription.StartDate,
+		&subscription.EndDate,
+		&subscription.Status,
+		&subscription.CancelledAt,
+		&subscription.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+func scanSubscriptions(rows *sql.Rows) ([]entity.Subscription, error) {
+	subscriptions := make([]entity.Subscription, 0)
+
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func dateConditions(filter *entity.GetSubscriptionsFilter, conditions []string, args []interface{}) ([]string, []interface{}) {
+	switch filter.Match {
+	case entity.DateMatchOverlaps:
+		if !filter.StartDate.IsZero() {
+			conditions = append(conditions, fmt.Sprintf("(end_date IS NULL OR end_date >= $%d)", len(args)+1))
+			args = append(args, filter.StartDate)
+		}
+
+		if !filter.EndDate.IsZero() {
+			conditions = append(conditions, fmt.Sprintf("start_date <= $%d", len(args)+1))
+			args = append(args, filter.EndDate)
+		}
+	case entity.DateMatchActiveAt:
+		if !filter.StartDate.IsZero() {
+			conditions = append(conditions, fmt.Sprintf("start_date <= $%d AND (end_date IS NULL OR end_date >= $%d)", len(args)+1, len(args)+1))
+			args = append(args, filter.StartDate)
+		}
+	default:
+		if !filter.StartDate.IsZero() {
+			conditions = append(conditions, fmt.Sprintf("start_date >= $%d", len(args)+1))
+			args = append(args, filter.StartDate)
+		}
+
+		if !filter.EndDate.IsZero() {
+			conditions = append(conditions, fmt.Sprintf("end_date <= $%d", len(args)+1))
+			args = append(args, filter.EndDate)
+		}
+	}
+
+	return conditions, args
+}
+
+// minorUnitScale returns an SQL expression for the number of minor units in one major unit of the currency
+// held by currencyExpr.
+func minorUnitScale(currencyExpr string) string {
+	currencies := make([]string, 0, len(entity.CurrencyMinorUnits))
+	for currency := range entity.CurrencyMinorUnits {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var builder strings.Builder
+
+	builder.WriteString("(CASE ")
+	builder.WriteString(currencyExpr)
+	for _, currency := range currencies {
+		builder.WriteString(fmt.Sprintf(" WHEN '%s' THEN %d", currency, pow10(entity.CurrencyMinorUnits[currency])))
+	}
+	builder.WriteString(fmt.Sprintf(" ELSE %d END)", pow10(entity.DefaultMinorUnits)))
+
+	return builder.String()
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+	return result
+}
+
+// versionsArg turns an If-Match version list into a query argument that is NULL when no version is required.
+func versionsArg(versions []int64) pq.Int64Array {
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions
+}
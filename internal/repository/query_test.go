@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMinorUnitScale(t *testing.T) {
+	got := minorUnitScale("s.currency")
+
+	if !strings.HasPrefix(got, "(CASE s.currency WHEN ") || !strings.HasSuffix(got, " ELSE 100 END)") {
+		t.Fatalf("minorUnitScale() = %q, want a CASE on s.currency defaulting to 100", got)
+	}
+
+	for _, want := range []string{"WHEN 'JPY' THEN 1 ", "WHEN 'KWD' THEN 1000 ", "WHEN 'BHD' THEN 1000 "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("minorUnitScale() = %q, want it to contain %q", got, want)
+		}
+	}
+
+	if strings.Count(got, " WHEN ") != len(entity.CurrencyMinorUnits) {
+		t.Errorf("minorUnitScale() has %d branches, want %d", strings.Count(got, " WHEN "), len(entity.CurrencyMinorUnits))
+	}
+
+	if again := minorUnitScale("s.currency"); again != got {
+		t.Error("minorUnitScale() is not deterministic")
+	}
+}
+
+func TestPow10(t *testing.T) {
+	for n, want := range []int64{1, 10, 100, 1000} {
+		if got := pow10(n); got != want {
+			t.Errorf("pow10(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestFilterConditions(t *testing.T) {
+	userID, owner := uuid.New(), uuid.New()
+	priceMin, priceMax := "5", "10.50"
+
+	filter := &entity.GetSubscriptionsFilter{
+		UserIDs:      []uuid.UUID{userID},
+		ServiceNames: []string{"Netflix", "Spotify"},
+		Statuses:     []entity.SubscriptionStatus{entity.SubscriptionStatusActive},
+		OwnerID:      &owner,
+		PriceMin:     &priceMin,
+		PriceMax:     &priceMax,
+	}
+
+	// One argument is already taken, so the placeholders start at $2.
+	conditions, args := filterConditions(filter, []interface{}{"taken"})
+
+	want := []string{
+		"service_name = ANY($2)",
+		"user_id = ANY($3::uuid[])",
+		"user_id = $4",
+		"status = ANY($5)",
+		"price >= $6::numeric * " + minorUnitScale("currency"),
+		"price <= $7::numeric * " + minorUnitScale("currency"),
+	}
+	if !slices.Equal(conditions, want) {
+		t.Fatalf("conditions = %q, want %q", conditions, want)
+	}
+
+	wantArgs := []interface{}{
+		"taken",
+		pq.StringArray{"Netflix", "Spotify"},
+		pq.StringArray{userID.String()},
+		owner,
+		pq.StringArray{"active"},
+		priceMin,
+		priceMax,
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestFilterConditionsEmpty(t *testing.T) {
+	for _, filter := range []*entity.GetSubscriptionsFilter{nil, {}} {
+		conditions, args := filterConditions(filter, nil)
+		if len(conditions) != 0 || len(args) != 0 {
+			t.Fatalf("filterConditions(%+v) = %q, %v, want nothing", filter, conditions, args)
+		}
+	}
+}
+
+func TestDateConditions(t *testing.T) {
+	july := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		filter   entity.GetSubscriptionsFilter
+		want     []string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "contained",
+			filter:   entity.GetSubscriptionsFilter{StartDate: july, EndDate: december, Match: entity.DateMatchContained},
+			want:     []string{"start_date >= $1", "end_date <= $2"},
+			wantArgs: []interface{}{july, december},
+		},
+		{
+			name:     "default is contained",
+			filter:   entity.GetSubscriptionsFilter{StartDate: july},
+			want:     []string{"start_date >= $1"},
+			wantArgs: []interface{}{july},
+		},
+		{
+			name:     "overlaps",
+			filter:   entity.GetSubscriptionsFilter{StartDate: july, EndDate: december, Match: entity.DateMatchOverlaps},
+			want:     []string{"(end_date IS NULL OR end_date >= $1)", "start_date <= $2"},
+			wantArgs: []interface{}{july, december},
+		},
+		{
+			name:     "overlaps open start",
+			filter:   entity.GetSubscriptionsFilter{EndDate: december, Match: entity.DateMatchOverlaps},
+			want:     []string{"start_date <= $1"},
+			wantArgs: []interface{}{december},
+		},
+		{
+			name:     "active at",
+			filter:   entity.GetSubscriptionsFilter{StartDate: july, EndDate: july, Match: entity.DateMatchActiveAt},
+			want:     []string{"start_date <= $1 AND (end_date IS NULL OR end_date >= $1)"},
+			wantArgs: []interface{}{july},
+		},
+		{
+			name:   "no dates",
+			filter: entity.GetSubscriptionsFilter{Match: entity.DateMatchOverlaps},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, args := dateConditions(&tt.filter, nil, nil)
+			if !slices.Equal(conditions, tt.want) {
+				t.Fatalf("conditions = %q, want %q", conditions, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMonthlyPrice(t *testing.T) {
+	got := monthlyPrice("s.price")
+
+	for _, want := range []string{
+		"(s.price::numeric * CASE s.billing_period",
+		"WHEN 'weekly' THEN 52.0 / 12",
+		"WHEN 'quarterly' THEN 1.0 / 3",
+		"WHEN 'yearly' THEN 1.0 / 12",
+		"ELSE 1 END / s.billing_interval)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("monthlyPrice() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestVersionsArg(t *testing.T) {
+	if got := versionsArg(nil); got != nil {
+		t.Fatalf("versionsArg(nil) = %v, want NULL", got)
+	}
+	if got := versionsArg([]int64{}); got != nil {
+		t.Fatalf("versionsArg([]) = %v, want NULL", got)
+	}
+	if got := versionsArg([]int64{2, 3}); !slices.Equal(got, pq.Int64Array{2, 3}) {
+		t.Fatalf("versionsArg([2 3]) = %v, want [2 3]", got)
+	}
+}
@@ -18,25 +18,39 @@ func New(db *sql.DB) *repository {
 	return &repository{db: db}
 }
 
-func (r *repository) CreateSubscription(ctx context.Context, subscription *entity.Subscription) (uuid.UUID, error) {
-	const query = `INSERT INTO app.subscriptions (id, user_id, service_name, price, start_date, end_date) VALUES ($1, $2, $3, $4, $5, $6)`
-
-	_, err := r.db.ExecContext(ctx, query, subscription.ID, subscription.UserID, subscription.ServiceName, subscription.Price, subscription.StartDate, subscription.EndDate)
+// CreateSubscription stores the subscription and records its creation in the history.
+func (r *repository) CreateSubscription(ctx context.Context, subscription *entity.Subscription, change *entity.Change) (uuid.UUID, error) {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		return insertSubscription(ctx, tx, subscription, change)
+	})
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("exec sql query: %w", err)
+		return uuid.Nil, err
 	}
 
 	return subscription.ID, nil
 }
 
-func (r *repository) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*entity.Subscription, error) {
-	const query = `SELECT user_id, service_name, price, start_date, end_date FROM app.subscriptions WHERE id = $1`
+// insertSubscription stores the subscription together with its created event.
+func insertSubscription(ctx context.Context, tx *sql.Tx, subscription *entity.Subscription, change *entity.Change) error {
+	const query = `INSERT INTO app.subscriptions (id, user_id, service_name, price, currency, billing_period, billing_interval, start_date, end_date, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
-	res := entity.Subscription{
-		ID: id,
+	_, err := tx.ExecContext(ctx, query, subscription.ID, subscription.UserID, subscription.ServiceName, subscription.Price.Amount, subscription.Price.Currency, subscription.BillingPeriod, subscription.BillingInterval, subscription.StartDate, subscription.EndDate, subscription.Status)
+	if err != nil {
+		return fmt.Errorf("exec sql query: %w", err)
 	}
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&res.UserID, &res.ServiceName, &res.Price, &res.StartDate, &res.EndDate)
+	return insertEvent(ctx, tx, &entity.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		Type:           entity.SubscriptionEventCreated,
+		Actor:          change.Actor,
+		CreatedAt:      change.At,
+	})
+}
+
+func (r *repository) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*entity.Subscription, error) {
+	const query = `SELECT ` + subscriptionColumns + ` FROM app.subscriptions WHERE id = $1`
+
+	res, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrRepoNotFound
@@ -44,78 +58,120 @@ func (r *repository) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*en
 		return nil, fmt.Errorf("query row: %w", err)
 	}
 
-	return &res, nil
-}
+	subscriptions := []entity.Subscription{*res}
 
-func (r *repository) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error {
-	const query = `DELETE FROM app.subscriptions WHERE id = $1`
-
-	res, err := r.db.ExecContext(ctx, query, id)
+	err = r.attachPriceChanges(ctx, subscriptions)
 	if err != nil {
-		return fmt.Errorf("exec query: %w", err)
+		return nil, fmt.Errorf("attach price changes: %w", err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("get rows affected: %w", err)
-	}
+	return &subscriptions[0], nil
+}
+
+// DeleteSubscriptionByID deletes the subscription if its version is one of ifMatch and records the deletion in
+// its history. An empty ifMatch matches any version.
+func (r *repository) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID, ifMatch []int64, change *entity.Change) error {
+	const query = `DELETE FROM app.subscriptions WHERE id = $1 AND ($2::bigint[] IS NULL OR version = ANY($2))`
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, query, id, versionsArg(ifMatch))
+		if err != nil {
+			return fmt.Errorf("exec query: %w", err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return ErrRepoVersionMismatch
+		}
+
+		return insertEvent(ctx, tx, &entity.SubscriptionEvent{
+			SubscriptionID: id,
+			Type:           entity.SubscriptionEventDeleted,
+			Actor:          change.Actor,
+			CreatedAt:      change.At,
+			Before:         before,
+		})
+	})
+}
+
+// UpdateSubscription updates the subscription if its version is one of ifMatch, records the update in its history
+// and returns the new version. An empty ifMatch matches any version.
+func (r *repository) UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, change *entity.Change) (int64, error) {
+	var version int64
 
-	if rowsAffected == 0 {
-		return ErrRepoNotFound
+	err := r.inTx(ctx, func(tx *sql.Tx) (err error) {
+		version, err = updateSubscription(ctx, tx, id, data, ifMatch, change)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (r *repository) UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData) error {
-	const query = `UPDATE app.subscriptions SET price = $1, service_name = $2, start_date = $3, end_date = $4 WHERE id = $5`
+// updateSubscription is UpdateSubscription within tx.
+func updateSubscription(ctx context.Context, tx *sql.Tx, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, change *entity.Change) (int64, error) {
+	const query = `UPDATE app.subscriptions SET price = $1, currency = $2, service_name = $3, billing_period = $4, billing_interval = $5, start_date = $6, end_date = $7, version = version + 1
+		WHERE id = $8 AND ($9::bigint[] IS NULL OR version = ANY($9)) RETURNING version`
 
-	res, err := r.db.ExecContext(ctx, query, data.Price, data.ServiceName, data.StartDate, data.EndDate, id)
+	before, err := lockSubscription(ctx, tx, id)
 	if err != nil {
-		return fmt.Errorf("exec sql query: %w", err)
+		return 0, err
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	var version int64
+	err = tx.QueryRowContext(ctx, query, data.Price.Amount, data.Price.Currency, data.ServiceName, data.BillingPeriod, data.BillingInterval, data.StartDate, data.EndDate, id, versionsArg(ifMatch)).Scan(&version)
 	if err != nil {
-		return fmt.Errorf("get rows affected: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRepoVersionMismatch
+		}
+		return 0, fmt.Errorf("query row: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return ErrRepoNotFound
+	err = insertEvent(ctx, tx, &entity.SubscriptionEvent{
+		SubscriptionID: id,
+		Type:           entity.SubscriptionEventUpdated,
+		Actor:          change.Actor,
+		CreatedAt:      change.At,
+		Before:         before,
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (r *repository) GetAllSubscriptionsFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter) (_ []entity.Subscription, err error) {
-	var (
-		queryBuilder strings.Builder
-		args         []interface{}
-		conditions   []string
-	)
+func (r *repository) ListSubscriptions(ctx context.Context, params *entity.ListSubscriptionsParams) (_ []entity.Subscription, err error) {
+	column, ok := sortColumns[params.Sort.Field]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q", params.Sort.Field)
+	}
 
-	queryBuilder.WriteString(`SELECT id, user_id, service_name, price, start_date, end_date FROM app.subscriptions`)
+	direction, comparison := "ASC", ">"
+	if params.Sort.Order == entity.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
 
-	if filter != nil {
-		if filter.ServiceName != "" {
-			conditions = append(conditions, fmt.Sprintf("service_name = $%d", len(args)+1))
-			args = append(args, filter.ServiceName)
-		}
+	var queryBuilder strings.Builder
 
-		if filter.UserID != uuid.Nil {
-			conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)+1))
-			args = append(args, filter.UserID)
-		}
+	queryBuilder.WriteString(`SELECT ` + subscriptionColumns + ` FROM app.subscriptions`)
 
-		if !filter.StartDate.IsZero() {
-			conditions = append(conditions, fmt.Sprintf("start_date >= $%d", len(args)+1))
-			args = append(args, filter.StartDate)
-		}
+	conditions, args := filterConditions(params.Filter, nil)
 
-		if !filter.EndDate.IsZero() {
-			conditions = append(conditions, fmt.Sprintf("end_date <= $%d", len(args)+1))
-			args = append(args, filter.EndDate)
-		}
+	if params.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", column.name, comparison, len(args)+1, column.sqlType, len(args)+2))
+		args = append(args, params.Cursor.Value, params.Cursor.ID)
 	}
 
 	if len(conditions) > 0 {
@@ -123,6 +179,9 @@ func (r *repository) GetAllSubscriptionsFilter(ctx context.Context, filter *enti
 		queryBuilder.WriteString(strings.Join(conditions, " AND "))
 	}
 
+	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", column.name, direction, direction, len(args)+1))
+	args = append(args, params.Limit)
+
 	query := queryBuilder.String()
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
@@ -136,26 +195,14 @@ func (r *repository) GetAllSubscriptionsFilter(ctx context.Context, filter *enti
 		}
 	}()
 
-	subscriptions := make([]entity.Subscription, 0)
-
-	for rows.Next() {
-		var subscription entity.Subscription
-		err = rows.Scan(
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.ServiceName,
-			&subscription.Price,
-			&subscription.StartDate,
-			&subscription.EndDate,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scan row: %w", err)
-		}
-		subscriptions = append(subscriptions, subscription)
+	subscriptions, err := scanSubscriptions(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration: %w", err)
+	err = r.attachPriceChanges(ctx, subscriptions)
+	if err != nil {
+		return nil, fmt.Errorf("attach price changes: %w", err)
 	}
 
 	return subscriptions, nil
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+)
+
+// GetRoleAssignments returns the role assignments of subject, or of every subject if it is empty, ordered by
+// subject and role.
+func (r *repository) GetRoleAssignments(ctx context.Context, subject string) (_ []entity.RoleAssignment, err error) {
+	const query = `SELECT subject, role, assigned_by, assigned_at FROM app.role_assignments
+		WHERE $1 = '' OR subject = $1
+		ORDER BY subject, role`
+
+	rows, err := r.db.QueryContext(ctx, query, subject)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close rows: %w", closeErr))
+		}
+	}()
+
+	assignments := make([]entity.RoleAssignment, 0)
+
+	for rows.Next() {
+		var assignment entity.RoleAssignment
+		err = rows.Scan(&assignment.Subject, &assignment.Role, &assignment.AssignedBy, &assignment.AssignedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		assignment.AssignedAt = assignment.AssignedAt.UTC()
+		assignments = append(assignments, assignment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// CreateRoleAssignment stores the assignment. Assigning a role the subject already has keeps the existing
+// assignment and reports false.
+func (r *repository) CreateRoleAssignment(ctx context.Context, assignment *entity.RoleAssignment) (bool, error) {
+	const query = `INSERT INTO app.role_assignments (subject, role, assigned_by, assigned_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subject, role) DO NOTHING`
+
+	res, err := r.db.ExecContext(ctx, query, assignment.Subject, assignment.Role, assignment.AssignedBy, assignment.AssignedAt)
+	if err != nil {
+		return false, fmt.Errorf("exec query: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *repository) DeleteRoleAssignment(ctx context.Context, subject, role string) error {
+	const query = `DELETE FROM app.role_assignments WHERE subject = $1 AND role = $2`
+
+	res, err := r.db.ExecContext(ctx, query, subject, role)
+	if err != nil {
+		return fmt.Errorf("exec query: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepoNotFound
+	}
+
+	return nil
+}
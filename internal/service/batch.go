@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"slices"
+)
+
+// NewSubscriptions creates the subscriptions in a single transaction and returns their IDs in the same order.
+// Either all of them are created or none. Callers scoped to their own subscriptions can only create subscriptions
+// for themselves.
+func (s *service) NewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData, actor string) ([]uuid.UUID, error) {
+	err := s.ValidateNewSubscriptions(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]entity.Subscription, 0, len(data))
+	ids := make([]uuid.UUID, 0, len(data))
+
+	for _, d := range data {
+		sub := newSubscription(d)
+		subs = append(subs, *sub)
+		ids = append(ids, sub.ID)
+	}
+
+	err = s.repo.CreateSubscriptions(ctx, subs, newChange(actor))
+	if err != nil {
+		return nil, fmt.Errorf("repo: create subscriptions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ValidateNewSubscriptions makes the checks of NewSubscriptions without creating anything: it fails with
+// ErrForbidden if the caller may not create one of the subscriptions.
+func (s *service) ValidateNewSubscriptions(ctx context.Context, data []*entity.CreateSubscriptionData) error {
+	for _, d := range data {
+		err := checkOwnership(ctx, d.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateSubscriptions applies the updates in a single transaction. In BatchModeAtomic a failed item leaves every
+// subscription unchanged; in BatchModePartial the other items are still applied. Item failures are reported in
+// the results, in the order of updates. Subscriptions of other users than the caller fail with ErrNotFound, and
+// items are checked against the scheduled prices as in UpdateSubscription.
+func (s *service) UpdateSubscriptions(ctx context.Context, updates []entity.SubscriptionUpdate, mode entity.BatchMode, actor string) ([]entity.BatchItemResult, error) {
+	results := make([]entity.BatchItemResult, len(updates))
+	accessible := make([]entity.SubscriptionUpdate, 0, len(updates))
+	indexes := make([]int, 0, len(updates))
+	failed := false
+
+	for i, update := range updates {
+		results[i].ID = update.ID
+
+		sub, err := s.GetSubscription(ctx, update.ID)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			results[i].Err = err
+			failed = true
+			continue
+		}
+
+		if len(update.IfMatch) > 0 && !slices.Contains(update.IfMatch, sub.Version) {
+			results[i].Err = ErrPreconditionFailed
+			failed = true
+			continue
+		}
+
+		err = checkPriceHistory(sub, update.Data)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+
+		// As in UpdateSubscription, the item was checked against this version.
+		update.IfMatch = []int64{sub.Version}
+		accessible = append(accessible, update)
+		indexes = append(indexes, i)
+	}
+
+	if (failed && mode == entity.BatchModeAtomic) || len(accessible) == 0 {
+		return results, nil
+	}
+
+	applied, err := s.repo.UpdateSubscriptions(ctx, accessible, mode == entity.BatchModeAtomic, newChange(actor))
+	if err != nil {
+		return nil, fmt.Errorf("repo: update subscriptions: %w", err)
+	}
+
+	for j, result := range mapBatchResults(applied) {
+		results[indexes[j]] = result
+	}
+
+	return results, nil
+}
+
+// CancelSubscriptions cancels the subscriptions as CancelSubscription would, in a single transaction. In
+// BatchModeAtomic a failed item leaves every subscription unchanged; in BatchModePartial the other items are still
+// applied. Item failures are reported in the results, in the order of ids.
+func (s *service) CancelSubscriptions(ctx context.Context, ids []uuid.UUID, atPeriodEnd bool, mode entity.BatchMode, actor string) ([]entity.BatchItemResult, error) {
+	change := newChange(actor)
+
+	results := make([]entity.BatchItemResult, len(ids))
+	transitions := make([]entity.StatusTransition, 0, len(ids))
+	versions := make([][]int64, 0, len(ids))
+	indexes := make([]int, 0, len(ids))
+	failed := false
+
+	for i, id := range ids {
+		results[i].ID = id
+
+		sub, err := s.GetSubscription(ctx, id)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			results[i].Err = err
+			failed = true
+			continue
+		}
+
+		if !entity.CanTransition(sub.Status, entity.SubscriptionStatusCancelled) {
+			results[i].Err = invalidTransition(sub.Status, entity.SubscriptionStatusCancelled)
+			failed = true
+			continue
+		}
+
+		endDate := cancellationEndDate(sub, change.At, atPeriodEnd)
+		transitions = append(transitions, entity.StatusTransition{
+			SubscriptionID: id,
+			From:           sub.Status,
+			To:             entity.SubscriptionStatusCancelled,
+			Change:         *change,
+			EndDate:        &endDate,
+		})
+		// The end date was derived from this version, so a concurrent change must not be overwritten.
+		versions = append(versions, []int64{sub.Version})
+		indexes = append(indexes, i)
+	}
+
+	if (failed && mode == entity.BatchModeAtomic) || len(transitions) == 0 {
+		return results, nil
+	}
+
+	applied, err := s.repo.TransitionSubscriptions(ctx, transitions, versions, mode == entity.BatchModeAtomic)
+	if err != nil {
+		return nil, fmt.Errorf("repo: transition subscriptions: %w", err)
+	}
+
+	for j, result := range mapBatchResults(applied) {
+		results[indexes[j]] = result
+	}
+
+	return results, nil
+}
+
+func mapBatchResults(results []entity.BatchItemResult) []entity.BatchItemResult {
+	for i := range results {
+		switch {
+		case errors.Is(results[i].Err, repo.ErrRepoNotFound):
+			results[i].Err = ErrNotFound
+		case errors.Is(results[i].Err, repo.ErrRepoVersionMismatch):
+			results[i].Err = ErrPreconditionFailed
+		}
+	}
+
+	return results
+}
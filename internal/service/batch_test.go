@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestMapBatchResults(t *testing.T) {
+	failure := errors.New("boom")
+	results := []entity.BatchItemResult{
+		{Version: 2},
+		{Err: repo.ErrRepoNotFound},
+		{Err: repo.ErrRepoVersionMismatch},
+		{Err: failure},
+	}
+
+	got := mapBatchResults(results)
+	want := []error{nil, ErrNotFound, ErrPreconditionFailed, failure}
+	for i := range want {
+		if !errors.Is(got[i].Err, want[i]) || (want[i] == nil && got[i].Err != nil) {
+			t.Fatalf("result %d error = %v, want %v", i, got[i].Err, want[i])
+		}
+	}
+	if got[0].Version != 2 {
+		t.Fatalf("result 0 version = %d, want 2", got[0].Version)
+	}
+}
+
+func TestUpdateSubscriptions(t *testing.T) {
+	owner := uuid.New()
+	own := &entity.Subscription{ID: uuid.New(), UserID: owner}
+	stale := &entity.Subscription{ID: uuid.New(), UserID: owner}
+	others := &entity.Subscription{ID: uuid.New(), UserID: uuid.New()}
+	subscriptions := map[uuid.UUID]*entity.Subscription{own.ID: own, stale.ID: stale, others.ID: others}
+
+	tests := []struct {
+		name        string
+		mode        entity.BatchMode
+		ids         []uuid.UUID
+		wantErrs    []error
+		wantUpdated int
+	}{
+		{
+			name:        "atomic",
+			mode:        entity.BatchModeAtomic,
+			ids:         []uuid.UUID{own.ID, stale.ID},
+			wantErrs:    []error{nil, ErrPreconditionFailed},
+			wantUpdated: 2,
+		},
+		{
+			name:     "atomic with another user's subscription",
+			mode:     entity.BatchModeAtomic,
+			ids:      []uuid.UUID{own.ID, others.ID},
+			wantErrs: []error{nil, ErrNotFound},
+		},
+		{
+			name:        "partial with another user's subscription",
+			mode:        entity.BatchModePartial,
+			ids:         []uuid.UUID{others.ID, own.ID},
+			wantErrs:    []error{ErrNotFound, nil},
+			wantUpdated: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRepository{
+				subscriptions: subscriptions,
+				updateErrs:    map[uuid.UUID]error{stale.ID: repo.ErrRepoVersionMismatch},
+			}
+
+			updates := make([]entity.SubscriptionUpdate, len(tt.ids))
+			for i, id := range tt.ids {
+				updates[i] = entity.SubscriptionUpdate{ID: id, Data: &entity.UpdateSubscriptionData{}}
+			}
+
+			results, err := NewService(fake, 0).UpdateSubscriptions(withRoles(owner, auth.RoleUser), updates, tt.mode, "test")
+			if err != nil {
+				t.Fatalf("UpdateSubscriptions() error = %v", err)
+			}
+			if len(results) != len(tt.ids) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.ids))
+			}
+			for i, want := range tt.wantErrs {
+				if results[i].ID != tt.ids[i] {
+					t.Fatalf("result %d ID = %v, want %v", i, results[i].ID, tt.ids[i])
+				}
+				if !errors.Is(results[i].Err, want) || (want == nil && results[i].Err != nil) {
+					t.Fatalf("result %d error = %v, want %v", i, results[i].Err, want)
+				}
+			}
+			if len(fake.updated) != tt.wantUpdated {
+				t.Fatalf("updated %d subscriptions, want %d", len(fake.updated), tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionsPriceHistory(t *testing.T) {
+	sub := &entity.Subscription{
+		ID:           uuid.New(),
+		Price:        entity.Money{Amount: 1000, Currency: "RUB"},
+		PriceChanges: []entity.PriceChange{{Price: entity.Money{Amount: 1200, Currency: "RUB"}}},
+		Version:      3,
+	}
+	plain := &entity.Subscription{ID: uuid.New(), Price: entity.Money{Amount: 1000, Currency: "RUB"}, Version: 5}
+	fake := &fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub, plain.ID: plain}}
+
+	jpy := &entity.UpdateSubscriptionData{Price: entity.Money{Amount: 1000, Currency: "JPY"}}
+	updates := []entity.SubscriptionUpdate{{ID: sub.ID, Data: jpy}, {ID: plain.ID, Data: jpy}}
+
+	results, err := NewService(fake, 0).UpdateSubscriptions(context.Background(), updates, entity.BatchModePartial, "test")
+	if err != nil {
+		t.Fatalf("UpdateSubscriptions() error = %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrPriceHistoryConflict) {
+		t.Fatalf("result 0 error = %v, want %v", results[0].Err, ErrPriceHistoryConflict)
+	}
+	if results[1].Err != nil {
+		t.Fatalf("result 1 error = %v, want none", results[1].Err)
+	}
+	if len(fake.updated) != 1 || fake.updated[0].ID != plain.ID || len(fake.updated[0].IfMatch) != 1 || fake.updated[0].IfMatch[0] != plain.Version {
+		t.Fatalf("updated = %+v, want only %v pinned to version %d", fake.updated, plain.ID, plain.Version)
+	}
+}
@@ -3,5 +3,14 @@ package service
 import "errors"
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound             = errors.New("not found")
+	ErrInvalidCursor        = errors.New("cursor does not match the requested sort")
+	ErrMixedCurrencies      = errors.New("subscriptions are priced in different currencies, a target currency is required")
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+	ErrPreconditionFailed   = errors.New("subscription version does not match")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	ErrInvalidTransition    = errors.New("subscription cannot change to the requested status")
+	ErrInvalidPriceChange   = errors.New("price change cannot be scheduled")
+	ErrPriceHistoryConflict = errors.New("update conflicts with the scheduled prices")
+	ErrForbidden            = errors.New("subscription belongs to another user")
 )
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+)
+
+func (s *service) GetExchangeRates(ctx context.Context, filter *entity.GetExchangeRatesFilter) ([]entity.ExchangeRate, error) {
+	rates, err := s.repo.GetExchangeRates(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo: get exchange rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+func (s *service) SetExchangeRates(ctx context.Context, rates []entity.ExchangeRate) error {
+	err := s.repo.UpsertExchangeRates(ctx, rates)
+	if err != nil {
+		return fmt.Errorf("repo: upsert exchange rates: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) DeleteExchangeRate(ctx context.Context, rate *entity.ExchangeRate) error {
+	err := s.repo.DeleteExchangeRate(ctx, rate)
+	if err != nil {
+		if errors.Is(err, repo.ErrRepoNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("repo: delete exchange rate: %w", err)
+	}
+
+	return nil
+}
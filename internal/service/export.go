@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+)
+
+// ExportSubscriptions calls fn with every subscription matching the filter, in sort order, while they are read from
+// the repository. Unlike ListSubscriptions the result is neither paginated nor buffered, and price changes are not
+// included. An error returned by fn stops the export.
+func (s *service) ExportSubscriptions(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) error {
+	err := s.repo.StreamSubscriptions(ctx, scopeFilter(ctx, filter), sort, fn)
+	if err != nil {
+		return fmt.Errorf("repo: stream subscriptions: %w", err)
+	}
+
+	return nil
+}
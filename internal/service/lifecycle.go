@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// ActivateSubscription ends the trial of a subscription and returns its new version.
+func (s *service) ActivateSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error) {
+	return s.transition(ctx, id, entity.SubscriptionStatusActive, actor, ifMatch, func(sub *entity.Subscription, _ *entity.StatusTransition) error {
+		if sub.Status != entity.SubscriptionStatusTrial {
+			return invalidTransition(sub.Status, entity.SubscriptionStatusActive)
+		}
+		return nil
+	})
+}
+
+// PauseSubscription pauses an active subscription and returns its new version. Months the subscription stays
+// paused throughout are not charged.
+func (s *service) PauseSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error) {
+	return s.transition(ctx, id, entity.SubscriptionStatusPaused, actor, ifMatch, nil)
+}
+
+// ResumeSubscription makes a paused subscription active again and returns its new version.
+func (s *service) ResumeSubscription(ctx context.Context, id uuid.UUID, actor string, ifMatch []int64) (int64, error) {
+	return s.transition(ctx, id, entity.SubscriptionStatusActive, actor, ifMatch, func(sub *entity.Subscription, _ *entity.StatusTransition) error {
+		if sub.Status != entity.SubscriptionStatusPaused {
+			return invalidTransition(sub.Status, entity.SubscriptionStatusActive)
+		}
+		return nil
+	})
+}
+
+// CancelSubscription cancels the subscription and returns its new version. The subscription ends with the current
+// month, or with the last month of the current billing cycle if atPeriodEnd is set, unless it was due to end
+// earlier.
+func (s *service) CancelSubscription(ctx context.Context, id uuid.UUID, atPeriodEnd bool, actor string, ifMatch []int64) (int64, error) {
+	return s.transition(ctx, id, entity.SubscriptionStatusCancelled, actor, ifMatch, func(sub *entity.Subscription, transition *entity.StatusTransition) error {
+		endDate := cancellationEndDate(sub, transition.At, atPeriodEnd)
+		transition.EndDate = &endDate
+		return nil
+	})
+}
+
+// cancellationEndDate returns the last month of a subscription cancelled at at.
+func cancellationEndDate(sub *entity.Subscription, at time.Time, atPeriodEnd bool) time.Time {
+	endDate := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if atPeriodEnd {
+		endDate = sub.PeriodEnd(at)
+	}
+	if endDate.Before(sub.StartDate) {
+		endDate = sub.StartDate
+	}
+	if sub.EndDate != nil && sub.EndDate.Before(endDate) {
+		endDate = *sub.EndDate
+	}
+
+	return endDate
+}
+
+// ExpireSubscriptions moves every subscription whose end date has passed and that is not cancelled to the
+// expired status. It returns how many subscriptions expired. It affects the subscriptions of every user, whoever
+// the caller is.
+func (s *service) ExpireSubscriptions(ctx context.Context, actor string) (int64, error) {
+	change := newChange(actor)
+	currentMonth := time.Date(change.At.Year(), change.At.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	expired, err := s.repo.ExpireSubscriptions(ctx, currentMonth, change)
+	if err != nil {
+		return 0, fmt.Errorf("repo: expire subscriptions: %w", err)
+	}
+
+	return expired, nil
+}
+
+// transition moves the subscription to status to if its version is one of ifMatch; an empty ifMatch skips the
+// check. prepare, if set, may reject the transition or complete it before it is stored.
+func (s *service) transition(
+	ctx context.Context,
+	id uuid.UUID,
+	to entity.SubscriptionStatus,
+	actor string,
+	ifMatch []int64,
+	prepare func(sub *entity.Subscription, transition *entity.StatusTransition) error,
+) (int64, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, sub.Version) {
+		return 0, ErrPreconditionFailed
+	}
+
+	if !entity.CanTransition(sub.Status, to) {
+		return 0, invalidTransition(sub.Status, to)
+	}
+
+	transition := &entity.StatusTransition{
+		SubscriptionID: id,
+		From:           sub.Status,
+		To:             to,
+		Change:         *newChange(actor),
+	}
+
+	if prepare != nil {
+		err = prepare(sub, transition)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The transition was checked against this version, so a concurrent change must not be overwritten.
+	version, err := s.repo.TransitionSubscription(ctx, transition, []int64{sub.Version})
+	if err != nil {
+		if errors.Is(err, repo.ErrRepoNotFound) {
+			return 0, ErrNotFound
+		}
+		if errors.Is(err, repo.ErrRepoVersionMismatch) {
+			return 0, ErrPreconditionFailed
+		}
+		return 0, fmt.Errorf("repo: transition subscription: %w", err)
+	}
+
+	return version, nil
+}
+
+func invalidTransition(from, to entity.SubscriptionStatus) error {
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestCancellationEndDate(t *testing.T) {
+	at := time.Date(2025, time.May, 14, 10, 0, 0, 0, time.UTC)
+	month := func(m time.Month) time.Time { return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC) }
+	endsIn := func(m time.Month) *time.Time { end := month(m); return &end }
+
+	tests := []struct {
+		name        string
+		sub         entity.Subscription
+		atPeriodEnd bool
+		want        time.Time
+	}{
+		{
+			name: "current month",
+			sub:  entity.Subscription{BillingPeriod: entity.BillingPeriodQuarterly, BillingInterval: 1, StartDate: month(time.January)},
+			want: month(time.May),
+		},
+		{
+			name:        "end of the billing cycle",
+			sub:         entity.Subscription{BillingPeriod: entity.BillingPeriodQuarterly, BillingInterval: 1, StartDate: month(time.January)},
+			atPeriodEnd: true,
+			want:        month(time.June),
+		},
+		{
+			name: "not started yet",
+			sub:  entity.Subscription{BillingPeriod: entity.BillingPeriodMonthly, BillingInterval: 1, StartDate: month(time.August)},
+			want: month(time.August),
+		},
+		{
+			name:        "cycle end after the end date",
+			sub:         entity.Subscription{BillingPeriod: entity.BillingPeriodYearly, BillingInterval: 1, StartDate: month(time.January), EndDate: endsIn(time.July)},
+			atPeriodEnd: true,
+			want:        month(time.July),
+		},
+		{
+			name: "already ended",
+			sub:  entity.Subscription{BillingPeriod: entity.BillingPeriodMonthly, BillingInterval: 1, StartDate: month(time.January), EndDate: endsIn(time.March)},
+			want: month(time.March),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cancellationEndDate(&tt.sub, at, tt.atPeriodEnd); !got.Equal(tt.want) {
+				t.Fatalf("cancellationEndDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  entity.SubscriptionStatus
+		apply   func(s *service, id uuid.UUID, ifMatch []int64) (int64, error)
+		ifMatch []int64
+		wantTo  entity.SubscriptionStatus
+		wantErr error
+	}{
+		{name: "activate trial", status: entity.SubscriptionStatusTrial, apply: activate, wantTo: entity.SubscriptionStatusActive},
+		{name: "activate paused", status: entity.SubscriptionStatusPaused, apply: activate, wantErr: ErrInvalidTransition},
+		{name: "pause active", status: entity.SubscriptionStatusActive, apply: pause, wantTo: entity.SubscriptionStatusPaused},
+		{name: "pause trial", status: entity.SubscriptionStatusTrial, apply: pause, wantErr: ErrInvalidTransition},
+		{name: "resume paused", status: entity.SubscriptionStatusPaused, apply: resume, wantTo: entity.SubscriptionStatusActive},
+		{name: "resume trial", status: entity.SubscriptionStatusTrial, apply: resume, wantErr: ErrInvalidTransition},
+		{name: "cancel active", status: entity.SubscriptionStatusActive, apply: cancel, wantTo: entity.SubscriptionStatusCancelled},
+		{name: "cancel cancelled", status: entity.SubscriptionStatusCancelled, apply: cancel, wantErr: ErrInvalidTransition},
+		{name: "cancel expired", status: entity.SubscriptionStatusExpired, apply: cancel, wantErr: ErrInvalidTransition},
+		{name: "matching version", status: entity.SubscriptionStatusActive, apply: pause, ifMatch: []int64{3}, wantTo: entity.SubscriptionStatusPaused},
+		{name: "stale version", status: entity.SubscriptionStatusActive, apply: pause, ifMatch: []int64{2}, wantErr: ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &entity.Subscription{ID: uuid.New(), Status: tt.status, Version: 3}
+			fake := &fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub}}
+
+			version, err := tt.apply(NewService(fake, 0), sub.ID, tt.ifMatch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if fake.transitioned != nil {
+					t.Fatalf("stored transition %+v, want none", fake.transitioned)
+				}
+				return
+			}
+
+			if version != 4 {
+				t.Fatalf("version = %d, want 4", version)
+			}
+			if got := fake.transitioned; got.From != tt.status || got.To != tt.wantTo || got.Actor != "test" {
+				t.Fatalf("transition = %+v, want %s to %s by test", got, tt.status, tt.wantTo)
+			}
+			if (fake.transitioned.EndDate != nil) != (tt.wantTo == entity.SubscriptionStatusCancelled) {
+				t.Fatalf("transition EndDate = %v, want one only when cancelling", fake.transitioned.EndDate)
+			}
+		})
+	}
+}
+
+func TestTransitionConcurrentChange(t *testing.T) {
+	sub := &entity.Subscription{ID: uuid.New(), Status: entity.SubscriptionStatusActive, Version: 3}
+	fake := &fakeRepository{
+		subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub},
+		transitionErr: repo.ErrRepoVersionMismatch,
+	}
+
+	_, err := pause(NewService(fake, 0), sub.ID, nil)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("error = %v, want %v", err, ErrPreconditionFailed)
+	}
+}
+
+func activate(s *service, id uuid.UUID, ifMatch []int64) (int64, error) {
+	return s.ActivateSubscription(context.Background(), id, "test", ifMatch)
+}
+
+func pause(s *service, id uuid.UUID, ifMatch []int64) (int64, error) {
+	return s.PauseSubscription(context.Background(), id, "test", ifMatch)
+}
+
+func resume(s *service, id uuid.UUID, ifMatch []int64) (int64, error) {
+	return s.ResumeSubscription(context.Background(), id, "test", ifMatch)
+}
+
+func cancel(s *service, id uuid.UUID, ifMatch []int64) (int64, error) {
+	return s.CancelSubscription(context.Background(), id, false, "test", ifMatch)
+}
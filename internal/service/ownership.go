@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+)
+
+// userIDClaim names the token claim holding the caller's user ID when the subject is not one.
+const userIDClaim = "user_id"
+
+// ownerScope returns the user whose subscriptions the caller may access, or nil if the caller may access all of
+// them: callers with auth.PermissionSubscriptionsAllUsers and requests without an identity, such as those made with
+// authentication disabled. A caller whose token names no valid user ID is scoped to uuid.Nil and so sees nothing.
+func ownerScope(ctx context.Context) *uuid.UUID {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || identity.HasPermission(auth.PermissionSubscriptionsAllUsers) {
+		return nil
+	}
+
+	userID := callerUserID(identity)
+
+	return &userID
+}
+
+func callerUserID(identity *auth.Identity) uuid.UUID {
+	if claim, ok := identity.Claims[userIDClaim].(string); ok {
+		if id, err := uuid.Parse(claim); err == nil {
+			return id
+		}
+	}
+
+	if id, err := uuid.Parse(identity.Subject); err == nil {
+		return id
+	}
+
+	return uuid.Nil
+}
+
+// scopeFilter returns filter limited to the subscriptions the caller may access. filter itself is not modified.
+func scopeFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter) *entity.GetSubscriptionsFilter {
+	owner := ownerScope(ctx)
+	if owner == nil {
+		return filter
+	}
+
+	var scoped entity.GetSubscriptionsFilter
+	if filter != nil {
+		scoped = *filter
+	}
+	scoped.OwnerID = owner
+
+	return &scoped
+}
+
+// checkOwnership fails with ErrForbidden if the caller may not create a subscription for userID.
+func checkOwnership(ctx context.Context, userID uuid.UUID) error {
+	owner := ownerScope(ctx)
+	if owner != nil && *owner != userID {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// eventsOwnedBy tells whether the subscription the events record belonged to owner. The subscription may be
+// deleted, so the owner is read from the snapshots.
+func eventsOwnedBy(events []entity.SubscriptionEvent, owner uuid.UUID) bool {
+	for _, event := range events {
+		snapshot := event.After
+		if snapshot == nil {
+			snapshot = event.Before
+		}
+
+		var row struct {
+			UserID uuid.UUID `json:"user_id"`
+		}
+		if json.Unmarshal(snapshot, &row) == nil {
+			return row.UserID == owner
+		}
+	}
+
+	return false
+}
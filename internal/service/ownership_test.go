@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/auth"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func withRoles(userID uuid.UUID, roles ...string) context.Context {
+	return auth.WithIdentity(context.Background(), &auth.Identity{
+		Subject: userID.String(),
+		Roles:   roles,
+	})
+}
+
+func TestOwnerScope(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		unscoped bool
+	}{
+		{name: "no identity", ctx: context.Background(), unscoped: true},
+		{name: "admin", ctx: withRoles(userID, auth.RoleAdmin), unscoped: true},
+		{name: "operator", ctx: withRoles(userID, auth.RoleOperator), unscoped: true},
+		{name: "user", ctx: withRoles(userID, auth.RoleUser)},
+		{name: "read-only", ctx: withRoles(userID, auth.RoleReadOnly)},
+		{name: "read-only and operator", ctx: withRoles(userID, auth.RoleReadOnly, auth.RoleOperator), unscoped: true},
+		{name: "no role", ctx: withRoles(userID)},
+		{name: "unknown role", ctx: withRoles(userID, "superuser")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := ownerScope(tt.ctx)
+
+			if tt.unscoped {
+				if owner != nil {
+					t.Fatalf("ownerScope() = %v, want nil", *owner)
+				}
+				return
+			}
+
+			if owner == nil || *owner != userID {
+				t.Fatalf("ownerScope() = %v, want %v", owner, userID)
+			}
+		})
+	}
+}
+
+func TestCallerUserID(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		identity *auth.Identity
+		want     uuid.UUID
+	}{
+		{
+			name:     "subject",
+			identity: &auth.Identity{Subject: userID.String()},
+			want:     userID,
+		},
+		{
+			name:     "user_id claim wins over subject",
+			identity: &auth.Identity{Subject: uuid.NewString(), Claims: map[string]any{"user_id": userID.String()}},
+			want:     userID,
+		},
+		{
+			name:     "invalid user_id claim falls back to subject",
+			identity: &auth.Identity{Subject: userID.String(), Claims: map[string]any{"user_id": "nope"}},
+			want:     userID,
+		},
+		{
+			name:     "no user ID",
+			identity: &auth.Identity{Subject: "alice@example.com"},
+			want:     uuid.Nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := callerUserID(tt.identity)
+			if got != tt.want {
+				t.Fatalf("callerUserID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionOwnership(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	sub := &entity.Subscription{ID: uuid.New(), UserID: owner}
+	srvc := NewService(&fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub}}, 0)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "owner", ctx: withRoles(owner, auth.RoleUser)},
+		{name: "other user", ctx: withRoles(other, auth.RoleUser), wantErr: ErrNotFound},
+		{name: "other read-only", ctx: withRoles(other, auth.RoleReadOnly), wantErr: ErrNotFound},
+		{name: "operator", ctx: withRoles(other, auth.RoleOperator)},
+		{name: "admin", ctx: withRoles(other, auth.RoleAdmin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srvc.GetSubscription(tt.ctx, sub.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSubscriptionsOwnership(t *testing.T) {
+	caller, other := uuid.New(), uuid.New()
+	data := []*entity.CreateSubscriptionData{{UserID: caller}, {UserID: other}}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "user", ctx: withRoles(caller, auth.RoleUser), wantErr: ErrForbidden},
+		{name: "operator", ctx: withRoles(caller, auth.RoleOperator)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRepository{}
+			srvc := NewService(fake, 0)
+
+			_, err := srvc.NewSubscriptions(tt.ctx, data, "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewSubscriptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && len(fake.created) > 0 {
+				t.Fatalf("created %d subscriptions, want none", len(fake.created))
+			}
+		})
+	}
+}
+
+func TestValidateNewSubscriptions(t *testing.T) {
+	caller, other := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		data    []*entity.CreateSubscriptionData
+		wantErr error
+	}{
+		{name: "own", ctx: withRoles(caller, auth.RoleUser), data: []*entity.CreateSubscriptionData{{UserID: caller}}},
+		{
+			name:    "other user",
+			ctx:     withRoles(caller, auth.RoleUser),
+			data:    []*entity.CreateSubscriptionData{{UserID: caller}, {UserID: other}},
+			wantErr: ErrForbidden,
+		},
+		{
+			name: "operator",
+			ctx:  withRoles(caller, auth.RoleOperator),
+			data: []*entity.CreateSubscriptionData{{UserID: other}},
+		},
+		{name: "no identity", ctx: context.Background(), data: []*entity.CreateSubscriptionData{{UserID: other}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRepository{}
+			srvc := NewService(fake, 0)
+
+			err := srvc.ValidateNewSubscriptions(tt.ctx, tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateNewSubscriptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(fake.created) > 0 {
+				t.Fatalf("created %d subscriptions, want none", len(fake.created))
+			}
+		})
+	}
+}
+
+func TestScopeFilter(t *testing.T) {
+	userID := uuid.New()
+	filter := &entity.GetSubscriptionsFilter{ServiceNames: []string{"Netflix"}}
+
+	scoped := scopeFilter(withRoles(userID, auth.RoleUser), filter)
+	if scoped.OwnerID == nil || *scoped.OwnerID != userID {
+		t.Fatalf("scoped OwnerID = %v, want %v", scoped.OwnerID, userID)
+	}
+	if filter.OwnerID != nil {
+		t.Fatal("scopeFilter modified its argument")
+	}
+	if len(scoped.ServiceNames) != 1 {
+		t.Fatalf("scoped ServiceNames = %v, want the original ones", scoped.ServiceNames)
+	}
+
+	if got := scopeFilter(withRoles(userID, auth.RoleOperator), filter); got != filter {
+		t.Fatal("scopeFilter changed the filter of an operator")
+	}
+
+	if got := scopeFilter(withRoles(userID), nil); got == nil || got.OwnerID == nil {
+		t.Fatal("scopeFilter(nil) of a scoped caller has no OwnerID")
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	caller := uuid.New()
+
+	if err := checkOwnership(withRoles(caller, auth.RoleUser), caller); err != nil {
+		t.Fatalf("checkOwnership() of the caller's own user ID error = %v", err)
+	}
+	if err := checkOwnership(withRoles(caller, auth.RoleUser), uuid.New()); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("checkOwnership() of another user ID error = %v, want %v", err, ErrForbidden)
+	}
+	if err := checkOwnership(withRoles(caller, auth.RoleAdmin), uuid.New()); err != nil {
+		t.Fatalf("checkOwnership() of an admin error = %v", err)
+	}
+}
+
+func TestEventsOwnedBy(t *testing.T) {
+	owner := uuid.New()
+	snapshot := json.RawMessage(`{"user_id":"` + owner.String() + `"}`)
+	otherSnapshot := json.RawMessage(`{"user_id":"` + uuid.NewString() + `"}`)
+
+	tests := []struct {
+		name   string
+		events []entity.SubscriptionEvent
+		want   bool
+	}{
+		{name: "created", events: []entity.SubscriptionEvent{{After: snapshot}}, want: true},
+		{name: "deleted", events: []entity.SubscriptionEvent{{After: snapshot}, {Before: snapshot}}, want: true},
+		{name: "only the deletion", events: []entity.SubscriptionEvent{{Before: snapshot}}, want: true},
+		{name: "other user", events: []entity.SubscriptionEvent{{After: otherSnapshot}}},
+		{name: "unreadable snapshot skipped", events: []entity.SubscriptionEvent{{After: json.RawMessage(`[`)}, {After: snapshot}}, want: true},
+		{name: "no snapshot", events: []entity.SubscriptionEvent{{}}},
+		{name: "no events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventsOwnedBy(tt.events, owner); got != tt.want {
+				t.Fatalf("eventsOwnedBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionHistoryOwnership(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	events := []entity.SubscriptionEvent{{ID: 1, After: json.RawMessage(`{"user_id":"` + owner.String() + `"}`)}}
+	srvc := NewService(&fakeRepository{events: events}, 0)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "owner", ctx: withRoles(owner, auth.RoleUser)},
+		{name: "other user", ctx: withRoles(other, auth.RoleUser), wantErr: ErrNotFound},
+		{name: "operator", ctx: withRoles(other, auth.RoleOperator)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srvc.GetSubscriptionHistory(tt.ctx, uuid.New())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetSubscriptionHistory() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsScoped(t *testing.T) {
+	caller := uuid.New()
+	filter := &entity.GetSubscriptionsFilter{ServiceNames: []string{"Netflix"}}
+
+	fake := &fakeRepository{}
+	_, err := NewService(fake, 0).ListSubscriptions(withRoles(caller, auth.RoleUser), &entity.ListSubscriptionsParams{Filter: filter, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSubscriptions() error = %v", err)
+	}
+	if got := fake.listParams.Filter; got.OwnerID == nil || *got.OwnerID != caller || len(got.ServiceNames) != 1 {
+		t.Fatalf("filter = %+v, want Netflix subscriptions of %v", got, caller)
+	}
+
+	_, err = NewService(fake, 0).ListSubscriptions(withRoles(caller, auth.RoleAdmin), &entity.ListSubscriptionsParams{Filter: filter, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSubscriptions() error = %v", err)
+	}
+	if fake.listParams.Filter.OwnerID != nil {
+		t.Fatalf("admin filter OwnerID = %v, want none", *fake.listParams.Filter.OwnerID)
+	}
+}
+
+func TestChangesOfOtherUsersSubscriptions(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	sub := &entity.Subscription{ID: uuid.New(), UserID: owner, Status: entity.SubscriptionStatusActive}
+	srvc := NewService(&fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub}}, 0)
+	ctx := withRoles(other, auth.RoleUser)
+
+	_, err := srvc.UpdateSubscription(ctx, sub.ID, &entity.UpdateSubscriptionData{}, nil, "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UpdateSubscription() error = %v, want %v", err, ErrNotFound)
+	}
+
+	_, err = srvc.CancelSubscription(ctx, sub.ID, false, "test", nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("CancelSubscription() error = %v, want %v", err, ErrNotFound)
+	}
+
+	err = srvc.DeleteSubscription(ctx, sub.ID, nil, "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteSubscription() error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// monthFormat formats months in error details the way the API accepts them.
+const monthFormat = "01-2006"
+
+// SchedulePriceChange makes amount, a decimal in the subscription's currency, its price from the month
+// effectiveFrom on, and returns the subscription's new version. The change must take effect after the
+// subscription starts, not before the current month and not after the subscription ends; a change already
+// scheduled for the same month is replaced.
+func (s *service) SchedulePriceChange(ctx context.Context, id uuid.UUID, amount string, effectiveFrom time.Time, actor string, ifMatch []int64) (int64, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, sub.Version) {
+		return 0, ErrPreconditionFailed
+	}
+
+	price, err := entity.ParseMoney(amount, sub.Price.Currency)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidPriceChange, err)
+	}
+
+	if price.IsNegative() {
+		return 0, fmt.Errorf("%w: price is negative", ErrInvalidPriceChange)
+	}
+
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	switch {
+	case !effectiveFrom.After(sub.StartDate):
+		return 0, fmt.Errorf("%w: effective date is not after the start date", ErrInvalidPriceChange)
+	case effectiveFrom.Before(currentMonth):
+		return 0, fmt.Errorf("%w: effective date is in the past", ErrInvalidPriceChange)
+	case sub.EndDate != nil && effectiveFrom.After(*sub.EndDate):
+		return 0, fmt.Errorf("%w: effective date is after the end date", ErrInvalidPriceChange)
+	}
+
+	priceChange := &entity.PriceChange{
+		Price:         price,
+		EffectiveFrom: effectiveFrom,
+	}
+
+	// The change was validated against this version, so a concurrent update must not be overwritten.
+	version, err := s.repo.SchedulePriceChange(ctx, id, priceChange, []int64{sub.Version}, newChange(actor))
+	if err != nil {
+		if errors.Is(err, repo.ErrRepoNotFound) {
+			return 0, ErrNotFound
+		}
+		if errors.Is(err, repo.ErrRepoVersionMismatch) {
+			return 0, ErrPreconditionFailed
+		}
+		return 0, fmt.Errorf("repo: schedule price change: %w", err)
+	}
+
+	return version, nil
+}
+
+// checkPriceHistory fails with ErrPriceHistoryConflict if data would change how the scheduled prices of sub are
+// read. They are stored in minor units of sub's currency, so the currency cannot change while there are any, and a
+// changed start or end date must still leave every price change after the start and not after the end.
+func checkPriceHistory(sub *entity.Subscription, data *entity.UpdateSubscriptionData) error {
+	if len(sub.PriceChanges) == 0 {
+		return nil
+	}
+
+	if data.Price.Currency != sub.Price.Currency {
+		return fmt.Errorf("%w: currency cannot change from %s while price changes are scheduled", ErrPriceHistoryConflict, sub.Price.Currency)
+	}
+
+	startChanged := !data.StartDate.Equal(sub.StartDate)
+	endChanged := (data.EndDate == nil) != (sub.EndDate == nil) || (data.EndDate != nil && !data.EndDate.Equal(*sub.EndDate))
+
+	for _, priceChange := range sub.PriceChanges {
+		switch {
+		case startChanged && !priceChange.EffectiveFrom.After(data.StartDate):
+			return fmt.Errorf("%w: a price change effective from %s is not after the start date", ErrPriceHistoryConflict, priceChange.EffectiveFrom.Format(monthFormat))
+		case endChanged && data.EndDate != nil && priceChange.EffectiveFrom.After(*data.EndDate):
+			return fmt.Errorf("%w: a price change effective from %s is after the end date", ErrPriceHistoryConflict, priceChange.EffectiveFrom.Format(monthFormat))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestSchedulePriceChange(t *testing.T) {
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	nextMonth := currentMonth.AddDate(0, 1, 0)
+	lastMonth := currentMonth.AddDate(0, -1, 0)
+	endDate := currentMonth.AddDate(0, 6, 0)
+
+	tests := []struct {
+		name          string
+		amount        string
+		effectiveFrom time.Time
+		startDate     time.Time
+		endDate       *time.Time
+		ifMatch       []int64
+		repoErr       error
+		wantErr       error
+	}{
+		{name: "next month", amount: "12.00", effectiveFrom: nextMonth, startDate: lastMonth},
+		{name: "current month", amount: "12.00", effectiveFrom: currentMonth, startDate: lastMonth},
+		{name: "on the end date", amount: "12.00", effectiveFrom: endDate, startDate: lastMonth, endDate: &endDate},
+		{name: "matching version", amount: "12.00", effectiveFrom: nextMonth, startDate: lastMonth, ifMatch: []int64{1}},
+		{name: "stale version", amount: "12.00", effectiveFrom: nextMonth, startDate: lastMonth, ifMatch: []int64{0}, wantErr: ErrPreconditionFailed},
+		{name: "invalid amount", amount: "12.345", effectiveFrom: nextMonth, startDate: lastMonth, wantErr: ErrInvalidPriceChange},
+		{name: "negative amount", amount: "-1", effectiveFrom: nextMonth, startDate: lastMonth, wantErr: ErrInvalidPriceChange},
+		{name: "on the start date", amount: "12.00", effectiveFrom: nextMonth, startDate: nextMonth, wantErr: ErrInvalidPriceChange},
+		{name: "in the past", amount: "12.00", effectiveFrom: lastMonth, startDate: lastMonth.AddDate(0, -1, 0), wantErr: ErrInvalidPriceChange},
+		{name: "after the end date", amount: "12.00", effectiveFrom: endDate.AddDate(0, 1, 0), startDate: lastMonth, endDate: &endDate, wantErr: ErrInvalidPriceChange},
+		{name: "concurrent change", amount: "12.00", effectiveFrom: nextMonth, startDate: lastMonth, repoErr: repo.ErrRepoVersionMismatch, wantErr: ErrPreconditionFailed},
+		{name: "deleted meanwhile", amount: "12.00", effectiveFrom: nextMonth, startDate: lastMonth, repoErr: repo.ErrRepoNotFound, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &entity.Subscription{
+				ID:        uuid.New(),
+				Price:     entity.Money{Amount: 1000, Currency: "USD"},
+				StartDate: tt.startDate,
+				EndDate:   tt.endDate,
+				Version:   1,
+			}
+			fake := &fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub}, scheduleErr: tt.repoErr}
+
+			version, err := NewService(fake, 0).SchedulePriceChange(context.Background(), sub.ID, tt.amount, tt.effectiveFrom, "test", tt.ifMatch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SchedulePriceChange() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if tt.repoErr == nil && fake.scheduled != nil {
+					t.Fatalf("scheduled %+v, want nothing", fake.scheduled)
+				}
+				return
+			}
+
+			if version != 2 {
+				t.Fatalf("SchedulePriceChange() = %d, want 2", version)
+			}
+			want := entity.PriceChange{Price: entity.Money{Amount: 1200, Currency: "USD"}, EffectiveFrom: tt.effectiveFrom}
+			if fake.scheduled == nil || *fake.scheduled != want {
+				t.Fatalf("scheduled %+v, want %+v", fake.scheduled, want)
+			}
+		})
+	}
+}
+
+func TestCheckPriceHistory(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	month := func(m time.Month) *time.Time {
+		date := time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+
+	scheduled := &entity.Subscription{
+		Price:        entity.Money{Amount: 1000, Currency: "RUB"},
+		StartDate:    start,
+		EndDate:      &end,
+		PriceChanges: []entity.PriceChange{{Price: entity.Money{Amount: 1200, Currency: "RUB"}, EffectiveFrom: *month(time.June)}},
+	}
+	// cancelled ended before its price change took effect.
+	cancelled := &entity.Subscription{
+		Price:        entity.Money{Amount: 1000, Currency: "RUB"},
+		StartDate:    start,
+		EndDate:      month(time.March),
+		PriceChanges: scheduled.PriceChanges,
+	}
+
+	tests := []struct {
+		name     string
+		sub      *entity.Subscription
+		currency string
+		start    time.Time
+		end      *time.Time
+		wantErr  error
+	}{
+		{name: "unchanged", sub: scheduled, currency: "RUB", start: start, end: &end},
+		{name: "currency without price changes", sub: &entity.Subscription{Price: entity.Money{Currency: "RUB"}, StartDate: start}, currency: "JPY", start: start},
+		{name: "currency", sub: scheduled, currency: "JPY", start: start, end: &end, wantErr: ErrPriceHistoryConflict},
+		{name: "start moved before the change", sub: scheduled, currency: "RUB", start: *month(time.May), end: &end},
+		{name: "start moved onto the change", sub: scheduled, currency: "RUB", start: *month(time.June), end: &end, wantErr: ErrPriceHistoryConflict},
+		{name: "start moved past the change", sub: scheduled, currency: "RUB", start: *month(time.July), end: &end, wantErr: ErrPriceHistoryConflict},
+		{name: "end moved onto the change", sub: scheduled, currency: "RUB", start: start, end: month(time.June)},
+		{name: "end moved before the change", sub: scheduled, currency: "RUB", start: start, end: month(time.May), wantErr: ErrPriceHistoryConflict},
+		{name: "made open-ended", sub: scheduled, currency: "RUB", start: start},
+		{name: "end kept before the change", sub: cancelled, currency: "RUB", start: start, end: month(time.March)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &entity.UpdateSubscriptionData{
+				Price:     entity.Money{Amount: 1500, Currency: tt.currency},
+				StartDate: tt.start,
+				EndDate:   tt.end,
+			}
+
+			err := checkPriceHistory(tt.sub, data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkPriceHistory() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionPriceHistory(t *testing.T) {
+	sub := &entity.Subscription{
+		ID:           uuid.New(),
+		Price:        entity.Money{Amount: 1000, Currency: "RUB"},
+		StartDate:    time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		PriceChanges: []entity.PriceChange{{Price: entity.Money{Amount: 1200, Currency: "RUB"}, EffectiveFrom: time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)}},
+		Version:      3,
+	}
+
+	tests := []struct {
+		name     string
+		currency string
+		ifMatch  []int64
+		wantErr  error
+	}{
+		{name: "same currency", currency: "RUB"},
+		{name: "same currency and matching version", currency: "RUB", ifMatch: []int64{2, 3}},
+		{name: "stale version", currency: "RUB", ifMatch: []int64{2}, wantErr: ErrPreconditionFailed},
+		{name: "other currency", currency: "JPY", wantErr: ErrPriceHistoryConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRepository{subscriptions: map[uuid.UUID]*entity.Subscription{sub.ID: sub}}
+			data := &entity.UpdateSubscriptionData{Price: entity.Money{Amount: 1000, Currency: tt.currency}, StartDate: sub.StartDate}
+
+			version, err := NewService(fake, 0).UpdateSubscription(context.Background(), sub.ID, data, tt.ifMatch, "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if fake.updatedData != nil {
+					t.Fatal("UpdateSubscription() wrote a rejected update")
+				}
+				return
+			}
+
+			if version != 4 {
+				t.Fatalf("UpdateSubscription() = %d, want 4", version)
+			}
+			// The update is pinned to the version it was checked against.
+			if len(fake.updateIfMatch) != 1 || fake.updateIfMatch[0] != sub.Version {
+				t.Fatalf("repository ifMatch = %v, want [%d]", fake.updateIfMatch, sub.Version)
+			}
+		})
+	}
+}
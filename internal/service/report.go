@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+)
+
+// GetMonthlyReport returns spend for every month of the filter's date range, including months without any
+// active subscription. Without a target currency all subscriptions in the range must share one currency.
+func (s *service) GetMonthlyReport(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) (*entity.MonthlyReport, error) {
+	overlapFilter := *scopeFilter(ctx, filter)
+	overlapFilter.Match = entity.DateMatchOverlaps
+
+	spend, err := s.repo.GetMonthlySpend(ctx, &overlapFilter, targetCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("repo: get monthly spend: %w", mapAggregateError(err))
+	}
+
+	report := &entity.MonthlyReport{
+		Currency: targetCurrency,
+		Months:   make([]entity.MonthReport, 0),
+	}
+
+	for _, row := range spend {
+		if report.Currency == "" {
+			report.Currency = row.Currency
+		}
+		if row.Currency != report.Currency {
+			return nil, ErrMixedCurrencies
+		}
+	}
+
+	for month := filter.StartDate; !month.After(filter.EndDate); month = month.AddDate(0, 1, 0) {
+		monthReport := entity.MonthReport{
+			Month:    month,
+			Services: make([]entity.ServiceSpend, 0),
+		}
+
+		for len(spend) > 0 && spend[0].Month.Equal(month) {
+			monthReport.TotalPrice += spend[0].TotalPrice
+			monthReport.ActiveSubscriptions += spend[0].ActiveSubscriptions
+			monthReport.Services = append(monthReport.Services, entity.ServiceSpend{
+				ServiceName:         spend[0].ServiceName,
+				TotalPrice:          spend[0].TotalPrice,
+				ActiveSubscriptions: spend[0].ActiveSubscriptions,
+			})
+			spend = spend[1:]
+		}
+
+		report.Months = append(report.Months, monthReport)
+	}
+
+	return report, nil
+}
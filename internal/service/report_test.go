@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	"testing"
+	"time"
+)
+
+func month(m time.Month) time.Time {
+	return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestGetMonthlyReport(t *testing.T) {
+	fake := &fakeRepository{spend: []entity.MonthlySpend{
+		{Month: month(time.January), ServiceName: "Netflix", Currency: "RUB", TotalPrice: 500, ActiveSubscriptions: 1},
+		{Month: month(time.January), ServiceName: "Spotify", Currency: "RUB", TotalPrice: 300, ActiveSubscriptions: 2},
+		{Month: month(time.March), ServiceName: "Netflix", Currency: "RUB", TotalPrice: 500, ActiveSubscriptions: 1},
+	}}
+	srvc := NewService(fake, 0)
+
+	filter := &entity.GetSubscriptionsFilter{StartDate: month(time.January), EndDate: month(time.April)}
+
+	report, err := srvc.GetMonthlyReport(context.Background(), filter, "")
+	if err != nil {
+		t.Fatalf("GetMonthlyReport() error = %v", err)
+	}
+
+	if fake.sumFilter.Match != entity.DateMatchOverlaps {
+		t.Errorf("repository match = %q, want %q", fake.sumFilter.Match, entity.DateMatchOverlaps)
+	}
+	if filter.Match != "" {
+		t.Errorf("GetMonthlyReport modified the filter's match to %q", filter.Match)
+	}
+	if report.Currency != "RUB" {
+		t.Errorf("Currency = %q, want the subscriptions' RUB", report.Currency)
+	}
+
+	want := []struct {
+		month    time.Time
+		total    int64
+		active   int
+		services int
+	}{
+		{month: month(time.January), total: 800, active: 3, services: 2},
+		{month: month(time.February)},
+		{month: month(time.March), total: 500, active: 1, services: 1},
+		{month: month(time.April)},
+	}
+	if len(report.Months) != len(want) {
+		t.Fatalf("got %d months, want %d", len(report.Months), len(want))
+	}
+	for i, w := range want {
+		got := report.Months[i]
+		if !got.Month.Equal(w.month) || got.TotalPrice != w.total || got.ActiveSubscriptions != w.active || len(got.Services) != w.services {
+			t.Errorf("month %d = %+v, want %s with total %d, %d active, %d services", i, got, w.month.Format("01-2006"), w.total, w.active, w.services)
+		}
+		if got.Services == nil {
+			t.Errorf("month %d has nil services, want an empty list", i)
+		}
+	}
+}
+
+func TestGetMonthlyReportCurrencies(t *testing.T) {
+	tests := []struct {
+		name           string
+		spend          []entity.MonthlySpend
+		targetCurrency string
+		want           string
+		wantErr        error
+	}{
+		{name: "target currency", targetCurrency: "EUR", want: "EUR"},
+		{name: "no spend", want: ""},
+		{
+			name: "mixed currencies",
+			spend: []entity.MonthlySpend{
+				{Month: month(time.January), ServiceName: "Netflix", Currency: "RUB"},
+				{Month: month(time.January), ServiceName: "Spotify", Currency: "USD"},
+			},
+			wantErr: ErrMixedCurrencies,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srvc := NewService(&fakeRepository{spend: tt.spend}, 0)
+			filter := &entity.GetSubscriptionsFilter{StartDate: month(time.January), EndDate: month(time.January)}
+
+			report, err := srvc.GetMonthlyReport(context.Background(), filter, tt.targetCurrency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetMonthlyReport() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && report.Currency != tt.want {
+				t.Fatalf("Currency = %q, want %q", report.Currency, tt.want)
+			}
+		})
+	}
+}
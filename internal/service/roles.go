@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+)
+
+// GetRoleAssignments returns the role assignments of subject, or of every subject if it is empty.
+func (s *service) GetRoleAssignments(ctx context.Context, subject string) ([]entity.RoleAssignment, error) {
+	assignments, err := s.repo.GetRoleAssignments(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("repo: get role assignments: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// GetSubjectRoles returns the roles assigned to subject.
+func (s *service) GetSubjectRoles(ctx context.Context, subject string) ([]string, error) {
+	assignments, err := s.GetRoleAssignments(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(assignments))
+	for _, assignment := range assignments {
+		roles = append(roles, assignment.Role)
+	}
+
+	return roles, nil
+}
+
+// AssignRole grants role to subject and tells whether the subject did not have it yet.
+func (s *service) AssignRole(ctx context.Context, subject, role, actor string) (bool, error) {
+	change := newChange(actor)
+
+	assignment := &entity.RoleAssignment{
+		Subject:    subject,
+		Role:       role,
+		AssignedBy: change.Actor,
+		AssignedAt: change.At,
+	}
+
+	created, err := s.repo.CreateRoleAssignment(ctx, assignment)
+	if err != nil {
+		return false, fmt.Errorf("repo: create role assignment: %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *service) RevokeRole(ctx context.Context, subject, role string) error {
+	err := s.repo.DeleteRoleAssignment(ctx, subject, role)
+	if err != nil {
+		if errors.Is(err, repo.ErrRepoNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("repo: delete role assignment: %w", err)
+	}
+
+	return nil
+}
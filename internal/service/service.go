@@ -2,32 +2,64 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/BernsteinMondy/subscription-service/internal/entity"
 	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
 	"github.com/google/uuid"
+	"slices"
+	"time"
 )
 
 type repository interface {
 	GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*entity.Subscription, error)
-	GetAllSubscriptionsFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter) ([]entity.Subscription, error)
+	ListSubscriptions(ctx context.Context, params *entity.ListSubscriptionsParams) ([]entity.Subscription, error)
+	StreamSubscriptions(ctx context.Context, filter *entity.GetSubscriptionsFilter, sort entity.Sort, fn func(subscription *entity.Subscription) error) error
 
-	CreateSubscription(ctx context.Context, subscription *entity.Subscription) (uuid.UUID, error)
-	UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData) error
-	DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error
+	SumSubscriptionsPrice(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.Money, error)
+	SumSubscriptionsMonthlyAccrual(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.Money, error)
+	GetMonthlySpend(ctx context.Context, filter *entity.GetSubscriptionsFilter, targetCurrency string) ([]entity.MonthlySpend, error)
+
+	GetExchangeRates(ctx context.Context, filter *entity.GetExchangeRatesFilter) ([]entity.ExchangeRate, error)
+	UpsertExchangeRates(ctx context.Context, rates []entity.ExchangeRate) error
+	DeleteExchangeRate(ctx context.Context, rate *entity.ExchangeRate) error
+
+	GetRoleAssignments(ctx context.Context, subject string) ([]entity.RoleAssignment, error)
+	CreateRoleAssignment(ctx context.Context, assignment *entity.RoleAssignment) (bool, error)
+	DeleteRoleAssignment(ctx context.Context, subject, role string) error
+
+	CreateSubscription(ctx context.Context, subscription *entity.Subscription, change *entity.Change) (uuid.UUID, error)
+	CreateSubscriptionIdempotent(ctx context.Context, subscription *entity.Subscription, key *entity.IdempotencyKey, change *entity.Change) (uuid.UUID, error)
+	UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, change *entity.Change) (int64, error)
+	TransitionSubscription(ctx context.Context, transition *entity.StatusTransition, ifMatch []int64) (int64, error)
+	ExpireSubscriptions(ctx context.Context, endedBefore time.Time, change *entity.Change) (int64, error)
+	DeleteSubscriptionByID(ctx context.Context, id uuid.UUID, ifMatch []int64, change *entity.Change) error
+	GetSubscriptionEvents(ctx context.Context, id uuid.UUID) ([]entity.SubscriptionEvent, error)
+
+	CreateSubscriptions(ctx context.Context, subscriptions []entity.Subscription, change *entity.Change) error
+	UpdateSubscriptions(ctx context.Context, updates []entity.SubscriptionUpdate, atomic bool, change *entity.Change) ([]entity.BatchItemResult, error)
+	TransitionSubscriptions(ctx context.Context, transitions []entity.StatusTransition, ifMatch [][]int64, atomic bool) ([]entity.BatchItemResult, error)
+	SchedulePriceChange(ctx context.Context, id uuid.UUID, priceChange *entity.PriceChange, ifMatch []int64, change *entity.Change) (int64, error)
 }
 
 type service struct {
-	repo repository
+	repo              repository
+	idempotencyKeyTTL time.Duration
 }
 
-func NewService(repo repository) *service {
+// NewService creates the service. Idempotency keys of create requests are remembered for idempotencyKeyTTL.
+func NewService(repo repository, idempotencyKeyTTL time.Duration) *service {
 	return &service{
-		repo: repo,
+		repo:              repo,
+		idempotencyKeyTTL: idempotencyKeyTTL,
 	}
 }
 
+// GetSubscription returns the subscription. Subscriptions of other users are reported as ErrNotFound unless the
+// caller may access every user's subscriptions.
 func (s *service) GetSubscription(ctx context.Context, id uuid.UUID) (*entity.Subscription, error) {
 	sub, err := s.repo.GetSubscriptionByID(ctx, id)
 	if err != nil {
@@ -37,70 +69,246 @@ func (s *service) GetSubscription(ctx context.Context, id uuid.UUID) (*entity.Su
 		return nil, fmt.Errorf("repo: get subscription by id: %w", err)
 	}
 
+	if owner := ownerScope(ctx); owner != nil && *owner != sub.UserID {
+		return nil, ErrNotFound
+	}
+
 	return sub, nil
 }
 
-func (s *service) NewSubscription(ctx context.Context, data *entity.CreateSubscriptionData) (uuid.UUID, error) {
-	sub := &entity.Subscription{
-		ID:          uuid.New(),
-		UserID:      data.UserID,
-		ServiceName: data.ServiceName,
-		Price:       data.Price,
-		StartDate:   data.StartDate,
-		EndDate:     data.EndDate,
+// NewSubscription creates a subscription. A non-empty idempotencyKey makes retries of the same request return
+// the subscription created first; reusing the key for a different request fails with ErrIdempotencyKeyReused.
+// Callers scoped to their own subscriptions can only create subscriptions for themselves.
+func (s *service) NewSubscription(ctx context.Context, data *entity.CreateSubscriptionData, idempotencyKey, actor string) (uuid.UUID, error) {
+	err := checkOwnership(ctx, data.UserID)
+	if err != nil {
+		return uuid.Nil, err
 	}
 
-	id, err := s.repo.CreateSubscription(ctx, sub)
+	sub := newSubscription(data)
+
+	change := newChange(actor)
+
+	if idempotencyKey == "" {
+		id, err := s.repo.CreateSubscription(ctx, sub, change)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("repo: create subscription: %w", err)
+		}
+
+		return id, nil
+	}
+
+	requestHash, err := hashRequest(data)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("repo: create subscription: %w", err)
+		return uuid.Nil, fmt.Errorf("hash request: %w", err)
+	}
+
+	key := &entity.IdempotencyKey{
+		Key:            idempotencyKey,
+		RequestHash:    requestHash,
+		SubscriptionID: sub.ID,
+		CreatedAt:      change.At,
+		ExpiresAt:      change.At.Add(s.idempotencyKeyTTL),
+	}
+
+	id, err := s.repo.CreateSubscriptionIdempotent(ctx, sub, key, change)
+	if err != nil {
+		if errors.Is(err, repo.ErrRepoIdempotencyKeyReused) {
+			return uuid.Nil, ErrIdempotencyKeyReused
+		}
+		return uuid.Nil, fmt.Errorf("repo: create subscription idempotent: %w", err)
 	}
 
 	return id, nil
 }
 
-func (s *service) CancelSubscription(ctx context.Context, id uuid.UUID) error {
-	err := s.repo.DeleteSubscriptionByID(ctx, id)
+// GetSubscriptionHistory returns every recorded change of the subscription, oldest first. The history of a
+// deleted subscription is still returned.
+func (s *service) GetSubscriptionHistory(ctx context.Context, id uuid.UUID) ([]entity.SubscriptionEvent, error) {
+	events, err := s.repo.GetSubscriptionEvents(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("repo: get subscription events: %w", err)
+	}
+
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if owner := ownerScope(ctx); owner != nil && !eventsOwnedBy(events, *owner) {
+		return nil, ErrNotFound
+	}
+
+	return events, nil
+}
+
+// newChange attributes a change made now to actor.
+func newChange(actor string) *entity.Change {
+	return &entity.Change{
+		Actor: actor,
+		At:    time.Now().UTC(),
+	}
+}
+
+func newSubscription(data *entity.CreateSubscriptionData) *entity.Subscription {
+	return &entity.Subscription{
+		ID:              uuid.New(),
+		UserID:          data.UserID,
+		ServiceName:     data.ServiceName,
+		Price:           data.Price,
+		BillingPeriod:   data.BillingPeriod,
+		BillingInterval: data.BillingInterval,
+		StartDate:       data.StartDate,
+		EndDate:         data.EndDate,
+		Status:          data.Status,
+	}
+}
+
+// hashRequest fingerprints the parsed request, so retries that differ only in formatting still match.
+func hashRequest(data any) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DeleteSubscription permanently removes the subscription if its version is one of ifMatch; an empty ifMatch
+// skips the check. The subscription disappears from historical totals as well.
+func (s *service) DeleteSubscription(ctx context.Context, id uuid.UUID, ifMatch []int64, actor string) error {
+	err := s.checkAccess(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteSubscriptionByID(ctx, id, ifMatch, newChange(actor))
 	if err != nil {
 		if errors.Is(err, repo.ErrRepoNotFound) {
 			return ErrNotFound
 		}
+		if errors.Is(err, repo.ErrRepoVersionMismatch) {
+			return ErrPreconditionFailed
+		}
 		return fmt.Errorf("repo: delete subscription: %w", err)
 	}
 
 	return nil
 }
 
-func (s *service) UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData) error {
-	err := s.repo.UpdateSubscription(ctx, id, data)
+// UpdateSubscription updates the subscription if its version is one of ifMatch and returns the new version;
+// an empty ifMatch skips the check. The update must not contradict the subscription's scheduled prices.
+func (s *service) UpdateSubscription(ctx context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, actor string) (int64, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, sub.Version) {
+		return 0, ErrPreconditionFailed
+	}
+
+	err = checkPriceHistory(sub, data)
+	if err != nil {
+		return 0, err
+	}
+
+	// The update was checked against this version, so a price change scheduled meanwhile must not be missed.
+	version, err := s.repo.UpdateSubscription(ctx, id, data, []int64{sub.Version}, newChange(actor))
 	if err != nil {
 		if errors.Is(err, repo.ErrRepoNotFound) {
-			return ErrNotFound
+			return 0, ErrNotFound
 		}
-		return fmt.Errorf("repo: update subscription: %w", err)
+		if errors.Is(err, repo.ErrRepoVersionMismatch) {
+			return 0, ErrPreconditionFailed
+		}
+		return 0, fmt.Errorf("repo: update subscription: %w", err)
 	}
 
-	return nil
+	return version, nil
 }
 
-func (s *service) GetSubscriptionsTotalSumFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter) (int32, error) {
-	subs, err := s.repo.GetAllSubscriptionsFilter(ctx, filter)
-	if err != nil {
-		return 0, fmt.Errorf("repo: get all subscriptions with filter: %w", err)
+// checkAccess fails with ErrNotFound if the subscription does not exist or belongs to another user than the
+// caller. It costs no query for callers that may access every subscription.
+func (s *service) checkAccess(ctx context.Context, id uuid.UUID) error {
+	if ownerScope(ctx) == nil {
+		return nil
 	}
 
-	totalPrice := int32(0)
-	for _, sub := range subs {
-		totalPrice += sub.Price
+	_, err := s.GetSubscription(ctx, id)
+
+	return err
+}
+
+// GetSubscriptionsTotalSumFilter sums the prices in targetCurrency. Without a target currency all matching
+// subscriptions must share one currency.
+func (s *service) GetSubscriptionsTotalSumFilter(ctx context.Context, filter *entity.GetSubscriptionsFilter, mode entity.TotalMode, targetCurrency string) (*entity.Money, error) {
+	var (
+		totals []entity.Money
+		err    error
+	)
+
+	filter = scopeFilter(ctx, filter)
+
+	switch mode {
+	case entity.TotalModeMonthlyAccrual:
+		totals, err = s.repo.SumSubscriptionsMonthlyAccrual(ctx, filter, targetCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("repo: sum subscriptions monthly accrual: %w", mapAggregateError(err))
+		}
+	default:
+		totals, err = s.repo.SumSubscriptionsPrice(ctx, filter, targetCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("repo: sum subscriptions price: %w", mapAggregateError(err))
+		}
+	}
+
+	switch len(totals) {
+	case 0:
+		return &entity.Money{Currency: targetCurrency}, nil
+	case 1:
+		return &totals[0], nil
+	default:
+		return nil, ErrMixedCurrencies
 	}
+}
 
-	return totalPrice, nil
+func mapAggregateError(err error) error {
+	if errors.Is(err, repo.ErrRepoExchangeRateNotFound) {
+		return ErrExchangeRateNotFound
+	}
+	return err
 }
 
-func (s *service) GetAllSubscriptions(ctx context.Context) ([]entity.Subscription, error) {
-	subs, err := s.repo.GetAllSubscriptionsFilter(ctx, nil)
+func (s *service) ListSubscriptions(ctx context.Context, params *entity.ListSubscriptionsParams) (*entity.SubscriptionsPage, error) {
+	if params.Cursor != nil && params.Cursor.Sort != params.Sort {
+		return nil, ErrInvalidCursor
+	}
+
+	// One extra row tells whether there is a next page without a separate COUNT query.
+	fetchParams := *params
+	fetchParams.Limit = params.Limit + 1
+	fetchParams.Filter = scopeFilter(ctx, params.Filter)
+
+	subs, err := s.repo.ListSubscriptions(ctx, &fetchParams)
 	if err != nil {
-		return nil, fmt.Errorf("repo: get all subscriptions: %w", err)
+		return nil, fmt.Errorf("repo: list subscriptions: %w", err)
+	}
+
+	page := &entity.SubscriptionsPage{
+		Subscriptions: subs,
+	}
+
+	if len(subs) > params.Limit {
+		page.Subscriptions = subs[:params.Limit]
+		last := page.Subscriptions[len(page.Subscriptions)-1]
+		page.NextCursor = &entity.Cursor{
+			Sort:  params.Sort,
+			Value: last.SortValue(params.Sort.Field),
+			ID:    last.ID,
+		}
 	}
 
-	return subs, nil
+	return page, nil
 }
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BernsteinMondy/subscription-service/internal/entity"
+	repo "github.com/BernsteinMondy/subscription-service/internal/repository"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// fakeRepository implements the repository methods a test needs; calling any other one panics.
+type fakeRepository struct {
+	repository
+
+	subscriptions map[uuid.UUID]*entity.Subscription
+	created       []entity.Subscription
+
+	// listed is returned by ListSubscriptions, which records its params in listParams.
+	listed     []entity.Subscription
+	listParams *entity.ListSubscriptionsParams
+
+	// totals is returned by the sums, which record their name and filter in summed and sumFilter.
+	totals    []entity.Money
+	summed    string
+	sumFilter *entity.GetSubscriptionsFilter
+
+	// spend is returned by GetMonthlySpend.
+	spend []entity.MonthlySpend
+
+	// idempotencyKey records the key CreateSubscriptionIdempotent was called with; idempotencyErr is its error.
+	idempotencyKey *entity.IdempotencyKey
+	idempotencyErr error
+
+	// transitioned records the transition TransitionSubscription stored; transitionErr is its error.
+	transitioned  *entity.StatusTransition
+	transitionErr error
+
+	// events is returned by GetSubscriptionEvents for any subscription.
+	events []entity.SubscriptionEvent
+
+	scheduled   *entity.PriceChange
+	scheduleErr error
+
+	// updated holds the items passed to UpdateSubscriptions; updateErrs fails the items of the given
+	// subscriptions.
+	updated    []entity.SubscriptionUpdate
+	updateErrs map[uuid.UUID]error
+
+	// updatedData and updateIfMatch hold the arguments of the last UpdateSubscription call.
+	updatedData   *entity.UpdateSubscriptionData
+	updateIfMatch []int64
+}
+
+func (f *fakeRepository) UpdateSubscription(_ context.Context, id uuid.UUID, data *entity.UpdateSubscriptionData, ifMatch []int64, _ *entity.Change) (int64, error) {
+	f.updatedData, f.updateIfMatch = data, ifMatch
+	return f.subscriptions[id].Version + 1, nil
+}
+
+func (f *fakeRepository) UpdateSubscriptions(_ context.Context, updates []entity.SubscriptionUpdate, _ bool, _ *entity.Change) ([]entity.BatchItemResult, error) {
+	f.updated = updates
+
+	results := make([]entity.BatchItemResult, len(updates))
+	for i, update := range updates {
+		results[i] = entity.BatchItemResult{ID: update.ID, Version: 2, Err: f.updateErrs[update.ID]}
+	}
+	return results, nil
+}
+
+func (f *fakeRepository) SchedulePriceChange(_ context.Context, id uuid.UUID, priceChange *entity.PriceChange, _ []int64, _ *entity.Change) (int64, error) {
+	if f.scheduleErr != nil {
+		return 0, f.scheduleErr
+	}
+	f.scheduled = priceChange
+	return f.subscriptions[id].Version + 1, nil
+}
+
+func (f *fakeRepository) GetSubscriptionEvents(_ context.Context, _ uuid.UUID) ([]entity.SubscriptionEvent, error) {
+	return f.events, nil
+}
+
+func (f *fakeRepository) TransitionSubscription(_ context.Context, transition *entity.StatusTransition, _ []int64) (int64, error) {
+	if f.transitionErr != nil {
+		return 0, f.transitionErr
+	}
+	f.transitioned = transition
+	return f.subscriptions[transition.SubscriptionID].Version + 1, nil
+}
+
+func (f *fakeRepository) CreateSubscription(_ context.Context, subscription *entity.Subscription, _ *entity.Change) (uuid.UUID, error) {
+	f.created = append(f.created, *subscription)
+	return subscription.ID, nil
+}
+
+func (f *fakeRepository) CreateSubscriptionIdempotent(_ context.Context, subscription *entity.Subscription, key *entity.IdempotencyKey, _ *entity.Change) (uuid.UUID, error) {
+	f.idempotencyKey = key
+	if f.idempotencyErr != nil {
+		return uuid.Nil, f.idempotencyErr
+	}
+	f.created = append(f.created, *subscription)
+	return subscription.ID, nil
+}
+
+func (f *fakeRepository) GetMonthlySpend(_ context.Context, filter *entity.GetSubscriptionsFilter, _ string) ([]entity.MonthlySpend, error) {
+	f.sumFilter = filter
+	return f.spend, nil
+}
+
+func (f *fakeRepository) SumSubscriptionsPrice(_ context.Context, filter *entity.GetSubscriptionsFilter, _ string) ([]entity.Money, error) {
+	f.summed, f.sumFilter = "price", filter
+	return f.totals, nil
+}
+
+func (f *fakeRepository) SumSubscriptionsMonthlyAccrual(_ context.Context, filter *entity.GetSubscriptionsFilter, _ string) ([]entity.Money, error) {
+	f.summed, f.sumFilter = "monthly accrual", filter
+	return f.totals, nil
+}
+
+func (f *fakeRepository) ListSubscriptions(_ context.Context, params *entity.ListSubscriptionsParams) ([]entity.Subscription, error) {
+	f.listParams = params
+	if len(f.listed) > params.Limit {
+		return f.listed[:params.Limit], nil
+	}
+	return f.listed, nil
+}
+
+func (f *fakeRepository) GetSubscriptionByID(_ context.Context, id uuid.UUID) (*entity.Subscription, error) {
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		return nil, repo.ErrRepoNotFound
+	}
+	return sub, nil
+}
+
+func (f *fakeRepository) CreateSubscriptions(_ context.Context, subscriptions []entity.Subscription, _ *entity.Change) error {
+	f.created = append(f.created, subscriptions...)
+	return nil
+}
+
+// subscriptionsNamed returns subscriptions with the given service names, in order.
+func subscriptionsNamed(names ...string) []entity.Subscription {
+	subs := make([]entity.Subscription, 0, len(names))
+	for _, name := range names {
+		subs = append(subs, entity.Subscription{ID: uuid.New(), ServiceName: name})
+	}
+
+	return subs
+}
+
+func TestListSubscriptionsPages(t *testing.T) {
+	sort := entity.Sort{Field: entity.SortByServiceName, Order: entity.SortAsc}
+
+	tests := []struct {
+		name       string
+		listed     []entity.Subscription
+		limit      int
+		wantLen    int
+		wantCursor bool
+	}{
+		{name: "more rows than the limit", listed: subscriptionsNamed("a", "b", "c"), limit: 2, wantLen: 2, wantCursor: true},
+		{name: "exactly the limit", listed: subscriptionsNamed("a", "b"), limit: 2, wantLen: 2},
+		{name: "fewer rows", listed: subscriptionsNamed("a"), limit: 2, wantLen: 1},
+		{name: "no rows", limit: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRepository{listed: tt.listed}
+			srvc := NewService(fake, 0)
+
+			page, err := srvc.ListSubscriptions(context.Background(), &entity.ListSubscriptionsParams{Sort: sort, Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("ListSubscriptions() error = %v", err)
+			}
+
+			if fake.listParams.Limit != tt.limit+1 {
+				t.Fatalf("repository limit = %d, want one extra row", fake.listParams.Limit)
+			}
+			if len(page.Subscriptions) != tt.wantLen {
+				t.Fatalf("got %d subscriptions, want %d", len(page.Subscriptions), tt.wantLen)
+			}
+			if (page.NextCursor != nil) != tt.wantCursor {
+				t.Fatalf("NextCursor = %+v, want one: %v", page.NextCursor, tt.wantCursor)
+			}
+			if !tt.wantCursor {
+				return
+			}
+
+			last := page.Subscriptions[len(page.Subscriptions)-1]
+			want := entity.Cursor{Sort: sort, Value: last.ServiceName, ID: last.ID}
+			if *page.NextCursor != want {
+				t.Fatalf("NextCursor = %+v, want %+v", page.NextCursor, want)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsCursorSortMismatch(t *testing.T) {
+	srvc := NewService(&fakeRepository{}, 0)
+
+	_, err := srvc.ListSubscriptions(context.Background(), &entity.ListSubscriptionsParams{
+		Sort:  entity.Sort{Field: entity.SortByPrice, Order: entity.SortAsc},
+		Limit: 10,
+		Cursor: &entity.Cursor{
+			Sort:  entity.Sort{Field: entity.SortByPrice, Order: entity.SortDesc},
+			Value: "100",
+			ID:    uuid.New(),
+		},
+	})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("ListSubscriptions() error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestGetSubscriptionsTotalSumFilterMode(t *testing.T) {
+	tests := []struct {
+		mode entity.TotalMode
+		want string
+	}{
+		{mode: entity.TotalModeSum, want: "price"},
+		{mode: entity.TotalModeMonthlyAccrual, want: "monthly accrual"},
+		{mode: "", want: "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			fake := &fakeRepository{totals: []entity.Money{{Amount: 1500, Currency: "RUB"}}}
+			srvc := NewService(fake, 0)
+
+			total, err := srvc.GetSubscriptionsTotalSumFilter(context.Background(), &entity.GetSubscriptionsFilter{}, tt.mode, "")
+			if err != nil {
+				t.Fatalf("GetSubscriptionsTotalSumFilter() error = %v", err)
+			}
+			if fake.summed != tt.want {
+				t.Fatalf("summed %s, want %s", fake.summed, tt.want)
+			}
+			if *total != fake.totals[0] {
+				t.Fatalf("total = %+v, want %+v", total, fake.totals[0])
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsTotalSumFilterCurrencies(t *testing.T) {
+	tests := []struct {
+		name           string
+		totals         []entity.Money
+		targetCurrency string
+		want           entity.Money
+		wantErr        error
+	}{
+		{name: "nothing matches", targetCurrency: "EUR", want: entity.Money{Currency: "EUR"}},
+		{name: "nothing matches without target", want: entity.Money{}},
+		{name: "one currency", totals: []entity.Money{{Amount: 999, Currency: "USD"}}, want: entity.Money{Amount: 999, Currency: "USD"}},
+		{
+			name:    "mixed currencies",
+			totals:  []entity.Money{{Amount: 999, Currency: "USD"}, {Amount: 100, Currency: "RUB"}},
+			wantErr: ErrMixedCurrencies,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srvc := NewService(&fakeRepository{totals: tt.totals}, 0)
+
+			total, err := srvc.GetSubscriptionsTotalSumFilter(context.Background(), &entity.GetSubscriptionsFilter{}, entity.TotalModeSum, tt.targetCurrency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetSubscriptionsTotalSumFilter() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && *total != tt.want {
+				t.Fatalf("total = %+v, want %+v", total, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashRequest(t *testing.T) {
+	data := &entity.CreateSubscriptionData{
+		UserID:      uuid.New(),
+		ServiceName: "Netflix",
+		Price:       entity.Money{Amount: 999, Currency: "USD"},
+		StartDate:   time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	first, err := hashRequest(data)
+	if err != nil {
+		t.Fatalf("hashRequest() error = %v", err)
+	}
+	if len(first) != 64 {
+		t.Fatalf("hashRequest() = %q, want a hex SHA-256", first)
+	}
+
+	same := *data
+	if again, _ := hashRequest(&same); again != first {
+		t.Fatalf("hashRequest() of an equal request = %q, want %q", again, first)
+	}
+
+	changed := *data
+	changed.Price.Amount++
+	if other, _ := hashRequest(&changed); other == first {
+		t.Fatal("hashRequest() is the same for a different price")
+	}
+}
+
+func TestNewSubscriptionIdempotencyKey(t *testing.T) {
+	data := &entity.CreateSubscriptionData{UserID: uuid.New(), ServiceName: "Netflix"}
+
+	t.Run("without key", func(t *testing.T) {
+		fake := &fakeRepository{}
+		srvc := NewService(fake, time.Hour)
+
+		id, err := srvc.NewSubscription(context.Background(), data, "", "test")
+		if err != nil {
+			t.Fatalf("NewSubscription() error = %v", err)
+		}
+		if fake.idempotencyKey != nil || len(fake.created) != 1 || fake.created[0].ID != id {
+			t.Fatalf("created %+v with key %+v, want one subscription without key", fake.created, fake.idempotencyKey)
+		}
+	})
+
+	t.Run("with key", func(t *testing.T) {
+		fake := &fakeRepository{}
+		srvc := NewService(fake, time.Hour)
+
+		id, err := srvc.NewSubscription(context.Background(), data, "retry-1", "test")
+		if err != nil {
+			t.Fatalf("NewSubscription() error = %v", err)
+		}
+
+		key := fake.idempotencyKey
+		if key == nil {
+			t.Fatal("CreateSubscriptionIdempotent was not called")
+		}
+		wantHash, _ := hashRequest(data)
+		if key.Key != "retry-1" || key.RequestHash != wantHash || key.SubscriptionID != id {
+			t.Fatalf("key = %+v, want retry-1 for subscription %s with the request's hash", key, id)
+		}
+		if ttl := key.ExpiresAt.Sub(key.CreatedAt); ttl != time.Hour {
+			t.Fatalf("key expires after %v, want %v", ttl, time.Hour)
+		}
+	})
+
+	t.Run("reused key", func(t *testing.T) {
+		srvc := NewService(&fakeRepository{idempotencyErr: repo.ErrRepoIdempotencyKeyReused}, time.Hour)
+
+		_, err := srvc.NewSubscription(context.Background(), data, "retry-1", "test")
+		if !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Fatalf("NewSubscription() error = %v, want %v", err, ErrIdempotencyKeyReused)
+		}
+	})
+}
+
+func TestGetSubscriptionHistory(t *testing.T) {
+	events := []entity.SubscriptionEvent{
+		{ID: 1, Type: "created", After: json.RawMessage(`{"service_name":"Netflix"}`)},
+		{ID: 2, Type: "deleted", Before: json.RawMessage(`{"service_name":"Netflix"}`)},
+	}
+
+	got, err := NewService(&fakeRepository{events: events}, 0).GetSubscriptionHistory(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatalf("GetSubscriptionHistory() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Fatalf("GetSubscriptionHistory() = %+v, want the events in order", got)
+	}
+
+	_, err = NewService(&fakeRepository{}, 0).GetSubscriptionHistory(context.Background(), uuid.New())
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetSubscriptionHistory() of an unknown subscription error = %v, want %v", err, ErrNotFound)
+	}
+}
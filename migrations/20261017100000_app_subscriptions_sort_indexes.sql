@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_subscriptions_start_date_id ON app.subscriptions (start_date, id);
+CREATE INDEX idx_subscriptions_end_date_id ON app.subscriptions (end_date, id);
+CREATE INDEX idx_subscriptions_price_id ON app.subscriptions (price, id);
+CREATE INDEX idx_subscriptions_service_name_id ON app.subscriptions (service_name, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX app.idx_subscriptions_service_name_id;
+DROP INDEX app.idx_subscriptions_price_id;
+DROP INDEX app.idx_subscriptions_end_date_id;
+DROP INDEX app.idx_subscriptions_start_date_id;
+-- +goose StatementEnd
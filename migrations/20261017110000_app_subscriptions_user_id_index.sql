@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_subscriptions_user_id ON app.subscriptions (user_id, start_date, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX app.idx_subscriptions_user_id;
+-- +goose StatementEnd
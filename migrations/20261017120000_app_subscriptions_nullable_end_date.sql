@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    ALTER COLUMN end_date DROP NOT NULL;
+
+DROP INDEX app.idx_subscriptions_end_date_id;
+CREATE INDEX idx_subscriptions_end_date_id
+    ON app.subscriptions (COALESCE(end_date, 'infinity'::timestamptz), id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX app.idx_subscriptions_end_date_id;
+CREATE INDEX idx_subscriptions_end_date_id ON app.subscriptions (end_date, id);
+
+UPDATE app.subscriptions
+SET end_date = '9999-12-01 00:00:00+00'
+WHERE end_date IS NULL;
+
+ALTER TABLE app.subscriptions
+    ALTER COLUMN end_date SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    ADD COLUMN billing_period   text    NOT NULL DEFAULT 'monthly'
+        CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly')),
+    ADD COLUMN billing_interval integer NOT NULL DEFAULT 1
+        CHECK (billing_interval > 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    DROP COLUMN billing_interval,
+    DROP COLUMN billing_period;
+-- +goose StatementEnd
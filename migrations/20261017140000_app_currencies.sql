@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    ADD COLUMN currency text NOT NULL DEFAULT 'RUB'
+        CHECK (currency ~ '^[A-Z]{3}$');
+
+CREATE TABLE app.exchange_rates
+(
+    base_currency  text           NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    quote_currency text           NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+    effective_from timestamptz    NOT NULL,
+    rate           numeric(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, effective_from),
+    CHECK (base_currency <> quote_currency)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.exchange_rates;
+
+ALTER TABLE app.subscriptions
+    DROP COLUMN currency;
+-- +goose StatementEnd
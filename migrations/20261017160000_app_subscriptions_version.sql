@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    ADD COLUMN version bigint NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE app.idempotency_keys
+(
+    key             text        PRIMARY KEY,
+    request_hash    text        NOT NULL,
+    subscription_id uuid        NOT NULL,
+    created_at      timestamptz NOT NULL,
+    expires_at      timestamptz NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON app.idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.idempotency_keys;
+-- +goose StatementEnd
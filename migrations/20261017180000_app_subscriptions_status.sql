@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    ADD COLUMN status       text        NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'cancelled')),
+    ADD COLUMN cancelled_at timestamptz NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Cancelled subscriptions keep their shortened end_date and read as ordinary finished ones.
+ALTER TABLE app.subscriptions
+    DROP COLUMN cancelled_at,
+    DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE app.subscriptions
+    DROP CONSTRAINT subscriptions_status_check,
+    ADD CONSTRAINT subscriptions_status_check
+        CHECK (status IN ('trial', 'active', 'paused', 'cancelled', 'expired'));
+
+CREATE TABLE app.subscription_transitions
+(
+    id              bigserial   PRIMARY KEY,
+    subscription_id uuid        NOT NULL REFERENCES app.subscriptions (id) ON DELETE CASCADE,
+    from_status     text        NOT NULL,
+    to_status       text        NOT NULL,
+    actor           text        NOT NULL,
+    created_at      timestamptz NOT NULL
+);
+
+CREATE INDEX idx_subscription_transitions_subscription_id
+    ON app.subscription_transitions (subscription_id, created_at);
+
+CREATE TABLE app.subscription_pauses
+(
+    subscription_id uuid        NOT NULL REFERENCES app.subscriptions (id) ON DELETE CASCADE,
+    paused_at       timestamptz NOT NULL,
+    resumed_at      timestamptz NULL,
+    PRIMARY KEY (subscription_id, paused_at),
+    CHECK (resumed_at IS NULL OR resumed_at >= paused_at)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.subscription_pauses;
+DROP TABLE app.subscription_transitions;
+
+UPDATE app.subscriptions
+SET status = 'active'
+WHERE status IN ('trial', 'paused', 'expired');
+
+ALTER TABLE app.subscriptions
+    DROP CONSTRAINT subscriptions_status_check,
+    ADD CONSTRAINT subscriptions_status_check
+        CHECK (status IN ('active', 'cancelled'));
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events outlive the subscription, so there is no foreign key: the history of a deleted subscription stays
+-- available for billing disputes.
+CREATE TABLE app.subscription_events
+(
+    id              bigserial   PRIMARY KEY,
+    subscription_id uuid        NOT NULL,
+    type            text        NOT NULL CHECK (type IN ('created', 'updated', 'status_changed', 'deleted')),
+    actor           text        NOT NULL,
+    created_at      timestamptz NOT NULL,
+    before          jsonb       NULL,
+    after           jsonb       NULL
+);
+
+CREATE INDEX idx_subscription_events_subscription_id ON app.subscription_events (subscription_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.subscription_events;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The price in app.subscriptions applies from start_date until the earliest effective_from below.
+CREATE TABLE app.subscription_prices
+(
+    subscription_id uuid        NOT NULL REFERENCES app.subscriptions (id) ON DELETE CASCADE,
+    effective_from  timestamptz NOT NULL,
+    price           bigint      NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (subscription_id, effective_from)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.subscription_prices;
+-- +goose StatementEnd
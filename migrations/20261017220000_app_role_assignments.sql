@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE app.role_assignments
+(
+    subject     text        NOT NULL,
+    role        text        NOT NULL CHECK (role IN ('admin', 'operator', 'read-only', 'user')),
+    assigned_by text        NOT NULL,
+    assigned_at timestamptz NOT NULL,
+    PRIMARY KEY (subject, role)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE app.role_assignments;
+-- +goose StatementEnd